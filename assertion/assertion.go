@@ -0,0 +1,350 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+// Package assertion evaluates assertions against response content.
+//
+// An assertion compares the value at a path with an expected value, eg:
+//
+//	{path: items.#, op: ">=", value: 1}
+//	{path: user.email, op: matches, value: ".*@corp"}
+//	{path: status, op: in, value: [active, pending]}
+//
+// Paths are GJSON paths for JSON content, or XPath expressions for XML.
+package assertion
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xpath"
+)
+
+// Value types.
+const (
+	typeString  = "string"
+	typeNumber  = "number"
+	typeBoolean = "boolean"
+	typeNull    = "null"
+	typeArray   = "array"
+	typeObject  = "object"
+)
+
+// Operators.
+const (
+	OpEqual        = "=="
+	OpNotEqual     = "!="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpMatches      = "matches"
+	OpIn           = "in"
+	OpContains     = "contains"
+	OpExists       = "exists"
+	OpType         = "type"
+	OpCount        = "count"
+)
+
+// Operators, for messages.
+const operators = "==, !=, <, <=, >, >=, matches, in, contains, exists, count, or type"
+
+// expected defines a normalized expected value.
+type expected struct {
+	kind  string
+	str   string
+	num   float64
+	b     bool
+	items []expected
+}
+
+// actual defines a value found in the content.  Untyped values (eg: XML
+// text) are compared as numbers when the expected value is a number.
+type actual struct {
+	exists  bool
+	kind    string
+	text    string
+	num     float64
+	items   []actual
+	count   int    // Array elements, or XPath nodes
+	raw     string // For messages
+	untyped bool
+}
+
+// Assertion defines a compiled assertion.
+type Assertion struct {
+	path   string
+	op     string
+	expect expected
+	re     *regexp.Regexp
+	xpath  *xpath.Expr
+	expr   string
+}
+
+// New compiles an assertion.  Exists defaults to true when no value is
+// given.
+func New(path, op string, value any) (*Assertion, error) {
+
+	if path == "" {
+		return nil, errors.New("assert: missing path")
+	}
+
+	if op == OpExists && value == nil {
+		value = true
+	}
+
+	a := &Assertion{
+		path:   path,
+		op:     op,
+		expect: normalize(value),
+	}
+
+	display, _ := json.Marshal(value)
+	a.expr = fmt.Sprintf("%s %s %s", path, op, display)
+
+	err := a.check()
+	if err != nil {
+		return nil, fmt.Errorf("assert %s: %w", a.expr, err)
+	}
+
+	return a, nil
+}
+
+// String returns the assertion, eg: items.# >= 1
+func (a *Assertion) String() string {
+	return a.expr
+}
+
+// check verifies the expected value suits the operator.
+func (a *Assertion) check() error {
+
+	switch a.op {
+	case OpEqual, OpNotEqual:
+	case OpLess, OpLessEqual, OpGreater, OpGreaterEqual:
+		if a.expect.kind != typeNumber && a.expect.kind != typeString {
+			return errors.New("value must be a number or string")
+		}
+	case OpMatches:
+		if a.expect.kind != typeString {
+			return errors.New("value must be a regular expression")
+		}
+		re, err := regexp.Compile(a.expect.str)
+		if err != nil {
+			return err
+		}
+		a.re = re
+	case OpIn:
+		if a.expect.kind != typeArray {
+			return errors.New("value must be a list")
+		}
+	case OpContains:
+		if a.expect.kind == typeArray || a.expect.kind == typeNull {
+			return errors.New("value must be a single value")
+		}
+	case OpExists:
+		if a.expect.kind != typeBoolean {
+			return errors.New("value must be true or false")
+		}
+	case OpCount:
+		if a.expect.kind != typeNumber {
+			return errors.New("value must be a number")
+		}
+	case OpType:
+		switch a.expect.str {
+		case typeString, typeNumber, typeBoolean, typeNull, typeArray, typeObject:
+		default:
+			return errors.New("value must be string, number, boolean, null, array, or object")
+		}
+	default:
+		return fmt.Errorf("unknown operator: %q (must be %s)", a.op, operators)
+	}
+
+	return nil
+}
+
+// normalize converts a configured value.
+func normalize(v any) expected {
+
+	switch v := v.(type) {
+	case nil:
+		return expected{kind: typeNull}
+	case string:
+		return expected{kind: typeString, str: v}
+	case bool:
+		return expected{kind: typeBoolean, b: v}
+	case int:
+		return expected{kind: typeNumber, num: float64(v)}
+	case int64:
+		return expected{kind: typeNumber, num: float64(v)}
+	case uint64:
+		return expected{kind: typeNumber, num: float64(v)}
+	case float64:
+		return expected{kind: typeNumber, num: v}
+	case []any:
+		e := expected{kind: typeArray}
+		for _, item := range v {
+			e.items = append(e.items, normalize(item))
+		}
+		return e
+	}
+
+	return expected{kind: typeString, str: fmt.Sprint(v)}
+}
+
+// number returns the numeric value, if any.
+func (v *actual) number() (float64, bool) {
+
+	if v.kind == typeNumber {
+		return v.num, true
+	}
+
+	if v.untyped {
+		n, err := strconv.ParseFloat(strings.TrimSpace(v.text), 64)
+		return n, err == nil
+	}
+
+	return 0, false
+}
+
+// equal compares values of the same type.
+func equal(v *actual, e *expected) bool {
+
+	switch e.kind {
+	case typeNumber:
+		n, ok := v.number()
+		return ok && n == e.num
+	case typeString:
+		return (v.kind == typeString || v.untyped) && v.text == e.str
+	case typeBoolean:
+		if v.untyped {
+			b, err := strconv.ParseBool(strings.TrimSpace(v.text))
+			return err == nil && b == e.b
+		}
+		return v.kind == typeBoolean && v.text == strconv.FormatBool(e.b)
+	case typeNull:
+		return v.kind == typeNull
+	}
+
+	return false
+}
+
+// compare orders numbers numerically, and strings lexically.
+func compare(v *actual, e *expected) (int, bool) {
+
+	if e.kind == typeNumber {
+		n, ok := v.number()
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case n < e.num:
+			return -1, true
+		case n > e.num:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	if v.kind != typeString && !v.untyped {
+		return 0, false
+	}
+
+	return strings.Compare(v.text, e.str), true
+}
+
+// evaluate returns an error if the assertion fails.
+func (a *Assertion) evaluate(v *actual) error {
+
+	if a.op == OpExists {
+		if v.exists == a.expect.b {
+			return nil
+		}
+		if v.exists {
+			return a.failed("found %s", v.raw)
+		}
+		return a.failed("not found")
+	}
+
+	if a.op == OpCount {
+		if float64(v.count) == a.expect.num {
+			return nil
+		}
+		return a.failed("got %d", v.count)
+	}
+
+	if !v.exists {
+		return a.failed("not found")
+	}
+
+	passed := false
+
+	switch a.op {
+	case OpEqual:
+		passed = equal(v, &a.expect)
+	case OpNotEqual:
+		passed = !equal(v, &a.expect)
+	case OpLess, OpLessEqual, OpGreater, OpGreaterEqual:
+		c, ok := compare(v, &a.expect)
+		if !ok {
+			return a.failed("%s is not a %s", v.raw, a.expect.kind)
+		}
+		switch a.op {
+		case OpLess:
+			passed = c < 0
+		case OpLessEqual:
+			passed = c <= 0
+		case OpGreater:
+			passed = c > 0
+		case OpGreaterEqual:
+			passed = c >= 0
+		}
+	case OpMatches:
+		passed = a.re.MatchString(v.text)
+	case OpIn:
+		for i := range a.expect.items {
+			if equal(v, &a.expect.items[i]) {
+				passed = true
+				break
+			}
+		}
+	case OpContains:
+		switch {
+		case v.kind == typeArray:
+			for i := range v.items {
+				if equal(&v.items[i], &a.expect) {
+					passed = true
+					break
+				}
+			}
+		case a.expect.kind == typeString:
+			passed = strings.Contains(v.text, a.expect.str)
+		}
+	case OpType:
+		passed = v.kind == a.expect.str
+	}
+
+	if !passed {
+		return a.failed("got %s", v.raw)
+	}
+
+	return nil
+}
+
+// Longest value shown in messages.
+const maxRaw = 128
+
+func (a *Assertion) failed(format string, args ...any) error {
+	return fmt.Errorf("assert %s: %s", a.expr, fmt.Sprintf(format, args...))
+}
+
+func truncate(s string) string {
+
+	if len(s) > maxRaw {
+		return s[:maxRaw] + "..."
+	}
+	return s
+}
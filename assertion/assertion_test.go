@@ -0,0 +1,129 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package assertion_test
+
+import (
+	"testing"
+
+	"github.com/pwmorreale/rapid/assertion"
+	"github.com/stretchr/testify/assert"
+)
+
+var doc = []byte(`{
+  "items": [{"id": 1}, {"id": 2}],
+  "user": {"email": "bob@corp", "age": 42, "admin": false, "manager": null},
+  "status": "active",
+  "tags": ["happy", "little", "trees"],
+  "version": "10"
+}`)
+
+func mustNew(t *testing.T, path, op string, value any) *assertion.Assertion {
+
+	a, err := assertion.New(path, op, value)
+	assert.Nil(t, err)
+	return a
+}
+
+func TestNew(t *testing.T) {
+
+	for _, tc := range []struct {
+		path  string
+		op    string
+		value any
+		err   string
+	}{
+		{"", "==", 1, "assert: missing path"},
+		{"status", "~=", "x", `assert status ~= "x": unknown operator: "~=" (must be ==, !=, <, <=, >, >=, matches, in, contains, exists, count, or type)`},
+		{"status", ">", true, `assert status > true: value must be a number or string`},
+		{"status", "matches", 1, `assert status matches 1: value must be a regular expression`},
+		{"status", "matches", "(", "assert status matches \"(\": error parsing regexp: missing closing ): `(`"},
+		{"status", "in", "active", `assert status in "active": value must be a list`},
+		{"tags", "contains", []any{"a"}, `assert tags contains ["a"]: value must be a single value`},
+		{"status", "exists", "yes", `assert status exists "yes": value must be true or false`},
+		{"status", "type", "text", `assert status type "text": value must be string, number, boolean, null, array, or object`},
+	} {
+		_, err := assertion.New(tc.path, tc.op, tc.value)
+		assert.EqualError(t, err, tc.err)
+	}
+
+	a := mustNew(t, "status", "exists", nil)
+	assert.Equal(t, "status exists true", a.String())
+}
+
+func TestJSON(t *testing.T) {
+
+	for _, tc := range []struct {
+		path  string
+		op    string
+		value any
+	}{
+		{"items.#", ">=", 1},
+		{"items.#", "==", 2},
+		{"items.1.id", ">", 1.5},
+		{"user.email", "matches", ".*@corp"},
+		{"user.email", "==", "bob@corp"},
+		{"user.age", "<=", 42},
+		{"user.age", "!=", "42"},
+		{"user.admin", "==", false},
+		{"user.manager", "==", nil},
+		{"status", "in", []any{"active", "pending"}},
+		{"tags", "contains", "little"},
+		{"user.email", "contains", "@"},
+		{"version", ">", "1"}, // Strings are compared lexically...
+		{"deleted", "exists", false},
+		{"user", "exists", true},
+		{"tags", "type", "array"},
+		{"tags", "count", 3},
+		{"missing", "count", 0},
+		{"user", "type", "object"},
+		{"user.age", "type", "number"},
+		{"user.admin", "type", "boolean"},
+	} {
+		a := mustNew(t, tc.path, tc.op, tc.value)
+		assert.Nil(t, a.JSON(doc), a.String())
+	}
+}
+
+func TestJSONFailures(t *testing.T) {
+
+	for _, tc := range []struct {
+		path  string
+		op    string
+		value any
+		err   string
+	}{
+		{"items.#", ">=", 3, `assert items.# >= 3: got 2`},
+		{"user.age", "==", "42", `assert user.age == "42": got 42`},
+		{"version", "==", 10, `assert version == 10: got "10"`},
+		{"user.email", "matches", ".*@example", `assert user.email matches ".*@example": got "bob@corp"`},
+		{"status", "in", []any{"pending", "closed"}, `assert status in ["pending","closed"]: got "active"`},
+		{"tags", "contains", "bushes", `assert tags contains "bushes": got ["happy", "little", "trees"]`},
+		{"status", "<", 1, `assert status < 1: "active" is not a number`},
+		{"missing", "==", 1, `assert missing == 1: not found`},
+		{"status", "exists", false, `assert status exists false: found "active"`},
+		{"user.manager", "type", "object", `assert user.manager type "object": got null`},
+	} {
+		a := mustNew(t, tc.path, tc.op, tc.value)
+		assert.EqualError(t, a.JSON(doc), tc.err)
+	}
+}
+
+func TestEvaluateJSON(t *testing.T) {
+
+	assertions := []*assertion.Assertion{
+		mustNew(t, "items.#", ">=", 1),
+		mustNew(t, "status", "==", "closed"),
+		mustNew(t, "user.age", "<", 18),
+	}
+
+	// One error per failed assertion...
+	err := assertion.EvaluateJSON(assertions, doc)
+	assert.EqualError(t, err, "assert status == \"closed\": got \"active\"\nassert user.age < 18: got 42")
+
+	err = assertion.EvaluateJSON(assertions, []byte("<html/>"))
+	assert.EqualError(t, err, "assert: content is not valid JSON")
+
+	assert.Nil(t, assertion.EvaluateJSON(nil, []byte("<html/>")))
+}
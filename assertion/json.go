@@ -0,0 +1,71 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package assertion
+
+import (
+	"errors"
+
+	"github.com/tidwall/gjson"
+)
+
+// fromJSON converts a gjson result.
+func fromJSON(r gjson.Result) actual {
+
+	v := actual{
+		exists: r.Exists(),
+		text:   r.String(),
+		raw:    truncate(r.Raw),
+	}
+
+	switch r.Type {
+	case gjson.String:
+		v.kind = typeString
+	case gjson.Number:
+		v.kind = typeNumber
+		v.num = r.Num
+	case gjson.True, gjson.False:
+		v.kind = typeBoolean
+	case gjson.Null:
+		v.kind = typeNull
+	case gjson.JSON:
+		v.kind = typeObject
+		if r.IsArray() {
+			v.kind = typeArray
+			for _, item := range r.Array() {
+				v.items = append(v.items, fromJSON(item))
+			}
+			v.count = len(v.items)
+		}
+	}
+
+	return v
+}
+
+// JSON evaluates the assertion against a JSON document.
+func (a *Assertion) JSON(content []byte) error {
+
+	v := fromJSON(gjson.GetBytes(content, a.path))
+	return a.evaluate(&v)
+}
+
+// EvaluateJSON evaluates the assertions against a JSON document, with one
+// error per failed assertion.
+func EvaluateJSON(assertions []*Assertion, content []byte) error {
+
+	if len(assertions) == 0 {
+		return nil
+	}
+
+	if !gjson.ValidBytes(content) {
+		return errors.New("assert: content is not valid JSON")
+	}
+
+	var all []error
+	for _, a := range assertions {
+		all = append(all, a.JSON(content))
+	}
+
+	return errors.Join(all...)
+}
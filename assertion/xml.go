@@ -0,0 +1,96 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package assertion
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// NewXPath compiles an assertion on an XPath expression.  Namespaces map
+// the prefixes used in the expression to namespace URIs.
+func NewXPath(path, op string, value any, namespaces map[string]string) (*Assertion, error) {
+
+	a, err := New(path, op, value)
+	if err != nil {
+		return nil, err
+	}
+
+	a.xpath, err = xpath.CompileWithNS(path, namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("assert %s: %w", a.expr, err)
+	}
+
+	return a, nil
+}
+
+// fromXPath converts the result of an XPath expression.  The value of a
+// node set is the text of its first node.
+func fromXPath(result any) actual {
+
+	switch r := result.(type) {
+	case float64:
+		return actual{exists: true, kind: typeNumber, num: r, text: strconv.FormatFloat(r, 'g', -1, 64), raw: strconv.FormatFloat(r, 'g', -1, 64), count: 1}
+	case bool:
+		return actual{exists: true, kind: typeBoolean, text: strconv.FormatBool(r), raw: strconv.FormatBool(r), count: 1}
+	case string:
+		return actual{exists: true, kind: typeString, text: r, raw: truncate(strconv.Quote(r)), untyped: true, count: 1}
+	case *xpath.NodeIterator:
+		v := actual{kind: typeString, untyped: true}
+		for r.MoveNext() {
+			text := r.Current().Value()
+			v.items = append(v.items, actual{exists: true, kind: typeString, text: text, untyped: true})
+		}
+		v.count = len(v.items)
+		if v.count > 0 {
+			v.exists = true
+			v.text = v.items[0].text
+			v.raw = truncate(strconv.Quote(v.text))
+			if v.count > 1 {
+				v.raw += fmt.Sprintf(" (first of %d nodes)", v.count)
+			}
+		}
+		return v
+	}
+
+	return actual{}
+}
+
+// XML evaluates the assertion against a parsed XML document.
+func (a *Assertion) XML(doc *xmlquery.Node) error {
+
+	if a.xpath == nil {
+		return fmt.Errorf("assert %s: not an XPath assertion", a.expr)
+	}
+
+	v := fromXPath(a.xpath.Evaluate(xmlquery.CreateXPathNavigator(doc)))
+	return a.evaluate(&v)
+}
+
+// EvaluateXML evaluates the assertions against an XML document, with one
+// error per failed assertion.
+func EvaluateXML(assertions []*Assertion, content []byte) error {
+
+	if len(assertions) == 0 {
+		return nil
+	}
+
+	doc, err := xmlquery.Parse(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("assert: content is not valid XML: %w", err)
+	}
+
+	var all []error
+	for _, a := range assertions {
+		all = append(all, a.XML(doc))
+	}
+
+	return errors.Join(all...)
+}
@@ -0,0 +1,126 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package assertion_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/pwmorreale/rapid/assertion"
+	"github.com/stretchr/testify/assert"
+)
+
+var soap = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope" xmlns:p="urn:painters">
+  <soap:Body>
+    <p:PaintersResponse status="ok">
+      <p:Painter id="1"><p:Name>Bob Ross</p:Name><p:Paintings>30000</p:Paintings></p:Painter>
+      <p:Painter id="2"><p:Name>Frida Kahlo</p:Name><p:Paintings>200</p:Paintings></p:Painter>
+      <p:Active>true</p:Active>
+    </p:PaintersResponse>
+  </soap:Body>
+</soap:Envelope>`)
+
+var namespaces = map[string]string{
+	"s":  "http://www.w3.org/2003/05/soap-envelope",
+	"pp": "urn:painters",
+}
+
+func mustNewXPath(t *testing.T, path, op string, value any) *assertion.Assertion {
+
+	a, err := assertion.NewXPath(path, op, value, namespaces)
+	assert.Nil(t, err)
+	return a
+}
+
+func TestNewXPath(t *testing.T) {
+
+	_, err := assertion.NewXPath("//pp:Painter[", "exists", nil, namespaces)
+	assert.ErrorContains(t, err, `assert //pp:Painter[ exists true: `)
+
+	_, err = assertion.NewXPath("//pp:Painter", "count", "two", namespaces)
+	assert.EqualError(t, err, `assert //pp:Painter count "two": value must be a number`)
+
+	// JSON assertions cannot be evaluated against XML...
+	a, err := assertion.New("status", "exists", nil)
+	assert.Nil(t, err)
+
+	doc, err := xmlquery.Parse(bytes.NewReader(soap))
+	assert.Nil(t, err)
+	assert.EqualError(t, a.XML(doc), "assert status exists true: not an XPath assertion")
+}
+
+func TestXML(t *testing.T) {
+
+	doc, err := xmlquery.Parse(bytes.NewReader(soap))
+	assert.Nil(t, err)
+
+	for _, tc := range []struct {
+		path  string
+		op    string
+		value any
+	}{
+		{"/s:Envelope/s:Body/pp:PaintersResponse", "exists", true},
+		{"//pp:Fault", "exists", false},
+		{"//pp:Painter", "count", 2},
+		{"count(//pp:Painter)", ">=", 2},
+		{"//pp:Painter[@id='2']/pp:Name", "==", "Frida Kahlo"},
+		{"//pp:PaintersResponse/@status", "==", "ok"},
+		{"//pp:Painter[1]/pp:Paintings", ">", 1000},
+		{"sum(//pp:Paintings)", "==", 30200},
+		{"//pp:Active", "==", true},
+		{"//pp:Name", "matches", "^Bob"},
+		{"//pp:Name", "in", []any{"Bob Ross", "Mary Cassatt"}},
+		{"//pp:Name", "contains", "Ross"},
+		{"string(//pp:Painter[2]/@id)", "==", 2},
+		{"boolean(//pp:Painter)", "==", true},
+	} {
+		a := mustNewXPath(t, tc.path, tc.op, tc.value)
+		assert.Nil(t, a.XML(doc), a.String())
+	}
+}
+
+func TestXMLFailures(t *testing.T) {
+
+	doc, err := xmlquery.Parse(bytes.NewReader(soap))
+	assert.Nil(t, err)
+
+	for _, tc := range []struct {
+		path  string
+		op    string
+		value any
+		err   string
+	}{
+		{"//pp:Fault", "exists", true, `assert //pp:Fault exists true: not found`},
+		{"//pp:Painter", "count", 3, `assert //pp:Painter count 3: got 2`},
+		{"//pp:Name", "==", "Frida Kahlo", `assert //pp:Name == "Frida Kahlo": got "Bob Ross" (first of 2 nodes)`},
+		{"//pp:Painter[2]/pp:Paintings", ">", 1000, `assert //pp:Painter[2]/pp:Paintings > 1000: got "200"`},
+		{"//pp:Name", "<", 5, `assert //pp:Name < 5: "Bob Ross" (first of 2 nodes) is not a number`},
+		{"count(//pp:Painter)", "==", 3, `assert count(//pp:Painter) == 3: got 2`},
+		{"//pp:Fault", "==", "x", `assert //pp:Fault == "x": not found`},
+	} {
+		a := mustNewXPath(t, tc.path, tc.op, tc.value)
+		assert.EqualError(t, a.XML(doc), tc.err)
+	}
+}
+
+func TestEvaluateXML(t *testing.T) {
+
+	assertions := []*assertion.Assertion{
+		mustNewXPath(t, "//pp:Painter", "count", 2),
+		mustNewXPath(t, "//pp:Active", "==", false),
+		mustNewXPath(t, "//pp:Fault", "exists", true),
+	}
+
+	// One error per failed assertion...
+	err := assertion.EvaluateXML(assertions, soap)
+	assert.EqualError(t, err, "assert //pp:Active == false: got \"true\"\nassert //pp:Fault exists true: not found")
+
+	err = assertion.EvaluateXML(assertions, []byte(`{"status": "ok"}`))
+	assert.ErrorContains(t, err, "assert: content is not valid XML")
+
+	assert.Nil(t, assertion.EvaluateXML(nil, []byte(`{}`)))
+}
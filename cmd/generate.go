@@ -0,0 +1,83 @@
+//
+// Copyright © 2025 Peter W. Morreale
+//
+
+// Package cmd contains the commands
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/pwmorreale/rapid/generate"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+// generateCmd represents the generate command
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a scenario from an OpenAPI document",
+	Long:  `Generate a scenario containing one request per OpenAPI operation.  The scenario is written to the --scenario file, or to stdout if the file is "-".`,
+	RunE:  DoGenerate,
+}
+
+var openapiFile string
+var generateForce bool
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+	generateCmd.Flags().StringVar(&openapiFile, "openapi", "", `Path to the OpenAPI v3 document`)
+	generateCmd.Flags().BoolVar(&generateForce, "force", false, `Overwrite an existing scenario file`)
+	generateCmd.MarkFlagRequired("openapi")
+	generateCmd.MarkFlagFilename("openapi", "yaml", "yml", "json")
+}
+
+// DoGenerate starts the generate command.
+func DoGenerate(_ *cobra.Command, _ []string) error {
+
+	g, err := generate.New(openapiFile)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	err = enc.Encode(g.Scenario())
+	if err != nil {
+		return err
+	}
+
+	for _, w := range g.Warnings() {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+
+	blob := buf.Bytes()
+
+	if scenarioFile == "-" {
+		_, err = os.Stdout.Write(blob)
+		return err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if generateForce {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(scenarioFile, flags, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(blob)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -34,6 +34,7 @@ var runCmd = &cobra.Command{
 
 var reportFile string
 var dumpFile string
+var updateSnapshots bool
 
 func init() {
 	rootCmd.AddCommand(runCmd)
@@ -41,6 +42,8 @@ func init() {
 
 	runCmd.Flags().StringVar(&dumpFile, "dump", "", `Dump raw HTTP request/response traffic (to stdout if no file specified)`)
 	runCmd.Flags().Lookup("dump").NoOptDefVal = "stdout"
+
+	runCmd.Flags().BoolVar(&updateSnapshots, "update-snapshots", false, `Record response snapshots, replacing any existing snapshots`)
 }
 
 func initLogger() (*os.File, error) {
@@ -109,6 +112,8 @@ func RunScenario(_ *cobra.Command, _ []string) error {
 		return err
 	}
 
+	sc.UpdateSnapshots = updateSnapshots
+
 	d, err := initData(sc)
 	if err != nil {
 		return err
@@ -129,27 +134,40 @@ func RunScenario(_ *cobra.Command, _ []string) error {
 	r := rest.New(sc, d, dumpWriter)
 	s := sequence.New(r)
 
-	err = s.Run(ctx, sc)
-	if err != nil {
-		return err
+	// A failed setup or teardown is still reported.
+	runErr := s.Run(ctx, sc)
+	if runErr != nil && ctx.Err() != nil {
+		return runErr
 	}
 
+	sc.Variables = d.Snapshot()
+
 	if err := r.Push(); err != nil {
 		return err
 	}
 
 	LogResults(sc)
 
+	failed := LogThresholds(sc)
+
 	if reportFile != "" {
 		if err := writeReport(reportFile, sc); err != nil {
 			return err
 		}
 	}
 
+	if runErr != nil {
+		return runErr
+	}
+
 	if totalErrors(sc) > 0 {
 		return fmt.Errorf("scenario completed with errors")
 	}
 
+	if failed > 0 {
+		return fmt.Errorf("scenario completed with %d failed threshold(s)", failed)
+	}
+
 	return nil
 }
 
@@ -178,10 +196,15 @@ func initDump() (io.Writer, io.Closer, error) {
 	return f, f, nil
 }
 
+// totalErrors returns the errors of every request, including responses
+// that failed verification.
 func totalErrors(sc *config.Scenario) int64 {
 	var total int64
-	for i := range sc.Sequence.Requests {
-		total += sc.Sequence.Requests[i].Stats.GetErrors()
+	for _, request := range sc.AllRequests() {
+		total += request.Stats.GetErrors()
+		for _, response := range request.AllResponses() {
+			total += response.Stats.GetErrors()
+		}
 	}
 	return total
 }
@@ -189,17 +212,44 @@ func totalErrors(sc *config.Scenario) int64 {
 // LogResults prints out the statistics from the run.
 func LogResults(sc *config.Scenario) {
 
-	for i := range sc.Sequence.Requests {
-		request := &sc.Sequence.Requests[i]
+	// Iterations of named sequences...
+	users := sc.SequenceUsers()
+	for i, seq := range sc.AllSequences() {
+		if seq.Name != "" {
+			logger.Info(nil, nil, "sequence %s: users=%d %s", seq.Name, users[i], seq.Stats.String())
+		}
+	}
+
+	for _, seq := range sc.Phases() {
+		if len(seq.Requests) > 0 {
+			logger.Info(nil, nil, "%s: %s", seq.Name, seq.Stats.String())
+		}
+	}
+
+	for _, request := range sc.LoadRequests() {
 
 		str := request.Stats.String()
 		logger.Info(request, nil, "%s", str)
 
+		if request.Poll != nil {
+			logger.Info(request, nil, "poll %s", request.Polling.String())
+		}
+
+		stages := request.ThunderingHerd.Stages
+		for j := range stages {
+			str := stages[j].Stats.String()
+			logger.Info(request, nil, "%s stage=%s", str, stages[j].Name)
+		}
+
 		for j := range request.Responses {
 			response := request.Responses[j]
 
 			str := response.Stats.String()
 			logger.Info(request, response, "%s", str)
+
+			if n := response.Stats.GetDurationErrors(); n > 0 {
+				logger.Error(request, response, "%d responses outside the expected duration: min_duration=%s max_duration=%s", n, response.MinDuration, response.MaxDuration)
+			}
 		}
 
 		for j := range request.UnknownResponses {
@@ -208,5 +258,45 @@ func LogResults(sc *config.Scenario) {
 			str := response.Stats.String()
 			logger.Warn(request, response, "%s", str)
 		}
+
+		for j := range request.UndocumentedResponses {
+			response := request.UndocumentedResponses[j]
+
+			str := response.Stats.String()
+			logger.Warn(request, response, "%s", str)
+		}
 	}
 }
+
+// LogThresholds logs the outcome of each threshold.  Returns the number of
+// failed thresholds.
+func LogThresholds(sc *config.Scenario) int {
+
+	failed := logThresholds(nil, nil, report.ScenarioThresholds(sc))
+
+	for _, request := range sc.LoadRequests() {
+
+		failed += logThresholds(request, nil, report.RequestThresholds(request))
+
+		for _, response := range request.Responses {
+			failed += logThresholds(request, response, report.ResponseThresholds(response))
+		}
+	}
+
+	return failed
+}
+
+func logThresholds(request *config.Request, response *config.Response, results []report.ThresholdResult) int {
+
+	failed := 0
+	for _, r := range results {
+		if r.Passed {
+			logger.Info(request, response, "threshold passed: %s, actual: %s", r.Expression, r.Actual)
+		} else {
+			logger.Error(request, response, "threshold failed: %s, actual: %s", r.Expression, r.Actual)
+			failed++
+		}
+	}
+
+	return failed
+}
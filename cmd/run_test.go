@@ -11,6 +11,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -106,6 +107,90 @@ func TestRun(t *testing.T) {
 
 	assert.Contains(t, string(blob), "count=10")
 	assert.Contains(t, string(blob), "errors=0")
+	assert.Contains(t, string(blob), "threshold passed: count(status=200) == 10")
+	assert.Contains(t, string(blob), "threshold passed: p99 < 1s")
+	assert.NotContains(t, string(blob), "threshold failed")
+
+	os.Remove(logFilename)
+}
+
+func TestRunThresholdFailed(t *testing.T) {
+
+	logFormat = "text"
+	logLevel = "info"
+	logFilename = "/tmp/rapidRunThresholdTestFile"
+	reportFile = ""
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("serverHeader", "something from the server")
+		http.SetCookie(w, &http.Cookie{Name: "z", Value: "b"})
+		w.WriteHeader(200)
+	}))
+	defer ts.Close()
+
+	f := createTempScenario(t, ts.URL)
+	defer os.Remove(f.Name())
+
+	blob, err := os.ReadFile(f.Name())
+	assert.Nil(t, err)
+
+	blob = []byte(strings.Replace(string(blob), "p99 < 1s", "throughput > 1000000rps", 1))
+	err = os.WriteFile(f.Name(), blob, 0644)
+	assert.Nil(t, err)
+
+	scenarioFile = f.Name()
+
+	err = RunScenario(nil, []string{})
+	assert.EqualError(t, err, "scenario completed with 1 failed threshold(s)")
+
+	blob, err = os.ReadFile(logFilename)
+	assert.Nil(t, err)
+
+	assert.Contains(t, string(blob), "threshold failed: throughput > 1000000rps")
+
+	os.Remove(logFilename)
+}
+
+func TestRunSchemaFailed(t *testing.T) {
+
+	logFormat = "text"
+	logLevel = "info"
+	logFilename = "/tmp/rapidRunSchemaTestFile"
+	reportFile = ""
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("serverHeader", "something from the server")
+		w.Header().Set("Content-Type", "application/json")
+		http.SetCookie(w, &http.Cookie{Name: "z", Value: "b"})
+		w.Write([]byte(`{"name": "Bob Ross"}`))
+	}))
+	defer ts.Close()
+
+	f := createTempScenario(t, ts.URL)
+	defer os.Remove(f.Name())
+
+	blob, err := os.ReadFile(f.Name())
+	assert.Nil(t, err)
+
+	// Thresholds pass, only the schema fails...
+	s := strings.Replace(string(blob), "  - error_rate < 0.5%\n", "", 1)
+	s = strings.Replace(s, "            expected: false\n", `            expected: true
+            content_type: application/json
+            schema: '{"type": "object", "required": ["id"]}'
+`, 1)
+	err = os.WriteFile(f.Name(), []byte(s), 0644)
+	assert.Nil(t, err)
+
+	scenarioFile = f.Name()
+
+	err = RunScenario(nil, []string{})
+	assert.EqualError(t, err, "scenario completed with errors")
+
+	blob, err = os.ReadFile(logFilename)
+	assert.Nil(t, err)
+
+	assert.Contains(t, string(blob), "schema")
+	assert.NotContains(t, string(blob), "threshold failed")
 
 	os.Remove(logFilename)
 }
@@ -0,0 +1,196 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+// Package condition evaluates the conditions that control the flow of a
+// sequence.
+//
+// A condition is an expression of the form: operand operator value, eg:
+//
+//	status == 404
+//	response != success
+//	login.status == 2xx
+//	vars.RESOURCE_ID == ""
+//
+// Status and response refer to the previously executed request, or to a
+// request by name, eg: login.status.  Variables are referenced by name.
+package condition
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Operands.
+const (
+	operandStatus   = "status"
+	operandResponse = "response"
+	operandVars     = "vars."
+)
+
+var expression = regexp.MustCompile(`^\s*(\S+?)\s*(==|!=|<=|>=|<|>)\s*(.*?)\s*$`)
+
+var statusClass = regexp.MustCompile(`^[1-5]xx$`)
+
+// Outcome defines the most recent response to a request.  Status is 0,
+// and Response is empty, if no response was received.
+type Outcome struct {
+	Response string
+	Status   int
+}
+
+// Input defines the values a condition is evaluated against.
+type Input struct {
+	Outcome func(request string) Outcome // The previous request when ""
+	Lookup  func(name string) string     // Variables
+}
+
+// Condition defines a compiled condition.
+type Condition struct {
+	expr     string
+	request  string // Request name, or "" for the previous request
+	operand  string
+	variable string
+	op       string
+	value    string
+}
+
+// Parse compiles a condition.
+func Parse(expr string) (*Condition, error) {
+
+	m := expression.FindStringSubmatch(expr)
+	if m == nil {
+		return nil, fmt.Errorf("condition %q: expected: operand operator value", expr)
+	}
+
+	c := &Condition{
+		expr:  strings.TrimSpace(expr),
+		op:    m[2],
+		value: unquote(m[3]),
+	}
+
+	err := c.setOperand(m[1])
+	if err != nil {
+		return nil, fmt.Errorf("condition %q: %w", expr, err)
+	}
+
+	return c, nil
+}
+
+// unquote removes any quotes around a value, eg: "" is empty.
+func unquote(s string) string {
+
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func (c *Condition) setOperand(operand string) error {
+
+	if name, ok := strings.CutPrefix(operand, operandVars); ok {
+		if name == "" {
+			return fmt.Errorf("missing variable name")
+		}
+		c.operand = operandVars
+		c.variable = name
+		return nil
+	}
+
+	c.operand = operand
+	if i := strings.LastIndex(operand, "."); i >= 0 {
+		c.request = operand[:i]
+		c.operand = operand[i+1:]
+	}
+
+	switch c.operand {
+	case operandStatus:
+		if statusClass.MatchString(c.value) {
+			if c.op != "==" && c.op != "!=" {
+				return fmt.Errorf("status class %s requires == or !=", c.value)
+			}
+			return nil
+		}
+		_, err := strconv.Atoi(c.value)
+		if err != nil {
+			return fmt.Errorf("invalid status: %q", c.value)
+		}
+	case operandResponse:
+		if c.op != "==" && c.op != "!=" {
+			return fmt.Errorf("response requires == or !=")
+		}
+	default:
+		return fmt.Errorf("unknown operand: %s (must be status, response, or vars.NAME)", operand)
+	}
+
+	return nil
+}
+
+// String returns the condition expression.
+func (c *Condition) String() string {
+	return c.expr
+}
+
+// Request returns the name of the request referenced by the condition, or
+// "" for the previous request or a variable.
+func (c *Condition) Request() string {
+	return c.request
+}
+
+// Evaluate returns the value of the condition.
+func (c *Condition) Evaluate(in *Input) bool {
+
+	switch c.operand {
+	case operandVars:
+		return c.compare(in.Lookup(c.variable))
+	case operandResponse:
+		return c.compare(in.Outcome(c.request).Response)
+	}
+
+	status := in.Outcome(c.request).Status
+
+	if statusClass.MatchString(c.value) {
+		matched := status/100 == int(c.value[0]-'0')
+		return matched == (c.op == "==")
+	}
+
+	return c.compare(strconv.Itoa(status))
+}
+
+// compare compares numbers numerically, and other values lexically.
+func (c *Condition) compare(actual string) bool {
+
+	r := strings.Compare(actual, c.value)
+
+	a, err1 := strconv.ParseFloat(actual, 64)
+	v, err2 := strconv.ParseFloat(c.value, 64)
+	if err1 == nil && err2 == nil {
+		switch {
+		case a < v:
+			r = -1
+		case a > v:
+			r = 1
+		default:
+			r = 0
+		}
+	}
+
+	switch c.op {
+	case "==":
+		return r == 0
+	case "!=":
+		return r != 0
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	}
+
+	return false
+}
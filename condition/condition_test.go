@@ -0,0 +1,93 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package condition_test
+
+import (
+	"testing"
+
+	"github.com/pwmorreale/rapid/condition"
+	"github.com/stretchr/testify/assert"
+)
+
+var input = &condition.Input{
+	Outcome: func(request string) condition.Outcome {
+		switch request {
+		case "":
+			return condition.Outcome{Response: "missing", Status: 404}
+		case "login":
+			return condition.Outcome{Response: "success", Status: 200}
+		}
+		return condition.Outcome{}
+	},
+	Lookup: func(name string) string {
+		if name == "COUNT" {
+			return "10"
+		}
+		if name == "PAINTER" {
+			return "Bob Ross"
+		}
+		return ""
+	},
+}
+
+func TestParse(t *testing.T) {
+
+	for _, tc := range []struct {
+		expr string
+		err  string
+	}{
+		{"status", `condition "status": expected: operand operator value`},
+		{"code == 200", `condition "code == 200": unknown operand: code (must be status, response, or vars.NAME)`},
+		{"status == ok", `condition "status == ok": invalid status: "ok"`},
+		{"status < 4xx", `condition "status < 4xx": status class 4xx requires == or !=`},
+		{"login.response > success", `condition "login.response > success": response requires == or !=`},
+		{"vars. == 1", `condition "vars. == 1": missing variable name`},
+	} {
+		_, err := condition.Parse(tc.expr)
+		assert.EqualError(t, err, tc.err)
+	}
+
+	c, err := condition.Parse("  login.status == 2xx ")
+	assert.Nil(t, err)
+	assert.Equal(t, "login.status == 2xx", c.String())
+	assert.Equal(t, "login", c.Request())
+
+	c, err = condition.Parse("vars.TOKEN != ''")
+	assert.Nil(t, err)
+	assert.Equal(t, "", c.Request())
+}
+
+func TestEvaluate(t *testing.T) {
+
+	for _, tc := range []struct {
+		expr   string
+		result bool
+	}{
+		{"status == 404", true},
+		{"status==404", true},
+		{"status != 404", false},
+		{"status >= 400", true},
+		{"status < 400", false},
+		{"status == 4xx", true},
+		{"status != 4xx", false},
+		{"status == 2xx", false},
+		{"response == missing", true},
+		{`response != "missing"`, false},
+		{"login.status == 200", true},
+		{"login.response == success", true},
+		{"create.status == 0", true},
+		{"create.response == ''", true},
+		{"vars.COUNT > 9", true}, // Numerically...
+		{"vars.COUNT <= 9", false},
+		{`vars.PAINTER == "Bob Ross"`, true},
+		{"vars.PAINTER > Frida", false}, // Lexically...
+		{`vars.TOKEN == ""`, true},
+		{"vars.TOKEN ==", true},
+	} {
+		c, err := condition.Parse(tc.expr)
+		assert.Nil(t, err, tc.expr)
+		assert.Equal(t, tc.result, c.Evaluate(input), tc.expr)
+	}
+}
@@ -6,22 +6,45 @@
 package config
 
 import (
+	"fmt"
 	"log/slog"
 	"regexp"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/pwmorreale/rapid/assertion"
+	"github.com/pwmorreale/rapid/condition"
+	"github.com/pwmorreale/rapid/data"
+	"github.com/pwmorreale/rapid/openapi"
 	"github.com/pwmorreale/rapid/stats"
+	"github.com/pwmorreale/rapid/threshold"
+	"github.com/santhosh-tekuri/jsonschema/v6"
 	"github.com/spf13/viper"
 )
 
 // Various constants...
 const (
-	DefaultContentLimit  = 4096
+	DefaultContentLimit   = 4096
 	DefaultRequestTimeout = 30 * time.Second
+	DefaultSnapshotDir    = "testdata/snapshots"
+	DefaultMaxJumps       = 100
+	DefaultPollInterval   = time.Second
+	DefaultPollTimeout    = time.Minute
 
 	TypeRegex = "regex"
 
 	DefaultResponseName = "unconfigured"
+
+	PhaseSetup    = "setup"
+	PhaseTeardown = "teardown"
+
+	UndocumentedResponseName = "undocumented"
+
+	ProtocolAuto  = "auto"
+	ProtocolHTTP1 = "http1"
+	ProtocolH2    = "h2"
+	ProtocolH2C   = "h2c"
 )
 
 // Configuration defines the interface for managing the scenario
@@ -50,14 +73,68 @@ type TLSConfig struct {
 
 // Scenario defines the entire configuration.
 type Scenario struct {
-	Name           string        `mapstructure:"name"`
-	Version        string        `mapstructure:"version"`
-	Comment        string        `mapstructure:"comment"`
-	RequestTimeout time.Duration `mapstructure:"request_timeout"`
-	Sequence       Sequence      `mapstructure:"sequence"`
-	Replacements   []ReplaceData `mapstructure:"find_replace"`
-	TLS            TLSConfig     `mapstructure:"tls_configuration"`
-	Prom           PromConfig    `mapstructure:"prometheus_configuration"`
+	Name           string          `mapstructure:"name"`
+	Version        string          `mapstructure:"version"`
+	Comment        string          `mapstructure:"comment"`
+	RequestTimeout time.Duration   `mapstructure:"request_timeout"`
+	Sequence       Sequence        `mapstructure:"sequence"`
+	Replacements   []ReplaceData   `mapstructure:"find_replace"`
+	TLS            TLSConfig       `mapstructure:"tls_configuration"`
+	Prom           PromConfig      `mapstructure:"prometheus_configuration"`
+	OpenAPI        OpenAPIConfig   `mapstructure:"openapi"`
+	Transport      TransportConfig `mapstructure:"transport"`
+	Thresholds     []string        `mapstructure:"thresholds"`
+	DataSources    []DataSource    `mapstructure:"data_sources"`
+	SnapshotDir    string          `mapstructure:"snapshot_dir"`
+	Concurrency    int             `mapstructure:"concurrency"` // Users shared by weight
+	Sequences      []Sequence      `mapstructure:"sequences"`
+	Setup          []Request       `mapstructure:"setup"`
+	Teardown       []Request       `mapstructure:"teardown"`
+	PhaseStats     bool            `mapstructure:"phase_stats"` // Include setup and teardown in statistics
+	Templates      bool            `mapstructure:"templates"`   // Execute template actions in requests
+
+	ThresholdsCompiled []*threshold.Threshold
+
+	// The setup and teardown requests, as sequences...
+	SetupSequence    Sequence
+	TeardownSequence Sequence
+
+	// The variables when the scenario completed.
+	Variables data.Snapshot
+
+	// Record snapshots rather than compare with them.
+	UpdateSnapshots bool
+}
+
+// TransportConfig defines HTTP connection handling.  Zero values use the
+// Go defaults, except that keep-alives are disabled unless enabled here.
+type TransportConfig struct {
+	KeepAlives            bool          `mapstructure:"keep_alives"`
+	MaxIdleConns          int           `mapstructure:"max_idle_connections"`
+	MaxIdleConnsPerHost   int           `mapstructure:"max_idle_connections_per_host"`
+	MaxConnsPerHost       int           `mapstructure:"max_connections_per_host"`
+	IdleTimeout           time.Duration `mapstructure:"idle_timeout"`
+	DialTimeout           time.Duration `mapstructure:"dial_timeout"`
+	TLSHandshakeTimeout   time.Duration `mapstructure:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration `mapstructure:"response_header_timeout"`
+	Protocol              string        `mapstructure:"protocol"`
+}
+
+// DataSource defines a file of rows used to parameterize requests.  Each
+// column is bound as a variable, by name, when a request uses the source.
+type DataSource struct {
+	Name      string `mapstructure:"name"`
+	Path      string `mapstructure:"path"`
+	Format    string `mapstructure:"format"`     // csv or jsonl, default from the extension
+	Order     string `mapstructure:"order"`      // sequential, random, or unique
+	OnExhaust string `mapstructure:"on_exhaust"` // stop, recycle, or error
+}
+
+// OpenAPIConfig defines conformance checking against an OpenAPI v3 document.
+type OpenAPIConfig struct {
+	Path     string `mapstructure:"path"`
+	BasePath string `mapstructure:"base_path"`
+	Spec     *openapi.Context
 }
 
 // BucketConfig defines parameters for the prometheus historgram buckets.
@@ -78,19 +155,39 @@ type PromConfig struct {
 
 // Sequence contains the sequence configuration.
 type Sequence struct {
+	Name         string        `mapstructure:"name"`
+	Weight       int           `mapstructure:"weight"`      // Share of the scenario's concurrency
+	Concurrency  int           `mapstructure:"concurrency"` // Concurrent users
 	Iterations   int           `mapstructure:"iterations"`
 	Limit        time.Duration `mapstructure:"iteration_time_limit"`
 	AbortOnError bool          `mapstructure:"abort_on_error"`
 	IgnoreDups   bool          `mapstructure:"ignore_duplicate_errors"`
+	MaxJumps     int           `mapstructure:"max_jumps"` // Per iteration, to end goto loops
 	Requests     []Request     `mapstructure:"requests"`
 	Stats        stats.Statistics
 }
 
-// ExtractData defines response data extraction.
+// Prefix returns a log message prefix for a named sequence.
+func (s *Sequence) Prefix() string {
+
+	if s.Name == "" {
+		return ""
+	}
+	return "sequence " + s.Name + ": "
+}
+
+// ExtractData defines response data extraction.  If Unset, the variable
+// named by Name is removed instead.  Variables that ResetEachIteration are
+// removed at the start of every iteration.
 type ExtractData struct {
-	Type string `mapstructure:"type"`
-	Path string `mapstructure:"path"`
-	Name string `mapstructure:"match"`
+	Type               string `mapstructure:"type"`
+	Path               string `mapstructure:"path"`
+	Name               string `mapstructure:"match"`
+	Group              string `mapstructure:"group"`     // Text only: capture group number or name
+	Index              string `mapstructure:"index"`     // Text only: match index, or all
+	Separator          string `mapstructure:"separator"` // Text only: joins all matches
+	Unset              bool   `mapstructure:"unset"`
+	ResetEachIteration bool   `mapstructure:"reset_each_iteration"`
 }
 
 // HeaderData contains user defined headers for inclusion with the request.
@@ -101,12 +198,32 @@ type HeaderData struct {
 
 // ContentData defines expected response data.
 type ContentData struct {
-	Expected        bool             `mapstructure:"expected"`
-	MediaType       string           `mapstructure:"content_type"`
-	MaxSize         int              `mapstructure:"max_content"`
-	Contains        []string         `mapstructure:"contains"`
+	Expected         bool     `mapstructure:"expected"`
+	MediaType        string   `mapstructure:"content_type"`
+	MaxSize          int      `mapstructure:"max_content"`
+	Contains         []string `mapstructure:"contains"`
 	ContainsCompiled []*regexp.Regexp
-	Extract         []ExtractData    `mapstructure:"extract"`
+	Schema           string `mapstructure:"schema"`
+	SchemaCompiled   *jsonschema.Schema
+	Extract          []ExtractData `mapstructure:"extract"`
+	Assert           []AssertData  `mapstructure:"assert"`
+	AssertCompiled   []*assertion.Assertion
+	XPathAssert      []AssertData    `mapstructure:"xpath_assert"`
+	Namespaces       []NamespaceData `mapstructure:"namespaces"`
+	XPathCompiled    []*assertion.Assertion
+}
+
+// NamespaceData maps a prefix used in XPath expressions to a namespace URI.
+type NamespaceData struct {
+	Prefix string `mapstructure:"prefix"`
+	URI    string `mapstructure:"uri"`
+}
+
+// AssertData defines an assertion on a value in the response content.
+type AssertData struct {
+	Path  string `mapstructure:"path"`
+	Op    string `mapstructure:"op"`
+	Value any    `mapstructure:"value"`
 }
 
 // CookieData defines a cookie string
@@ -116,12 +233,59 @@ type CookieData struct {
 
 // Response defines a REST response
 type Response struct {
-	Name       string       `mapstructure:"name"`
+	Name        string          `mapstructure:"name"`
+	StatusCode  int             `mapstructure:"status_code"`
+	Protocol    string          `mapstructure:"protocol"`
+	Headers     []HeaderData    `mapstructure:"headers"`
+	Cookies     []CookieData    `mapstructure:"cookies"`
+	Content     ContentData     `mapstructure:"content"`
+	Thresholds  []string        `mapstructure:"thresholds"`
+	Snapshot    *SnapshotConfig `mapstructure:"snapshot"`
+	MaxDuration time.Duration   `mapstructure:"max_duration"` // Slowest acceptable response, 0 is unlimited
+	MinDuration time.Duration   `mapstructure:"min_duration"` // Fastest acceptable response, eg: a tarpit
+	Stats       stats.Statistics
+
+	ThresholdsCompiled []*threshold.Threshold
+
+	// Differences from the snapshot, if any...
+	SnapshotDiffs []string
+}
+
+// SnapshotConfig defines comparison of the response content with a golden
+// file.  The file is named for the request and response unless Name is set.
+type SnapshotConfig struct {
+	Name   string   `mapstructure:"name"`
+	Ignore []string `mapstructure:"ignore"` // JSON paths of volatile fields
+}
+
+// PollConfig defines re-sending a request until a condition is met, eg: an
+// asynchronous job completes.  Polling ends after the timeout or max polls,
+// whichever is first.
+type PollConfig struct {
+	Interval time.Duration `mapstructure:"interval"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	MaxPolls int           `mapstructure:"max_polls"`
+	Until    PollUntil     `mapstructure:"until"`
+}
+
+// PollUntil defines the condition that ends polling.  All of the set
+// fields must match.
+type PollUntil struct {
 	StatusCode int          `mapstructure:"status_code"`
 	Headers    []HeaderData `mapstructure:"headers"`
-	Cookies    []CookieData `mapstructure:"cookies"`
-	Content    ContentData  `mapstructure:"content"`
-	Stats      stats.Statistics
+	Assert     []AssertData `mapstructure:"assert"` // JSON content
+
+	AssertCompiled []*assertion.Assertion
+}
+
+// ForEachConfig defines executing a request once for each element of an
+// array, eg: the ids in a list of orders.  The array is selected from the
+// content held by a variable, typically extracted by an earlier request.
+type ForEachConfig struct {
+	Type     string `mapstructure:"type"`     // json or xml
+	Variable string `mapstructure:"variable"` // Holds the content
+	Path     string `mapstructure:"path"`     // GJSON path or XPath of the elements
+	Match    string `mapstructure:"match"`    // Variable bound to each element
 }
 
 // RetryConfig defines retry behavior for a request.
@@ -132,32 +296,190 @@ type RetryConfig struct {
 	StatusCodes []int         `mapstructure:"status_codes"`
 }
 
-// Stampede defines a thundering herd configuration
+// Stage defines one step of a staged thundering herd.  A stage runs for
+// Duration at either a fixed concurrency, or at a fixed Rate.
+type Stage struct {
+	Name     string        `mapstructure:"name"`
+	Size     int           `mapstructure:"concurrent_requests"`
+	Rate     float64       `mapstructure:"rate"`
+	Duration time.Duration `mapstructure:"duration"`
+	Stats    stats.Statistics
+}
+
+// Stampede defines a thundering herd configuration.  When Rate is set,
+// requests are sent on a fixed schedule (open-loop) rather than as workers
+// become free, and Delay is ignored.  When Stages are set, each stage is
+// executed in turn and Max, TimeLimit, and Ramp are ignored.
 type Stampede struct {
-	Max       int           `mapstructure:"maximum_requests"`
-	Size      int           `mapstructure:"concurrent_requests"`
-	TimeLimit time.Duration `mapstructure:"time_limit"`
-	Delay     time.Duration `mapstructure:"delay"`
+	Max            int           `mapstructure:"maximum_requests"`
+	Size           int           `mapstructure:"concurrent_requests"`
+	TimeLimit      time.Duration `mapstructure:"time_limit"`
+	Delay          time.Duration `mapstructure:"delay"`
+	Rate           float64       `mapstructure:"rate"`
+	Ramp           time.Duration `mapstructure:"ramp"`
+	MaxOutstanding int           `mapstructure:"max_outstanding"`
+	Stages         []Stage       `mapstructure:"stages"`
 }
 
 // Request defines the a request/response
 type Request struct {
-	Name             string       `mapstructure:"name"`
-	OnceOnly         bool         `mapstructure:"once_only"`
-	Retry            RetryConfig  `mapstructure:"retry"`
-	ThunderingHerd   Stampede     `mapstructure:"thundering_herd"`
-	Method           string       `mapstructure:"method"`
-	URL              string       `mapstructure:"url"`
-	ExtraHeaders     []HeaderData `mapstructure:"extra_headers"`
-	Cookies          []CookieData `mapstructure:"cookies"`
-	Content          string       `mapstructure:"content"`
-	ContentType      string       `mapstructure:"content_type"`
-	Responses        []*Response  `mapstructure:"responses"`
+	Name             string           `mapstructure:"name"`
+	OnceOnly         bool             `mapstructure:"once_only"`
+	Retry            RetryConfig      `mapstructure:"retry"`
+	Poll             *PollConfig      `mapstructure:"poll"`
+	ForEach          *ForEachConfig   `mapstructure:"foreach"`
+	ThunderingHerd   Stampede         `mapstructure:"thundering_herd"`
+	Transport        *TransportConfig `mapstructure:"transport"` // Overrides the scenario transport...
+	DataSource       string           `mapstructure:"data_source"`
+	When             string           `mapstructure:"when"`       // Execute only if true...
+	SkipIf           string           `mapstructure:"skip_if"`    // Skip if true...
+	Goto             string           `mapstructure:"goto"`       // Request to continue with...
+	OnFailure        string           `mapstructure:"on_failure"` // Request to continue with after an error...
+	Method           string           `mapstructure:"method"`
+	URL              string           `mapstructure:"url"`
+	ExtraHeaders     []HeaderData     `mapstructure:"extra_headers"`
+	Cookies          []CookieData     `mapstructure:"cookies"`
+	Content          string           `mapstructure:"content"`
+	ContentType      string           `mapstructure:"content_type"`
+	Thresholds       []string         `mapstructure:"thresholds"`
+	Responses        []*Response      `mapstructure:"responses"`
 	Stats            stats.Statistics
 	UnknownResponses []*Response // Unconfigured responses received...
 
+	// Execution times of errors without a response...
+	Unanswered stats.Statistics
+
+	// Responses with status codes not documented in the OpenAPI spec...
+	UndocumentedResponses []*Response
+
 	// Did we execute this one?
 	Executed bool
+
+	ThresholdsCompiled []*threshold.Threshold
+
+	// The rows of the data source...
+	Feeder *data.Feeder
+
+	WhenCompiled   *condition.Condition
+	SkipIfCompiled *condition.Condition
+
+	// Total wait, and polls, until the poll condition was met...
+	Polling stats.Polling
+
+	// Name of the sequence the request belongs to, if named...
+	Sequence string
+}
+
+// AllResponses returns the configured, unconfigured and undocumented
+// responses of the request.
+func (r *Request) AllResponses() []*Response {
+
+	all := append([]*Response{}, r.Responses...)
+	all = append(all, r.UnknownResponses...)
+	return append(all, r.UndocumentedResponses...)
+}
+
+// AllSequences returns the scenario's sequences, or its single sequence.
+func (s *Scenario) AllSequences() []*Sequence {
+
+	if len(s.Sequences) == 0 {
+		return []*Sequence{&s.Sequence}
+	}
+
+	all := make([]*Sequence, len(s.Sequences))
+	for i := range s.Sequences {
+		all[i] = &s.Sequences[i]
+	}
+	return all
+}
+
+// Phases returns the setup and teardown sequences.
+func (s *Scenario) Phases() []*Sequence {
+	return []*Sequence{&s.SetupSequence, &s.TeardownSequence}
+}
+
+// AllRequests returns the requests of every sequence, and of the setup and
+// teardown phases.
+func (s *Scenario) AllRequests() []*Request {
+	return requests(append(s.AllSequences(), s.Phases()...))
+}
+
+// LoadRequests returns the requests included in the statistics: those of
+// every sequence, and of the setup and teardown phases if phase_stats is
+// set.
+func (s *Scenario) LoadRequests() []*Request {
+
+	if s.PhaseStats {
+		return s.AllRequests()
+	}
+	return requests(s.AllSequences())
+}
+
+func requests(sequences []*Sequence) []*Request {
+
+	var all []*Request
+	for _, seq := range sequences {
+		for i := range seq.Requests {
+			all = append(all, &seq.Requests[i])
+		}
+	}
+	return all
+}
+
+// SequenceUsers returns the number of concurrent users of each sequence,
+// in order.  A sequence's concurrency takes precedence over its weight.
+// Weights share the scenario's concurrency, by default their sum, and
+// unweighted sequences have one user.
+func (s *Scenario) SequenceUsers() []int {
+
+	sequences := s.AllSequences()
+	users := make([]int, len(sequences))
+
+	weights := 0
+	for _, seq := range sequences {
+		if seq.Concurrency <= 0 && seq.Weight > 0 {
+			weights += seq.Weight
+		}
+	}
+
+	total := s.Concurrency
+	if total <= 0 {
+		total = weights
+	}
+
+	// Weighted shares are rounded by largest remainder, so that they
+	// add up to the total...
+	var weighted []int
+	allocated := 0
+	for i, seq := range sequences {
+		switch {
+		case seq.Concurrency > 0:
+			users[i] = seq.Concurrency
+		case seq.Weight > 0:
+			users[i] = total * seq.Weight / weights
+			allocated += users[i]
+			weighted = append(weighted, i)
+		default:
+			users[i] = 1
+		}
+	}
+
+	remainder := func(i int) int {
+		return total * sequences[i].Weight % weights
+	}
+	sort.SliceStable(weighted, func(a, b int) bool {
+		return remainder(weighted[a]) > remainder(weighted[b])
+	})
+
+	for _, i := range weighted {
+		if allocated >= total {
+			break
+		}
+		users[i]++
+		allocated++
+	}
+
+	return users
 }
 
 // New creates a new context instance
@@ -166,19 +488,94 @@ func New() *Context {
 }
 
 func setDefaultStampedeMax(s *Scenario) {
-	for i := range s.Sequence.Requests {
-		if s.Sequence.Requests[i].ThunderingHerd.Max == 0 {
-			s.Sequence.Requests[i].ThunderingHerd.Max = 1
+	for _, request := range s.AllRequests() {
+		if request.ThunderingHerd.Max == 0 {
+			request.ThunderingHerd.Max = 1
+		}
+	}
+}
+
+// Polls are limited to a minute unless otherwise set.
+func setDefaultPoll(s *Scenario) {
+	for _, request := range s.AllRequests() {
+		p := request.Poll
+		if p == nil {
+			continue
+		}
+		if p.Interval == 0 {
+			p.Interval = DefaultPollInterval
+		}
+		if p.Timeout == 0 && p.MaxPolls == 0 {
+			p.Timeout = DefaultPollTimeout
+		}
+	}
+}
+
+// Foreach content is JSON unless otherwise set.
+func setDefaultForEach(s *Scenario) {
+	for _, request := range s.AllRequests() {
+		f := request.ForEach
+		if f != nil && f.Type == "" {
+			f.Type = "json"
+		}
+	}
+}
+
+// Unnamed sequences are named by position, eg: sequence-1.  The setup and
+// teardown requests are executed as sequences of one iteration, and setup
+// ends on the first error.  Each request records the name of its sequence.
+func setSequences(s *Scenario) error {
+
+	if len(s.Sequences) > 0 && (len(s.Sequence.Requests) > 0 || s.Sequence.Iterations > 0) {
+		return fmt.Errorf("sequence and sequences are mutually exclusive")
+	}
+
+	for i := range s.Sequences {
+		if s.Sequences[i].Name == "" {
+			s.Sequences[i].Name = fmt.Sprintf("sequence-%d", i+1)
+		}
+	}
+
+	s.SetupSequence = Sequence{
+		Name:         PhaseSetup,
+		Iterations:   1,
+		AbortOnError: true,
+		Requests:     s.Setup,
+	}
+
+	s.TeardownSequence = Sequence{
+		Name:       PhaseTeardown,
+		Iterations: 1,
+		Requests:   s.Teardown,
+	}
+
+	for _, seq := range append(s.AllSequences(), s.Phases()...) {
+		for n := range seq.Requests {
+			seq.Requests[n].Sequence = seq.Name
+		}
+	}
+
+	return nil
+}
+
+// Unnamed stages are named by position, eg: stage-1
+func setDefaultStageNames(s *Scenario) {
+	for _, request := range s.AllRequests() {
+		stages := request.ThunderingHerd.Stages
+		for n := range stages {
+			if stages[n].Name == "" {
+				stages[n].Name = fmt.Sprintf("stage-%d", n+1)
+			}
 		}
 	}
 }
 
 func setDefaultContentMaxSize(s *Scenario) {
 
-	for i := range s.Sequence.Requests {
-		for n := range s.Sequence.Requests[i].Responses {
-			if s.Sequence.Requests[i].Responses[n].Content.MaxSize == 0 {
-				s.Sequence.Requests[i].Responses[n].Content.MaxSize = DefaultContentLimit
+	for _, request := range s.AllRequests() {
+		for _, response := range request.Responses {
+			if response.Content.MaxSize == 0 {
+				response.Content.MaxSize = DefaultContentLimit
 			}
 		}
 	}
@@ -202,24 +599,70 @@ func (c *Context) ParseFile(flnm string) (*Scenario, error) {
 		return nil, err
 	}
 
+	if err := setSequences(&s); err != nil {
+		return nil, err
+	}
+
 	setDefaultContentMaxSize(&s)
 	setDefaultStampedeMax(&s)
+	setDefaultStageNames(&s)
 
 	if err := compileContainsRegexes(&s); err != nil {
 		return nil, err
 	}
 
+	if err := compileSchemas(&s); err != nil {
+		return nil, err
+	}
+
+	if err := compileAssertions(&s); err != nil {
+		return nil, err
+	}
+
+	if err := compileThresholds(&s); err != nil {
+		return nil, err
+	}
+
+	if err := compileDataSources(&s); err != nil {
+		return nil, err
+	}
+
+	if err := compileFlow(&s); err != nil {
+		return nil, err
+	}
+
+	if s.OpenAPI.Path != "" {
+		spec, err := openapi.New(s.OpenAPI.Path, s.OpenAPI.BasePath)
+		if err != nil {
+			return nil, fmt.Errorf("openapi: %s: %w", s.OpenAPI.Path, err)
+		}
+		s.OpenAPI.Spec = spec
+	}
+
 	if s.RequestTimeout == 0 {
 		s.RequestTimeout = DefaultRequestTimeout
 	}
 
+	if s.SnapshotDir == "" {
+		s.SnapshotDir = DefaultSnapshotDir
+	}
+
+	for _, seq := range append(s.AllSequences(), s.Phases()...) {
+		if seq.MaxJumps == 0 {
+			seq.MaxJumps = DefaultMaxJumps
+		}
+	}
+
+	setDefaultPoll(&s)
+	setDefaultForEach(&s)
+
 	return &s, nil
 }
 
 func compileContainsRegexes(s *Scenario) error {
-	for i := range s.Sequence.Requests {
-		for n := range s.Sequence.Requests[i].Responses {
-			content := &s.Sequence.Requests[i].Responses[n].Content
+	for _, request := range s.AllRequests() {
+		for _, response := range request.Responses {
+			content := &response.Content
 			for _, pattern := range content.Contains {
 				if pattern == "" {
 					continue
@@ -235,16 +678,266 @@ func compileContainsRegexes(s *Scenario) error {
 	return nil
 }
 
+// CompileSchema compiles a JSON Schema (draft 2020-12 unless the schema
+// declares otherwise).  The source is either an inline JSON document or
+// a path to a JSON schema file.
+func CompileSchema(source string) (*jsonschema.Schema, error) {
+
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+
+	location := source
+	if strings.HasPrefix(strings.TrimSpace(source), "{") {
+		doc, err := jsonschema.UnmarshalJSON(strings.NewReader(source))
+		if err != nil {
+			return nil, err
+		}
+
+		location = "inline-schema.json"
+		if err := c.AddResource(location, doc); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.Compile(location)
+}
+
+func compileSchemas(s *Scenario) error {
+	for _, request := range s.AllRequests() {
+		for n := range request.Responses {
+			content := &request.Responses[n].Content
+			if content.Schema == "" {
+				continue
+			}
+			sch, err := CompileSchema(content.Schema)
+			if err != nil {
+				return fmt.Errorf("request %s, response %s: schema: %w", request.Name, request.Responses[n].Name, err)
+			}
+			content.SchemaCompiled = sch
+		}
+	}
+	return nil
+}
+
+// compileDataSources loads the data sources and attaches them to the
+// requests that use them.
+func compileDataSources(s *Scenario) error {
+
+	feeders := make(map[string]*data.Feeder)
+	for _, ds := range s.DataSources {
+		if _, ok := feeders[ds.Name]; ok {
+			return fmt.Errorf("data source %s: duplicate name", ds.Name)
+		}
+		f, err := data.NewFeeder(ds.Name, ds.Path, ds.Format, ds.Order, ds.OnExhaust)
+		if err != nil {
+			return fmt.Errorf("data source %s: %w", ds.Name, err)
+		}
+		feeders[ds.Name] = f
+	}
+
+	for _, request := range s.AllRequests() {
+		if request.DataSource == "" {
+			continue
+		}
+		f, ok := feeders[request.DataSource]
+		if !ok {
+			return fmt.Errorf("request %s: unknown data_source: %s", request.Name, request.DataSource)
+		}
+		request.Feeder = f
+	}
+
+	return nil
+}
+
+// compileFlow compiles the request conditions, and verifies the requests
+// they, and jumps, refer to.  Requests refer to others in their sequence.
+func compileFlow(s *Scenario) error {
+
+	for _, seq := range append(s.AllSequences(), s.Phases()...) {
+		err := compileSequenceFlow(seq)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func compileSequenceFlow(seq *Sequence) error {
+
+	names := make(map[string]bool)
+	for i := range seq.Requests {
+		names[seq.Requests[i].Name] = true
+	}
+
+	compile := func(request *Request, field, expr string) (*condition.Condition, error) {
+		if expr == "" {
+			return nil, nil
+		}
+		c, err := condition.Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("request %s: %s: %w", request.Name, field, err)
+		}
+		if c.Request() != "" && !names[c.Request()] {
+			return nil, fmt.Errorf("request %s: %s: unknown request: %s", request.Name, field, c.Request())
+		}
+		return c, nil
+	}
+
+	var err error
+	for i := range seq.Requests {
+		request := &seq.Requests[i]
+
+		request.WhenCompiled, err = compile(request, "when", request.When)
+		if err != nil {
+			return err
+		}
+
+		request.SkipIfCompiled, err = compile(request, "skip_if", request.SkipIf)
+		if err != nil {
+			return err
+		}
+
+		if request.Goto != "" && !names[request.Goto] {
+			return fmt.Errorf("request %s: unknown goto request: %s", request.Name, request.Goto)
+		}
+
+		if request.OnFailure != "" && !names[request.OnFailure] {
+			return fmt.Errorf("request %s: unknown on_failure request: %s", request.Name, request.OnFailure)
+		}
+	}
+
+	return nil
+}
+
+func compileAssertions(s *Scenario) error {
+	for _, request := range s.AllRequests() {
+		for _, response := range request.Responses {
+			content := &response.Content
+			for _, ad := range content.Assert {
+				a, err := assertion.New(ad.Path, ad.Op, ad.Value)
+				if err != nil {
+					return fmt.Errorf("request %s, response %s: %w", request.Name, response.Name, err)
+				}
+				content.AssertCompiled = append(content.AssertCompiled, a)
+			}
+
+			namespaces, err := namespaceMap(content.Namespaces)
+			if err != nil {
+				return fmt.Errorf("request %s, response %s: %w", request.Name, response.Name, err)
+			}
+
+			for _, ad := range content.XPathAssert {
+				a, err := assertion.NewXPath(ad.Path, ad.Op, ad.Value, namespaces)
+				if err != nil {
+					return fmt.Errorf("request %s, response %s: %w", request.Name, response.Name, err)
+				}
+				content.XPathCompiled = append(content.XPathCompiled, a)
+			}
+		}
+
+		if request.Poll == nil {
+			continue
+		}
+
+		until := &request.Poll.Until
+		for _, ad := range until.Assert {
+			a, err := assertion.New(ad.Path, ad.Op, ad.Value)
+			if err != nil {
+				return fmt.Errorf("request %s, poll: %w", request.Name, err)
+			}
+			until.AssertCompiled = append(until.AssertCompiled, a)
+		}
+	}
+	return nil
+}
+
+func namespaceMap(all []NamespaceData) (map[string]string, error) {
+
+	namespaces := make(map[string]string, len(all))
+	for _, ns := range all {
+		if ns.Prefix == "" || ns.URI == "" {
+			return nil, fmt.Errorf("namespace: prefix and uri must be defined")
+		}
+		if _, ok := namespaces[ns.Prefix]; ok {
+			return nil, fmt.Errorf("namespace: duplicate prefix: %s", ns.Prefix)
+		}
+		namespaces[ns.Prefix] = ns.URI
+	}
+
+	return namespaces, nil
+}
+
 // LogValue is used by the slog logger to record elements of the http request.
 func (rq *Request) LogValue() slog.Value {
+
+	if rq.Sequence != "" {
+		return slog.GroupValue(
+			slog.String("sequence", rq.Sequence),
+			slog.String("name", rq.Name),
+			slog.String("method", rq.Method))
+	}
+
 	return slog.GroupValue(
 		slog.String("name", rq.Name),
 		slog.String("method", rq.Method))
 }
 
+// SnapshotName returns the name of the response's snapshot, by default
+// the request and response names.
+func (rp *Response) SnapshotName(request *Request) string {
+
+	if rp.Snapshot != nil && rp.Snapshot.Name != "" {
+		return rp.Snapshot.Name
+	}
+	return request.Name + "_" + rp.Name
+}
+
 // LogValue is used by the slog logger to record elements of the http response.
 func (rp *Response) LogValue() slog.Value {
 	return slog.GroupValue(
 		slog.String("name", rp.Name),
 		slog.Int("status", rp.StatusCode))
 }
+
+// CompileThresholds compiles threshold expressions.
+func CompileThresholds(exprs []string) ([]*threshold.Threshold, error) {
+
+	var all []*threshold.Threshold
+	for _, expr := range exprs {
+		t, err := threshold.Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, t)
+	}
+
+	return all, nil
+}
+
+func compileThresholds(s *Scenario) error {
+
+	var err error
+
+	s.ThresholdsCompiled, err = CompileThresholds(s.Thresholds)
+	if err != nil {
+		return fmt.Errorf("scenario: %w", err)
+	}
+
+	for _, request := range s.AllRequests() {
+
+		request.ThresholdsCompiled, err = CompileThresholds(request.Thresholds)
+		if err != nil {
+			return fmt.Errorf("request %s: %w", request.Name, err)
+		}
+
+		for _, response := range request.Responses {
+			response.ThresholdsCompiled, err = CompileThresholds(response.Thresholds)
+			if err != nil {
+				return fmt.Errorf("request %s, response %s: %w", request.Name, response.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
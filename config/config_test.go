@@ -5,10 +5,14 @@
 package config_test
 
 import (
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/pwmorreale/rapid/config"
 	"github.com/stretchr/testify/assert"
+	"go.yaml.in/yaml/v3"
 )
 
 func TestReadInConfig(t *testing.T) {
@@ -49,6 +53,9 @@ func TestReadInConfig(t *testing.T) {
 	assert.Equal(t, 0, len(s.Sequence.Requests[0].Responses[1].Cookies))
 
 	assert.Equal(t, s.Sequence.Requests[1].Name, "request2")
+
+	assert.NotNil(t, s.Sequence.Requests[0].Responses[0].Content.SchemaCompiled)
+	assert.Nil(t, s.Sequence.Requests[0].Responses[1].Content.SchemaCompiled)
 }
 
 func TestBadSyntax(t *testing.T) {
@@ -69,3 +76,378 @@ func TestExtraFields(t *testing.T) {
 	assert.Contains(t, err.Error(), "'sequence' has invalid keys: not_a_valid_field")
 	assert.Contains(t, err.Error(), "has invalid keys: data")
 }
+
+func TestBadSchema(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/bad-schema.yaml")
+	assert.Nil(t, s)
+	assert.Contains(t, err.Error(), "request request1, response success: schema:")
+}
+
+func TestBadThreshold(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/bad-threshold.yaml")
+	assert.Nil(t, s)
+	assert.Contains(t, err.Error(), `request request1, response success: threshold "latency < 1s": unknown metric: latency`)
+}
+
+func TestThresholds(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/run-test.yaml")
+	assert.Nil(t, err)
+
+	assert.Equal(t, 2, len(s.ThresholdsCompiled))
+	assert.Equal(t, "error_rate < 0.5%", s.ThresholdsCompiled[1].String())
+	assert.Equal(t, 1, len(s.Sequence.Requests[0].ThresholdsCompiled))
+	assert.Equal(t, 1, len(s.Sequence.Requests[0].Responses[0].ThresholdsCompiled))
+}
+
+func TestCompileSchemaInline(t *testing.T) {
+
+	sch, err := config.CompileSchema(`{"type": "object", "required": ["id"]}`)
+	assert.Nil(t, err)
+	assert.NotNil(t, sch)
+
+	sch, err = config.CompileSchema(`{"type": `)
+	assert.Nil(t, sch)
+	assert.NotNil(t, err)
+
+	sch, err = config.CompileSchema("../testdata/schemas/no-such-schema.json")
+	assert.Nil(t, sch)
+	assert.NotNil(t, err)
+}
+
+func TestOpenAPI(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/openapi.yaml")
+	assert.Nil(t, err)
+	assert.NotNil(t, s.OpenAPI.Spec)
+
+	s, err = c.ParseFile("../testdata/configs/test_scenario.yaml")
+	assert.Nil(t, err)
+	assert.Nil(t, s.OpenAPI.Spec)
+}
+
+func TestStages(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/stages.yaml")
+	assert.Nil(t, err)
+
+	stages := s.Sequence.Requests[0].ThunderingHerd.Stages
+	assert.Equal(t, 3, len(stages))
+	assert.Equal(t, "ramp-up", stages[0].Name)
+	assert.Equal(t, 4, stages[0].Size)
+	assert.Equal(t, "stage-2", stages[1].Name)
+	assert.Equal(t, "steady", stages[2].Name)
+	assert.Equal(t, float64(100), stages[2].Rate)
+	assert.Equal(t, 50*time.Millisecond, stages[2].Duration)
+}
+
+func TestTransport(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/transport.yaml")
+	assert.Nil(t, err)
+
+	assert.True(t, s.Transport.KeepAlives)
+	assert.Equal(t, 100, s.Transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 90*time.Second, s.Transport.IdleTimeout)
+	assert.Equal(t, 5*time.Second, s.Transport.DialTimeout)
+	assert.Equal(t, config.ProtocolH2, s.Transport.Protocol)
+	assert.Equal(t, config.ProtocolH2, s.Sequence.Requests[0].Responses[0].Protocol)
+
+	assert.Nil(t, s.Sequence.Requests[0].Transport)
+	assert.NotNil(t, s.Sequence.Requests[1].Transport)
+	assert.False(t, s.Sequence.Requests[1].Transport.KeepAlives)
+	assert.Equal(t, 2*time.Second, s.Sequence.Requests[1].Transport.ResponseHeaderTimeout)
+}
+
+func TestDataSources(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/data-sources.yaml")
+	assert.Nil(t, err)
+
+	assert.Len(t, s.DataSources, 2)
+	assert.Equal(t, "recycle", s.DataSources[0].OnExhaust)
+
+	painters := s.Sequence.Requests[0].Feeder
+	assert.NotNil(t, painters)
+	assert.Equal(t, "painters", painters.Name())
+	assert.Equal(t, 3, painters.Len())
+
+	accounts := s.Sequence.Requests[1].Feeder
+	assert.NotNil(t, accounts)
+	assert.Equal(t, 3, accounts.Len())
+
+	s, err = c.ParseFile("../testdata/configs/bad-data-source.yaml")
+	assert.Nil(t, s)
+	assert.EqualError(t, err, "request painter: unknown data_source: sculptors")
+}
+
+func TestAssertions(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/assert.yaml")
+	assert.Nil(t, err)
+
+	content := s.Sequence.Requests[0].Responses[0].Content
+	assert.Len(t, content.Assert, 5)
+	assert.Len(t, content.AssertCompiled, 5)
+	assert.Equal(t, `items.# >= 1`, content.AssertCompiled[0].String())
+	assert.Equal(t, `status in ["active","pending"]`, content.AssertCompiled[2].String())
+
+	content = s.Sequence.Requests[1].Responses[0].Content
+	assert.Len(t, content.Namespaces, 2)
+	assert.Len(t, content.XPathCompiled, 3)
+	assert.Equal(t, `//p:Painter count 2`, content.XPathCompiled[1].String())
+
+	// Zero values are kept when written...
+	blob, err := yaml.Marshal(s)
+	assert.Nil(t, err)
+	assert.Contains(t, string(blob), "value: 0")
+	assert.Contains(t, string(blob), "value: false")
+
+	s, err = c.ParseFile("../testdata/configs/bad-assert.yaml")
+	assert.Nil(t, s)
+	assert.ErrorContains(t, err, `request painters, response success: assert status one_of ["active","pending"]: unknown operator: "one_of"`)
+}
+
+func TestSnapshots(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/snapshot.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, "testdata/snapshots", s.SnapshotDir)
+
+	request := &s.Sequence.Requests[0]
+	response := request.Responses[0]
+	assert.NotNil(t, response.Snapshot)
+	assert.Equal(t, []string{"updated_at", "items.#.id"}, response.Snapshot.Ignore)
+	assert.Equal(t, "painters_success", response.SnapshotName(request))
+
+	request = &s.Sequence.Requests[1]
+	assert.Equal(t, "painter-1", request.Responses[0].SnapshotName(request))
+
+	s, err = c.ParseFile("../testdata/configs/assert.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, config.DefaultSnapshotDir, s.SnapshotDir)
+	assert.Nil(t, s.Sequence.Requests[0].Responses[0].Snapshot)
+}
+
+func TestResponseDuration(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/duration.yaml")
+	assert.Nil(t, err)
+
+	responses := s.Sequence.Requests[0].Responses
+	assert.Equal(t, 200*time.Millisecond, responses[0].MaxDuration)
+	assert.Equal(t, time.Duration(0), responses[0].MinDuration)
+	assert.Equal(t, time.Second, responses[1].MinDuration)
+	assert.Equal(t, 5*time.Second, responses[1].MaxDuration)
+}
+
+func TestFlow(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/flow.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, 10, s.Sequence.MaxJumps)
+
+	requests := s.Sequence.Requests
+	assert.Nil(t, requests[0].WhenCompiled)
+	assert.Equal(t, "find-painter.status == 200", requests[2].SkipIfCompiled.String())
+	assert.Equal(t, "find-painter", requests[2].SkipIfCompiled.Request())
+	assert.Equal(t, "login", requests[3].OnFailure)
+	assert.Equal(t, `vars.CANVAS != ""`, requests[4].WhenCompiled.String())
+	assert.Equal(t, "get-painter", requests[4].Goto)
+
+	s, err = c.ParseFile("../testdata/configs/assert.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, config.DefaultMaxJumps, s.Sequence.MaxJumps)
+
+	s, err = c.ParseFile("../testdata/configs/bad-flow.yaml")
+	assert.Nil(t, s)
+	assert.EqualError(t, err, "request login: unknown goto request: logon")
+}
+
+func TestForEach(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/foreach.yaml")
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.Sequence.Requests[0].ForEach)
+
+	f := s.Sequence.Requests[1].ForEach
+	assert.Equal(t, "json", f.Type)
+	assert.Equal(t, "ORDERS", f.Variable)
+	assert.Equal(t, "#.id", f.Path)
+	assert.Equal(t, "ORDER_ID", f.Match)
+
+	f = s.Sequence.Requests[2].ForEach
+	assert.Equal(t, "xml", f.Type)
+	assert.Equal(t, "//invoice/@id", f.Path)
+}
+
+func TestSequences(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/sequences.yaml")
+	assert.Nil(t, err)
+
+	sequences := s.AllSequences()
+	assert.Len(t, sequences, 4)
+	assert.Equal(t, &s.Sequences[0], sequences[0])
+	assert.Equal(t, "sequence-3", sequences[2].Name)
+	assert.Equal(t, "sequence sequence-3: ", sequences[2].Prefix())
+	assert.Equal(t, config.DefaultMaxJumps, sequences[2].MaxJumps)
+
+	requests := s.AllRequests()
+	assert.Len(t, requests, 6)
+	assert.Equal(t, "browse", requests[0].Sequence)
+	assert.Equal(t, "sequence-3", requests[3].Sequence)
+	assert.Equal(t, "admin", requests[5].Sequence)
+
+	// Defaults apply to every sequence...
+	assert.Equal(t, 1, requests[5].ThunderingHerd.Max)
+
+	assert.Equal(t, []int{7, 2, 1, 2}, s.SequenceUsers())
+
+	// Shares are rounded by largest remainder...
+	s.Concurrency = 4
+	assert.Equal(t, []int{3, 1, 0, 2}, s.SequenceUsers())
+
+	s.Concurrency = 0
+	assert.Equal(t, []int{7, 2, 1, 2}, s.SequenceUsers())
+
+	// A single sequence...
+	s, err = c.ParseFile("../testdata/configs/flow.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, []*config.Sequence{&s.Sequence}, s.AllSequences())
+	assert.Empty(t, s.Sequence.Prefix())
+	assert.Equal(t, []int{1}, s.SequenceUsers())
+	assert.Empty(t, s.AllRequests()[0].Sequence)
+
+	_, err = c.ParseFile("../testdata/configs/bad-sequences.yaml")
+	assert.EqualError(t, err, "sequence and sequences are mutually exclusive")
+}
+
+func TestPhases(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/phases.yaml")
+	assert.Nil(t, err)
+
+	setup := &s.SetupSequence
+	assert.Equal(t, config.PhaseSetup, setup.Name)
+	assert.Equal(t, 1, setup.Iterations)
+	assert.True(t, setup.AbortOnError)
+	assert.Equal(t, config.DefaultMaxJumps, setup.MaxJumps)
+	assert.Equal(t, &s.Setup[0], &setup.Requests[0])
+	assert.Equal(t, config.PhaseSetup, s.Setup[1].Sequence)
+
+	teardown := &s.TeardownSequence
+	assert.Equal(t, config.PhaseTeardown, teardown.Name)
+	assert.False(t, teardown.AbortOnError)
+	assert.Equal(t, config.PhaseTeardown, s.Teardown[0].Sequence)
+	assert.Equal(t, []*config.Sequence{setup, teardown}, s.Phases())
+
+	// Defaults apply to the phases...
+	assert.Equal(t, 1, s.Teardown[1].ThunderingHerd.Max)
+
+	assert.Len(t, s.AllRequests(), 5)
+
+	// Phases are only included in the statistics when requested...
+	assert.Equal(t, []*config.Request{&s.Sequence.Requests[0]}, s.LoadRequests())
+
+	s.PhaseStats = true
+	assert.Len(t, s.LoadRequests(), 5)
+}
+
+func TestPoll(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/poll.yaml")
+	assert.Nil(t, err)
+
+	p := s.Sequence.Requests[0].Poll
+	assert.Equal(t, 10*time.Millisecond, p.Interval)
+	assert.Equal(t, time.Duration(0), p.Timeout)
+	assert.Equal(t, 5, p.MaxPolls)
+	assert.Equal(t, 200, p.Until.StatusCode)
+	assert.Len(t, p.Until.AssertCompiled, 1)
+	assert.Equal(t, `status == "done"`, p.Until.AssertCompiled[0].String())
+
+	// Defaults...
+	p = s.Sequence.Requests[1].Poll
+	assert.Equal(t, config.DefaultPollInterval, p.Interval)
+	assert.Equal(t, config.DefaultPollTimeout, p.Timeout)
+	assert.Equal(t, "X-Job-State", p.Until.Headers[0].Name)
+
+	assert.Nil(t, s.Sequence.Requests[1].Poll.Until.AssertCompiled)
+}
+
+func TestMarshalYAML(t *testing.T) {
+
+	c := config.New()
+
+	s, err := c.ParseFile("../testdata/configs/test_scenario.yaml")
+	assert.Nil(t, err)
+
+	blob, err := yaml.Marshal(s)
+	assert.Nil(t, err)
+
+	// Runtime state is not written...
+	assert.NotContains(t, string(blob), "stats")
+	assert.Contains(t, string(blob), "time_limit: 1ms")
+
+	// The sequence follows the scenario settings, and a request's method
+	// precedes its herd...
+	out := string(blob)
+	assert.Less(t, strings.Index(out, "find_replace:"), strings.Index(out, "sequence:"))
+	assert.Less(t, strings.Index(out, "method:"), strings.Index(out, "thundering_herd:"))
+	assert.Less(t, strings.Index(out, "thundering_herd:"), strings.Index(out, "responses:"))
+
+	f, err := os.CreateTemp("", "rapid-marshal-*.yaml")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(blob)
+	assert.Nil(t, err)
+	f.Close()
+
+	rt, err := c.ParseFile(f.Name())
+	assert.Nil(t, err)
+
+	assert.Equal(t, s.Name, rt.Name)
+	assert.Equal(t, s.Replacements, rt.Replacements)
+	assert.Equal(t, s.TLS, rt.TLS)
+	assert.Equal(t, s.Sequence.Limit, rt.Sequence.Limit)
+	assert.Equal(t, len(s.Sequence.Requests), len(rt.Sequence.Requests))
+	assert.Equal(t, s.Sequence.Requests[0].ThunderingHerd, rt.Sequence.Requests[0].ThunderingHerd)
+	assert.Equal(t, s.Sequence.Requests[0].Cookies, rt.Sequence.Requests[0].Cookies)
+	assert.Equal(t, s.Sequence.Requests[0].Responses[0].Content.Contains, rt.Sequence.Requests[0].Responses[0].Content.Contains)
+	assert.Equal(t, s.Sequence.Requests[0].Responses[0].Content.Extract, rt.Sequence.Requests[0].Responses[0].Content.Extract)
+}
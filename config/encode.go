@@ -0,0 +1,192 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fieldOrder defines the fields written first, and last, for readability,
+// eg: a request's method and url precede its herd.  Other fields are
+// written in declaration order.
+type fieldOrder struct {
+	first []string
+	last  []string
+}
+
+var fieldOrders = map[reflect.Type]fieldOrder{
+	reflect.TypeOf(Scenario{}): {
+		last: []string{"setup", "sequence", "sequences", "teardown"},
+	},
+	reflect.TypeOf(Request{}): {
+		first: []string{"name", "once_only", "method", "url", "content", "content_type"},
+		last:  []string{"responses"},
+	},
+}
+
+// MarshalYAML encodes the scenario using the mapstructure field names.
+// Zero values and runtime state are omitted.
+func (s *Scenario) MarshalYAML() (any, error) {
+
+	n, err := encodeNode(reflect.ValueOf(s).Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	if n == nil {
+		return &yaml.Node{Kind: yaml.MappingNode}, nil
+	}
+
+	return n, nil
+}
+
+func scalarNode(tag, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
+
+// encodeNode returns nil for values that should be omitted.
+func encodeNode(v reflect.Value) (*yaml.Node, error) {
+
+	if v.Type() == durationType {
+		if v.Int() == 0 {
+			return nil, nil
+		}
+		return scalarNode("!!str", time.Duration(v.Int()).String()), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return encodeNode(v.Elem())
+
+	case reflect.Struct:
+		return encodeStruct(v)
+
+	case reflect.Interface:
+		// Arbitrary values, eg: an assertion's value, where zero is meaningful.
+		if v.IsNil() {
+			return nil, nil
+		}
+		n := new(yaml.Node)
+		err := n.Encode(v.Interface())
+		return n, err
+
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return nil, nil
+		}
+
+		seq := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < v.Len(); i++ {
+			n, err := encodeNode(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			if n != nil {
+				seq.Content = append(seq.Content, n)
+			}
+		}
+
+		if len(seq.Content) == 0 {
+			return nil, nil
+		}
+		return seq, nil
+
+	case reflect.String:
+		if v.String() == "" {
+			return nil, nil
+		}
+		return scalarNode("!!str", v.String()), nil
+
+	case reflect.Bool:
+		if !v.Bool() {
+			return nil, nil
+		}
+		return scalarNode("!!bool", "true"), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() == 0 {
+			return nil, nil
+		}
+		return scalarNode("!!int", strconv.FormatInt(v.Int(), 10)), nil
+
+	case reflect.Float32, reflect.Float64:
+		if v.Float() == 0 {
+			return nil, nil
+		}
+		return scalarNode("!!float", strconv.FormatFloat(v.Float(), 'g', -1, 64)), nil
+	}
+
+	return nil, fmt.Errorf("config: cannot encode %s", v.Type())
+}
+
+// fieldIndexes returns the indexes of the configuration fields of t, by
+// name, in the order they are written.
+func fieldIndexes(t reflect.Type) ([]string, map[string]int) {
+
+	order := fieldOrders[t]
+	rank := func(name string) int {
+		if slices.Contains(order.last, name) {
+			return len(order.first) + 1 + slices.Index(order.last, name)
+		}
+		if i := slices.Index(order.first, name); i >= 0 {
+			return i
+		}
+		return len(order.first)
+	}
+
+	var names []string
+	indexes := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+
+		// Only configuration fields, runtime state has no tag.
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("mapstructure"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		names = append(names, name)
+		indexes[name] = i
+	}
+
+	slices.SortStableFunc(names, func(a, b string) int {
+		return rank(a) - rank(b)
+	})
+
+	return names, indexes
+}
+
+func encodeStruct(v reflect.Value) (*yaml.Node, error) {
+
+	m := &yaml.Node{Kind: yaml.MappingNode}
+
+	names, indexes := fieldIndexes(v.Type())
+	for _, name := range names {
+
+		n, err := encodeNode(v.Field(indexes[name]))
+		if err != nil {
+			return nil, err
+		}
+
+		if n != nil {
+			m.Content = append(m.Content, scalarNode("!!str", name), n)
+		}
+	}
+
+	if len(m.Content) == 0 {
+		return nil, nil
+	}
+	return m, nil
+}
@@ -16,12 +16,19 @@ import (
 //go:generate go tool counterfeiter -o ../testdata/mocks/fake_data.go . Data
 type Data interface {
 	AddReplacement(string, string) error
+	Unset(string)
+	Reset(...string)
+	Variables() map[string]string
+	Snapshot() Snapshot
 	Replace(string) string
+	Render(string, int) (string, error)
 	Lookup(string) string
 	Len() int
+	Scope(int) Data
+	User(int) Data
 	ExtractJSON(string, io.Reader) (string, error)
 	ExtractXML(string, io.Reader) (string, error)
-	ExtractRegex(string, io.Reader) (string, error)
+	ExtractRegex(string, io.Reader, RegexMatch) (string, error)
 }
 
 // Replacement defines a compiled regex and its associated replacement string
@@ -31,10 +38,19 @@ type Replacement struct {
 	value string
 }
 
-// Context defines a sequence
+// Shared selects the scenario scope, rather than a virtual user's scope.
+const Shared = -1
+
+// Context defines a data scope.  A virtual user's scope inherits the
+// replacements of its parent, the scenario scope or a user's scope, and a
+// user's scope inherits from the scenario scope.  Replacements added to a
+// scope are only visible within it, and take precedence over the parent.
 type Context struct {
-	mu  sync.RWMutex
-	all []Replacement
+	mu     sync.RWMutex
+	all    []Replacement
+	parent *Context
+	scopes map[int]*Context
+	users  map[int]*Context
 }
 
 // New creates a new context instance
@@ -42,53 +58,156 @@ func New() *Context {
 	return &Context{}
 }
 
-// AddReplacement creates a new regex replacement.
+// update sets the value of an existing replacement.  Returns false if
+// the name is not in this scope.  The caller holds the lock.
+func (d *Context) update(name string, value string) bool {
+
+	for i := range d.all {
+		if d.all[i].name == name {
+			d.all[i].value = value
+			return true
+		}
+	}
+	return false
+}
+
+// AddReplacement creates a new regex replacement, or updates the value
+// in place if the name already exists in this scope.
 func (d *Context) AddReplacement(name string, value string) error {
 
-	r := Replacement{}
+	d.mu.Lock()
+	found := d.update(name, value)
+	d.mu.Unlock()
+
+	if found {
+		return nil
+	}
 
 	re, err := regexp.Compile(name)
 	if err != nil {
 		return err
 	}
 
-	r.name = name
-	r.regx = re
-	r.value = value
-
 	d.mu.Lock()
-	d.all = append(d.all, r)
-	d.mu.Unlock()
+	defer d.mu.Unlock()
+
+	// Another goroutine may have added it meanwhile...
+	if !d.update(name, value) {
+		d.all = append(d.all, Replacement{name: name, regx: re, value: value})
+	}
 
 	return nil
 }
 
-// Replace replaces any matches and returns a new string
-func (d *Context) Replace(s string) string {
+// Unset removes a replacement from this scope.  Inherited values are not
+// affected.
+func (d *Context) Unset(name string) {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i := range d.all {
+		if d.all[i].name == name {
+			d.all = append(d.all[:i], d.all[i+1:]...)
+			return
+		}
+	}
+}
+
+// Reset removes replacements from this scope, and every user's and
+// virtual user's scope within it.
+func (d *Context) Reset(names ...string) {
+
+	for _, name := range names {
+		d.Unset(name)
+	}
 
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
+	for _, scope := range d.scopes {
+		scope.Reset(names...)
+	}
+	for _, scope := range d.users {
+		scope.Reset(names...)
+	}
+}
+
+// child returns the scope for id in scopes, creating it on first use.
+func (d *Context) child(scopes *map[int]*Context, id int) *Context {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if *scopes == nil {
+		*scopes = make(map[int]*Context)
+	}
+
+	scope, ok := (*scopes)[id]
+	if !ok {
+		scope = &Context{parent: d}
+		(*scopes)[id] = scope
+	}
+
+	return scope
+}
+
+// Scope returns the scope for a virtual user, creating it on first use.
+// Shared returns this scope.
+func (d *Context) Scope(vu int) Data {
+
+	if vu == Shared {
+		return d
+	}
+	return d.child(&d.scopes, vu)
+}
+
+// User returns the scope for a user of a sequence, creating it on first
+// use.  The user's virtual users are scopes within it.  Shared returns
+// this scope.
+func (d *Context) User(user int) Data {
+
+	if user == Shared {
+		return d
+	}
+	return d.child(&d.users, user)
+}
+
+// Replace replaces any matches and returns a new string
+func (d *Context) Replace(s string) string {
+
+	d.mu.RLock()
 	for i := range d.all {
 		s = d.all[i].regx.ReplaceAllLiteralString(s, d.all[i].value)
 	}
+	d.mu.RUnlock()
+
+	if d.parent != nil {
+		return d.parent.Replace(s)
+	}
 	return s
 }
 
 // Lookup returns the replacement text (value) for a name
 func (d *Context) Lookup(n string) string {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
 
+	d.mu.RLock()
 	for i := range d.all {
 		if n == d.all[i].name {
-			return d.all[i].value
+			value := d.all[i].value
+			d.mu.RUnlock()
+			return value
 		}
 	}
+	d.mu.RUnlock()
+
+	if d.parent != nil {
+		return d.parent.Lookup(n)
+	}
 	return ""
 }
 
-// Len returns the number of replacement elements.
+// Len returns the number of replacement elements in this scope.
 func (d *Context) Len() int {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
@@ -6,7 +6,9 @@ package data_test
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+	"sync"
 	"testing"
 	"testing/iotest"
 
@@ -184,12 +186,56 @@ func TestExtractJSONNotFoundError(t *testing.T) {
 	assert.Equal(t, "", v)
 }
 
+func TestJSONElements(t *testing.T) {
+
+	b := []byte(`{"orders": [{"id": 7, "total": 1.5}, {"id": 9}], "count": 2}`)
+
+	v, err := data.JSONElements("orders.#.id", b)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"7", "9"}, v)
+
+	v, err = data.JSONElements("orders", b)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`{"id": 7, "total": 1.5}`, `{"id": 9}`}, v)
+
+	// The whole document...
+	v, err = data.JSONElements("", []byte(`["a", "b"]`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, v)
+
+	v, err = data.JSONElements("", []byte(`[]`))
+	assert.Nil(t, err)
+	assert.Empty(t, v)
+
+	_, err = data.JSONElements("count", b)
+	assert.EqualError(t, err, "JSON: Not an array: count")
+
+	_, err = data.JSONElements("items", b)
+	assert.EqualError(t, err, "JSON: Not found: items")
+}
+
+func TestXMLElements(t *testing.T) {
+
+	b := []byte(`<orders><order><id>7</id></order><order><id>9</id></order></orders>`)
+
+	v, err := data.XMLElements("//order/id", b)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"7", "9"}, v)
+
+	v, err = data.XMLElements("//item", b)
+	assert.Nil(t, err)
+	assert.Empty(t, v)
+
+	_, err = data.XMLElements("/>?order", b)
+	assert.NotNil(t, err)
+}
+
 func TestExtractRegexp(t *testing.T) {
 
 	d := data.New()
 
 	s := `{ "color":"blue"}`
-	v, err := d.ExtractRegex("color", strings.NewReader(s))
+	v, err := d.ExtractRegex("color", strings.NewReader(s), data.RegexMatch{})
 	assert.Nil(t, err)
 	assert.Equal(t, "color", v)
 }
@@ -199,7 +245,7 @@ func TestExtractRegexpBadReader(t *testing.T) {
 	d := data.New()
 
 	r := iotest.ErrReader(errors.New("blowing chunks"))
-	v, err := d.ExtractRegex("color", r)
+	v, err := d.ExtractRegex("color", r, data.RegexMatch{})
 	assert.Equal(t, "REGEX: Read error: blowing chunks", err.Error())
 	assert.Equal(t, "", v)
 }
@@ -209,7 +255,7 @@ func TestExtractRegexpBadCompile(t *testing.T) {
 	d := data.New()
 
 	s := `{ "color":"blue"}`
-	v, err := d.ExtractRegex(`\((?!['"]`, strings.NewReader(s))
+	v, err := d.ExtractRegex(`\((?!['"]`, strings.NewReader(s), data.RegexMatch{})
 	assert.Equal(t, "error parsing regexp: invalid or unsupported Perl syntax: `(?!`", err.Error())
 	assert.Equal(t, "", v)
 }
@@ -219,7 +265,213 @@ func TestExtractRegexpNotFoundError(t *testing.T) {
 	d := data.New()
 
 	s := `{ "color":"blue"}`
-	v, err := d.ExtractRegex("foobar", strings.NewReader(s))
+	v, err := d.ExtractRegex("foobar", strings.NewReader(s), data.RegexMatch{})
 	assert.Equal(t, "REGEX: value not found for expression: foobar", err.Error())
 	assert.Equal(t, "", v)
 }
+
+func TestExtractRegexGroups(t *testing.T) {
+
+	d := data.New()
+
+	s := `<a href="/painters?id=123&name=bob">Bob</a> <a href="/painters?id=456&name=betsy">Betsy</a>`
+
+	v, err := d.ExtractRegex(`id=(\d+)`, strings.NewReader(s), data.RegexMatch{Group: "1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "123", v)
+
+	v, err = d.ExtractRegex(`id=(?P<id>\d+)&name=(?P<name>\w+)`, strings.NewReader(s), data.RegexMatch{Group: "name", Index: "1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "betsy", v)
+
+	v, err = d.ExtractRegex(`id=(\d+)`, strings.NewReader(s), data.RegexMatch{Group: "1", Index: "-1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "456", v)
+
+	v, err = d.ExtractRegex(`id=(\d+)`, strings.NewReader(s), data.RegexMatch{Group: "1", Index: data.IndexAll})
+	assert.Nil(t, err)
+	assert.Equal(t, "123,456", v)
+
+	v, err = d.ExtractRegex(`>(\w+)<`, strings.NewReader(s), data.RegexMatch{Group: "1", Index: data.IndexAll, Separator: " "})
+	assert.Nil(t, err)
+	assert.Equal(t, "Bob Betsy", v)
+
+	// The whole match by default...
+	v, err = d.ExtractRegex(`id=(\d+)`, strings.NewReader(s), data.RegexMatch{})
+	assert.Nil(t, err)
+	assert.Equal(t, "id=123", v)
+
+	_, err = d.ExtractRegex(`id=(\d+)`, strings.NewReader(s), data.RegexMatch{Index: "2"})
+	assert.EqualError(t, err, `REGEX: index 2 out of range, 2 match(es) found for expression: id=(\d+)`)
+
+	_, err = d.ExtractRegex(`id=(\d+)`, strings.NewReader(s), data.RegexMatch{Group: "2"})
+	assert.EqualError(t, err, "REGEX: capture group 2 out of range, expression has 1")
+
+	_, err = d.ExtractRegex(`id=(\d+)`, strings.NewReader(s), data.RegexMatch{Group: "token"})
+	assert.EqualError(t, err, "REGEX: unknown capture group: token")
+
+	_, err = d.ExtractRegex(`id=(\d+)`, strings.NewReader(s), data.RegexMatch{Index: "last"})
+	assert.EqualError(t, err, `REGEX: invalid index: "last" (must be a number or all)`)
+}
+
+func TestExtractRegexNearestMatch(t *testing.T) {
+
+	d := data.New()
+
+	s := `id=123&nom=bob`
+
+	_, err := d.ExtractRegex(`id=(\d+)&name=(\w+)`, strings.NewReader(s), data.RegexMatch{Group: "1"})
+	assert.EqualError(t, err, `REGEX: value not found for expression: id=(\d+)&name=(\w+) (nearest partial match "id=123", followed by "&nom=bob")`)
+
+	// Nothing matches at all...
+	_, err = d.ExtractRegex(`token=(\w+)`, strings.NewReader(s), data.RegexMatch{})
+	assert.EqualError(t, err, `REGEX: value not found for expression: token=(\w+)`)
+}
+
+func TestCheckRegex(t *testing.T) {
+
+	assert.Nil(t, data.CheckRegex(`id=(?P<id>\d+)`, data.RegexMatch{Group: "id", Index: "all"}))
+	assert.NotNil(t, data.CheckRegex(`id=(\d+`, data.RegexMatch{}))
+	assert.NotNil(t, data.CheckRegex(`id=(\d+)`, data.RegexMatch{Group: "id"}))
+	assert.NotNil(t, data.CheckRegex(`id=(\d+)`, data.RegexMatch{Index: "first"}))
+}
+
+func TestScope(t *testing.T) {
+
+	d := data.New()
+
+	err := d.AddReplacement("HOST", "bob_ross.com")
+	assert.Nil(t, err)
+	err = d.AddReplacement("TOKEN", "shared")
+	assert.Nil(t, err)
+
+	assert.Same(t, d, d.Scope(data.Shared))
+
+	vu0 := d.Scope(0)
+	vu1 := d.Scope(1)
+	assert.Same(t, vu0, d.Scope(0))
+	assert.NotSame(t, vu0, vu1)
+
+	// Scopes inherit from the scenario...
+	assert.Equal(t, "bob_ross.com shared", vu0.Replace("HOST TOKEN"))
+
+	err = vu0.AddReplacement("TOKEN", "zero")
+	assert.Nil(t, err)
+	err = vu1.AddReplacement("TOKEN", "one")
+	assert.Nil(t, err)
+
+	// ...and their own values take precedence, without leaking.
+	assert.Equal(t, "bob_ross.com zero", vu0.Replace("HOST TOKEN"))
+	assert.Equal(t, "bob_ross.com one", vu1.Replace("HOST TOKEN"))
+	assert.Equal(t, "bob_ross.com shared", d.Replace("HOST TOKEN"))
+
+	assert.Equal(t, "zero", vu0.Lookup("TOKEN"))
+	assert.Equal(t, "bob_ross.com", vu0.Lookup("HOST"))
+	assert.Equal(t, "shared", d.Lookup("TOKEN"))
+	assert.Equal(t, 1, vu0.Len())
+
+	s, err := vu1.Render("{{ .vars.HOST }}/{{ .vars.TOKEN }}", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "bob_ross.com/one", s)
+}
+
+func TestAddReplacementUpsert(t *testing.T) {
+
+	d := data.New()
+
+	for _, v := range []string{"first", "second", "third"} {
+		err := d.AddReplacement("TOKEN", v)
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, 1, d.Len())
+	assert.Equal(t, "third", d.Lookup("TOKEN"))
+	assert.Equal(t, "Bearer third", d.Replace("Bearer TOKEN"))
+}
+
+func TestLookupConcurrent(t *testing.T) {
+
+	d := data.New()
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for i := range 1000 {
+			_ = d.AddReplacement("TOKEN", fmt.Sprint(i))
+			_ = d.AddReplacement("HOST", "bob_ross.com")
+			d.Unset("TOKEN")
+		}
+	})
+
+	for range 1000 {
+		d.Lookup("HOST")
+		d.Lookup("TOKEN")
+	}
+	wg.Wait()
+
+	assert.Equal(t, "bob_ross.com", d.Lookup("HOST"))
+}
+
+func TestUnsetReset(t *testing.T) {
+
+	d := data.New()
+
+	err := d.AddReplacement("TOKEN", "shared")
+	assert.Nil(t, err)
+	err = d.AddReplacement("HOST", "bob_ross.com")
+	assert.Nil(t, err)
+
+	vu := d.Scope(0)
+	err = vu.AddReplacement("TOKEN", "mine")
+	assert.Nil(t, err)
+
+	// Unset only affects the scope...
+	vu.Unset("TOKEN")
+	assert.Equal(t, "shared", vu.Lookup("TOKEN"))
+	vu.Unset("NOSUCH")
+
+	err = vu.AddReplacement("TOKEN", "mine")
+	assert.Nil(t, err)
+
+	// ...reset affects every scope.
+	d.Reset("TOKEN")
+	assert.Equal(t, "", d.Lookup("TOKEN"))
+	assert.Equal(t, "", vu.Lookup("TOKEN"))
+	assert.Equal(t, "bob_ross.com", vu.Lookup("HOST"))
+}
+
+func TestSnapshot(t *testing.T) {
+
+	d := data.New()
+
+	err := d.AddReplacement("HOST", "bob_ross.com")
+	assert.Nil(t, err)
+
+	err = d.Scope(2).AddReplacement("TOKEN", "two")
+	assert.Nil(t, err)
+	d.Scope(5)
+
+	s := d.Snapshot()
+	assert.Equal(t, map[string]string{"HOST": "bob_ross.com"}, s.Shared)
+	assert.Equal(t, map[int]map[string]string{2: {"TOKEN": "two"}}, s.VirtualUsers)
+
+	assert.Equal(t, map[string]string{"HOST": "bob_ross.com", "TOKEN": "two"}, d.Scope(2).Variables())
+	assert.Nil(t, s.Users)
+
+	// Users, and their virtual users...
+	err = d.User(1).AddReplacement("TOKEN", "user-one")
+	assert.Nil(t, err)
+	err = d.User(1).Scope(2).AddReplacement("ID", "12")
+	assert.Nil(t, err)
+	err = d.User(3).Scope(0).AddReplacement("ID", "30")
+	assert.Nil(t, err)
+	d.User(4).Scope(0)
+
+	s = d.Snapshot()
+	assert.Equal(t, map[int]map[string]string{2: {"TOKEN": "two"}}, s.VirtualUsers)
+	assert.Equal(t, map[int]data.Snapshot{
+		1: {Shared: map[string]string{"TOKEN": "user-one"}, VirtualUsers: map[int]map[string]string{2: {"ID": "12"}}},
+		3: {Shared: map[string]string{}, VirtualUsers: map[int]map[string]string{0: {"ID": "30"}}},
+	}, s.Users)
+
+	assert.Equal(t, map[string]string{"HOST": "bob_ross.com", "TOKEN": "user-one", "ID": "12"}, d.User(1).Scope(2).Variables())
+}
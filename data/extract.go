@@ -10,6 +10,9 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
 
 	"github.com/antchfx/xmlquery"
 	"github.com/tidwall/gjson"
@@ -53,14 +56,95 @@ func (d *Context) ExtractJSON(path string, r io.Reader) (string, error) {
 	return result.String(), nil
 }
 
+// RegexMatch selects the value extracted by a regular expression.  Group
+// is a capture group number or name, zero (the default) is the whole
+// match.  Index selects a match, from zero, negative indexes count from
+// the last match, and "all" joins every match with the separator, a comma
+// by default.
+type RegexMatch struct {
+	Group     string
+	Index     string
+	Separator string
+}
+
+// IndexAll selects every match.
+const IndexAll = "all"
+
+// group returns the capture group number.
+func (m RegexMatch) group(re *regexp.Regexp) (int, error) {
+
+	if m.Group == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(m.Group)
+	if err != nil {
+		n = re.SubexpIndex(m.Group)
+		if n < 0 {
+			return 0, fmt.Errorf("REGEX: unknown capture group: %s", m.Group)
+		}
+	}
+
+	if n < 0 || n > re.NumSubexp() {
+		return 0, fmt.Errorf("REGEX: capture group %d out of range, expression has %d", n, re.NumSubexp())
+	}
+
+	return n, nil
+}
+
+// index returns the match index, or all.
+func (m RegexMatch) index() (int, bool, error) {
+
+	switch m.Index {
+	case "":
+		return 0, false, nil
+	case IndexAll:
+		return 0, true, nil
+	}
+
+	n, err := strconv.Atoi(m.Index)
+	if err != nil {
+		return 0, false, fmt.Errorf("REGEX: invalid index: %q (must be a number or all)", m.Index)
+	}
+
+	return n, false, nil
+}
+
+// CheckRegex verifies an expression and the selection of its value.
+func CheckRegex(rs string, m RegexMatch) error {
+
+	re, err := regexp.Compile(rs)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.group(re)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = m.index()
+	return err
+}
+
 // ExtractRegex extracts a value using a regular expression.
-func (d *Context) ExtractRegex(rs string, r io.Reader) (string, error) {
+func (d *Context) ExtractRegex(rs string, r io.Reader, m RegexMatch) (string, error) {
 
 	re, err := regexp.Compile(rs)
 	if err != nil {
 		return "", err
 	}
 
+	group, err := m.group(re)
+	if err != nil {
+		return "", err
+	}
+
+	index, all, err := m.index()
+	if err != nil {
+		return "", err
+	}
+
 	buf := new(bytes.Buffer)
 
 	_, err = buf.ReadFrom(r)
@@ -68,10 +152,121 @@ func (d *Context) ExtractRegex(rs string, r io.Reader) (string, error) {
 		return "", fmt.Errorf("REGEX: Read error: %s", err.Error())
 	}
 
-	b := re.Find(buf.Bytes())
-	if len(b) == 0 {
-		return "", fmt.Errorf("REGEX: value not found for expression: %s", rs)
+	b := buf.Bytes()
+
+	matches := re.FindAllSubmatchIndex(b, -1)
+	if len(matches) == 0 {
+		return "", fmt.Errorf("REGEX: value not found for expression: %s%s", rs, nearestMatch(rs, b))
+	}
+
+	// A group that did not participate in a match is empty.
+	value := func(match []int) string {
+		if match[2*group] < 0 {
+			return ""
+		}
+		return string(b[match[2*group]:match[2*group+1]])
+	}
+
+	if all {
+		separator := m.Separator
+		if separator == "" {
+			separator = ","
+		}
+
+		values := make([]string, len(matches))
+		for i, match := range matches {
+			values[i] = value(match)
+		}
+		return strings.Join(values, separator), nil
+	}
+
+	if index < 0 {
+		index += len(matches)
+	}
+
+	if index < 0 || index >= len(matches) {
+		return "", fmt.Errorf("REGEX: index %s out of range, %d match(es) found for expression: %s", m.Index, len(matches), rs)
+	}
+
+	return value(matches[index]), nil
+}
+
+// Context shown after a partial match.
+const partialContext = 32
+
+// nearestMatch describes the longest leading part of an expression that
+// does match, eg: for `id=(\d+)&name=(\w+)` it finds "id=123" followed by
+// "&nom=bob", pointing at the mismatch.
+func nearestMatch(rs string, b []byte) string {
+
+	parsed, err := syntax.Parse(rs, syntax.Perl)
+	if err != nil || parsed.Op != syntax.OpConcat {
+		return ""
+	}
+
+	for n := len(parsed.Sub) - 1; n > 0; n-- {
+
+		prefix := &syntax.Regexp{Op: syntax.OpConcat, Flags: parsed.Flags, Sub: parsed.Sub[:n]}
+		re, err := regexp.Compile(prefix.String())
+		if err != nil {
+			continue
+		}
+
+		loc := re.FindIndex(b)
+		if loc == nil || loc[0] == loc[1] {
+			continue
+		}
+
+		end := min(loc[1]+partialContext, len(b))
+		return fmt.Sprintf(" (nearest partial match %q, followed by %q)", b[loc[0]:loc[1]], b[loc[1]:end])
+	}
+
+	return ""
+}
+
+// JSONElements returns the elements of the array selected by a gjson
+// path.  An empty path selects the whole document.  Objects and arrays
+// are returned as JSON.
+func JSONElements(path string, b []byte) ([]string, error) {
+
+	result := gjson.ParseBytes(b)
+	if path != "" {
+		result = result.Get(path)
+	}
+
+	if !result.Exists() {
+		return nil, fmt.Errorf("JSON: Not found: %s", path)
+	}
+
+	if !result.IsArray() {
+		return nil, fmt.Errorf("JSON: Not an array: %s", path)
+	}
+
+	var elements []string
+	for _, e := range result.Array() {
+		elements = append(elements, e.String())
+	}
+
+	return elements, nil
+}
+
+// XMLElements returns the text of every node selected by an XPath.
+func XMLElements(path string, b []byte) ([]string, error) {
+
+	doc, err := xmlquery.Parse(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := xmlquery.QueryAll(doc, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var elements []string
+	for _, n := range nodes {
+		elements = append(elements, n.InnerText())
 	}
 
-	return string(b), nil
+	return elements, nil
 }
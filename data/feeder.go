@@ -0,0 +1,257 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package data
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Data source formats.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Orders in which rows are handed out.
+const (
+	OrderSequential = "sequential" // In file order, shared by all virtual users
+	OrderRandom     = "random"     // A random row each time, never exhausted
+	OrderUnique     = "unique"     // One row per data scope, kept for the run
+)
+
+// Actions when a data source runs out of rows.
+const (
+	ExhaustStop    = "stop"    // Stop executing the request
+	ExhaustRecycle = "recycle" // Start again from the first row
+	ExhaustError   = "error"   // Each further execution is an error
+)
+
+// ErrExhausted is returned when a data source has no more rows.
+var ErrExhausted = errors.New("data source exhausted")
+
+// Feeder hands out the rows of a data source.  Each column of a row is
+// bound as a variable.
+type Feeder struct {
+	name      string
+	rows      []map[string]string
+	order     string
+	onExhaust string
+
+	mu        sync.Mutex
+	next      int
+	assigned  map[Data]map[string]string // Unique rows, by data scope
+	exhausted atomic.Bool
+}
+
+// NewFeeder loads a data source.  The format defaults to the file
+// extension, the order to sequential, and on exhaustion to stop.
+func NewFeeder(name, path, format, order, onExhaust string) (*Feeder, error) {
+
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	if order == "" {
+		order = OrderSequential
+	}
+
+	if onExhaust == "" {
+		onExhaust = ExhaustStop
+	}
+
+	switch order {
+	case OrderSequential, OrderRandom, OrderUnique:
+	default:
+		return nil, fmt.Errorf("unknown order: %q (must be sequential, random, or unique)", order)
+	}
+
+	switch onExhaust {
+	case ExhaustStop, ExhaustRecycle, ExhaustError:
+	default:
+		return nil, fmt.Errorf("unknown on_exhaust: %q (must be stop, recycle, or error)", onExhaust)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rows []map[string]string
+	switch format {
+	case FormatCSV:
+		rows, err = readCSV(f)
+	case FormatJSONL:
+		rows, err = readJSONL(f)
+	default:
+		return nil, fmt.Errorf("unknown format: %q (must be csv or jsonl)", format)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no rows", path)
+	}
+
+	// Columns are used as replacement names, JSONL rows may differ...
+	checked := map[string]bool{}
+	for i, row := range rows {
+		for column := range row {
+			if checked[column] {
+				continue
+			}
+			if _, err := regexp.Compile(column); err != nil {
+				return nil, fmt.Errorf("%s: row %d: column %q: %w", path, i+1, column, err)
+			}
+			checked[column] = true
+		}
+	}
+
+	return &Feeder{
+		name:      name,
+		rows:      rows,
+		order:     order,
+		onExhaust: onExhaust,
+	}, nil
+}
+
+// readCSV reads rows, the first record names the columns.
+func readCSV(r io.Reader) ([]map[string]string, error) {
+
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// readJSONL reads one JSON object per line.  Values that are not strings
+// are bound as their JSON text.
+func readJSONL(r io.Reader) ([]map[string]string, error) {
+
+	var rows []map[string]string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var obj map[string]json.RawMessage
+		err := json.Unmarshal([]byte(text), &obj)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		row := make(map[string]string, len(obj))
+		for k, v := range obj {
+			var s string
+			if json.Unmarshal(v, &s) != nil {
+				s = string(v)
+			}
+			row[k] = s
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, scanner.Err()
+}
+
+// Name returns the name of the data source.
+func (f *Feeder) Name() string {
+	return f.name
+}
+
+// Len returns the number of rows.
+func (f *Feeder) Len() int {
+	return len(f.rows)
+}
+
+// Exhausted returns true once the data source has run out of rows, and
+// further executions should stop.
+func (f *Feeder) Exhausted() bool {
+	return f.exhausted.Load()
+}
+
+// Stops returns true if executions without a row stop, rather than error,
+// when the data source has run out of rows.
+func (f *Feeder) Stops() bool {
+	return f.onExhaust == ExhaustStop
+}
+
+// Next returns the row to bind in a data scope, or ErrExhausted.  With
+// unique order, each scope (eg: a user's virtual user) keeps its row, only
+// scopes without a row are refused, and the data source is never exhausted.
+func (f *Feeder) Next(scope Data) (map[string]string, error) {
+
+	if f.order == OrderRandom {
+		return f.rows[rand.IntN(len(f.rows))], nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.order == OrderUnique {
+		if row, ok := f.assigned[scope]; ok {
+			return row, nil
+		}
+	}
+
+	if f.next >= len(f.rows) {
+		if f.onExhaust != ExhaustRecycle {
+			if f.onExhaust == ExhaustStop && f.order != OrderUnique {
+				f.exhausted.Store(true)
+			}
+			return nil, fmt.Errorf("%s: %w", f.name, ErrExhausted)
+		}
+		f.next = 0
+	}
+
+	row := f.rows[f.next]
+	f.next++
+
+	if f.order == OrderUnique {
+		if f.assigned == nil {
+			f.assigned = make(map[Data]map[string]string)
+		}
+		f.assigned[scope] = row
+	}
+
+	return row, nil
+}
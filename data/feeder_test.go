@@ -0,0 +1,163 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package data_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pwmorreale/rapid/data"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	csvSource   = "../testdata/sources/painters.csv"
+	jsonlSource = "../testdata/sources/painters.jsonl"
+)
+
+func TestNewFeeder(t *testing.T) {
+
+	f, err := data.NewFeeder("painters", csvSource, "", "", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "painters", f.Name())
+	assert.Equal(t, 3, f.Len())
+
+	f, err = data.NewFeeder("painters", jsonlSource, "", "", "")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, f.Len())
+
+	// Non-string JSON values are bound as their text...
+	row, err := f.Next(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"PAINTER_ID": "1", "PAINTER_NAME": "Bob Ross"}, row)
+
+	_, err = data.NewFeeder("painters", csvSource, "xml", "", "")
+	assert.ErrorContains(t, err, "unknown format")
+
+	_, err = data.NewFeeder("painters", csvSource, "", "shuffled", "")
+	assert.ErrorContains(t, err, "unknown order")
+
+	_, err = data.NewFeeder("painters", csvSource, "", "", "wrap")
+	assert.ErrorContains(t, err, "unknown on_exhaust")
+
+	_, err = data.NewFeeder("painters", "nonexistent.csv", "", "", "")
+	assert.NotNil(t, err)
+
+	empty := filepath.Join(t.TempDir(), "empty.csv")
+	err = os.WriteFile(empty, []byte("PAINTER_ID\n"), 0o600)
+	assert.Nil(t, err)
+
+	_, err = data.NewFeeder("painters", empty, "", "", "")
+	assert.ErrorContains(t, err, "no rows")
+
+	// Every row's columns are checked...
+	bad := filepath.Join(t.TempDir(), "bad.jsonl")
+	err = os.WriteFile(bad, []byte(`{"PAINTER_ID": 1}`+"\n"+`{"PAINTER_ID": 2, "(": "x"}`+"\n"), 0o600)
+	assert.Nil(t, err)
+
+	_, err = data.NewFeeder("painters", bad, "", "", "")
+	assert.ErrorContains(t, err, `row 2: column "("`)
+}
+
+func TestFeederSequential(t *testing.T) {
+
+	f, err := data.NewFeeder("painters", csvSource, "", data.OrderSequential, data.ExhaustStop)
+	assert.Nil(t, err)
+
+	for _, id := range []string{"1", "2", "3"} {
+		row, err := f.Next(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, id, row["PAINTER_ID"])
+	}
+
+	assert.False(t, f.Exhausted())
+	_, err = f.Next(nil)
+	assert.ErrorIs(t, err, data.ErrExhausted)
+	assert.True(t, f.Exhausted())
+
+	f, err = data.NewFeeder("painters", csvSource, "", data.OrderSequential, data.ExhaustError)
+	assert.Nil(t, err)
+
+	for range 3 {
+		_, err = f.Next(nil)
+		assert.Nil(t, err)
+	}
+
+	// Errors do not stop execution...
+	_, err = f.Next(nil)
+	assert.ErrorIs(t, err, data.ErrExhausted)
+	assert.False(t, f.Exhausted())
+
+	f, err = data.NewFeeder("painters", csvSource, "", data.OrderSequential, data.ExhaustRecycle)
+	assert.Nil(t, err)
+
+	for _, id := range []string{"1", "2", "3", "1"} {
+		row, err := f.Next(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, id, row["PAINTER_ID"])
+	}
+}
+
+func TestFeederUnique(t *testing.T) {
+
+	f, err := data.NewFeeder("painters", csvSource, "", data.OrderUnique, data.ExhaustError)
+	assert.Nil(t, err)
+
+	d := data.New()
+
+	vu0, err := f.Next(d.Scope(0))
+	assert.Nil(t, err)
+	vu1, err := f.Next(d.Scope(1))
+	assert.Nil(t, err)
+	assert.NotEqual(t, vu0["PAINTER_ID"], vu1["PAINTER_ID"])
+
+	// Each virtual user keeps its row...
+	row, err := f.Next(d.Scope(0))
+	assert.Nil(t, err)
+	assert.Equal(t, vu0, row)
+
+	_, err = f.Next(d.Scope(2))
+	assert.Nil(t, err)
+
+	_, err = f.Next(d.Scope(3))
+	assert.ErrorIs(t, err, data.ErrExhausted)
+
+	// Users have their own virtual users...
+	_, err = f.Next(d.User(1).Scope(0))
+	assert.ErrorIs(t, err, data.ErrExhausted)
+
+	// Only the virtual user without a row is stopped...
+	f, err = data.NewFeeder("painters", csvSource, "", data.OrderUnique, data.ExhaustStop)
+	assert.Nil(t, err)
+
+	for vu := range 3 {
+		_, err = f.Next(d.Scope(vu))
+		assert.Nil(t, err)
+	}
+
+	_, err = f.Next(d.Scope(3))
+	assert.ErrorIs(t, err, data.ErrExhausted)
+	assert.False(t, f.Exhausted())
+	assert.True(t, f.Stops())
+
+	row, err = f.Next(d.Scope(0))
+	assert.Nil(t, err)
+	assert.Equal(t, "1", row["PAINTER_ID"])
+}
+
+func TestFeederRandom(t *testing.T) {
+
+	f, err := data.NewFeeder("painters", csvSource, "", data.OrderRandom, data.ExhaustStop)
+	assert.Nil(t, err)
+
+	for range 10 {
+		row, err := f.Next(nil)
+		assert.Nil(t, err)
+		assert.Contains(t, []string{"1", "2", "3"}, row["PAINTER_ID"])
+	}
+
+	assert.False(t, f.Exhausted())
+}
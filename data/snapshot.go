@@ -0,0 +1,84 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package data
+
+import (
+	"maps"
+)
+
+// Snapshot defines the variables of a scope, and of each virtual user's
+// and user's scope within it.  A scope only includes its own variables,
+// not those it inherits.
+type Snapshot struct {
+	Shared       map[string]string
+	VirtualUsers map[int]map[string]string
+	Users        map[int]Snapshot
+}
+
+// own returns the replacement values of this scope by name.
+func (d *Context) own() map[string]string {
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	v := make(map[string]string, len(d.all))
+	for i := range d.all {
+		v[d.all[i].name] = d.all[i].value
+	}
+
+	return v
+}
+
+// Variables returns the replacement values by name, including inherited
+// values.  A scope's values override its parent's.
+func (d *Context) Variables() map[string]string {
+
+	v := map[string]string{}
+	if d.parent != nil {
+		v = d.parent.Variables()
+	}
+
+	maps.Copy(v, d.own())
+	return v
+}
+
+// empty returns true if no scope has variables.
+func (s *Snapshot) empty() bool {
+	return len(s.Shared) == 0 && len(s.VirtualUsers) == 0 && len(s.Users) == 0
+}
+
+// Snapshot returns a copy of the current variables, by user and virtual
+// user.
+func (d *Context) Snapshot() Snapshot {
+
+	s := Snapshot{Shared: d.own()}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for vu, scope := range d.scopes {
+		v := scope.own()
+		if len(v) == 0 {
+			continue
+		}
+		if s.VirtualUsers == nil {
+			s.VirtualUsers = make(map[int]map[string]string)
+		}
+		s.VirtualUsers[vu] = v
+	}
+
+	for user, scope := range d.users {
+		us := scope.Snapshot()
+		if us.empty() {
+			continue
+		}
+		if s.Users == nil {
+			s.Users = make(map[int]Snapshot)
+		}
+		s.Users[user] = us
+	}
+
+	return s
+}
@@ -0,0 +1,129 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package data
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const randChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Parsed templates, keyed by their text.
+var templates sync.Map
+
+var funcs = template.FuncMap{
+	"uuid":       newUUID,
+	"now":        time.Now,
+	"rfc3339":    func(t time.Time) string { return t.Format(time.RFC3339) },
+	"unix":       func(t time.Time) int64 { return t.Unix() },
+	"unixMilli":  func(t time.Time) int64 { return t.UnixMilli() },
+	"date":       func(layout string, t time.Time) string { return t.Format(layout) },
+	"randInt":    randInt,
+	"randString": randString,
+	"env":        os.Getenv,
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// randInt returns a random integer in [lo, hi].
+func randInt(lo, hi int) (int, error) {
+
+	if hi < lo {
+		return 0, fmt.Errorf("randInt: %d < %d", hi, lo)
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(hi-lo)+1))
+	if err != nil {
+		return 0, err
+	}
+
+	return lo + int(n.Int64()), nil
+}
+
+// randString returns n random alphanumeric characters.
+func randString(n int) (string, error) {
+
+	b := make([]byte, n)
+	for i := range b {
+		c, err := rand.Int(rand.Reader, big.NewInt(int64(len(randChars))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = randChars[c.Int64()]
+	}
+
+	return string(b), nil
+}
+
+func parseTemplate(s string) (*template.Template, error) {
+
+	if t, ok := templates.Load(s); ok {
+		return t.(*template.Template), nil
+	}
+
+	t, err := template.New("").Funcs(funcs).Option("missingkey=error").Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	templates.Store(s, t)
+	return t, nil
+}
+
+// CheckTemplate verifies the syntax of any template actions in s.
+func CheckTemplate(s string) error {
+
+	if !strings.Contains(s, "{{") {
+		return nil
+	}
+
+	_, err := parseTemplate(s)
+	return err
+}
+
+// Render executes any template actions in s, then applies the
+// replacements.  Templates may use the iteration, eg: {{ .iteration }},
+// and replacement values by name, eg: {{ .vars.AUTH_TOKEN }}
+func (d *Context) Render(s string, iteration int) (string, error) {
+
+	if strings.Contains(s, "{{") {
+
+		t, err := parseTemplate(s)
+		if err != nil {
+			return "", err
+		}
+
+		var sb strings.Builder
+		err = t.Execute(&sb, map[string]any{
+			"iteration": iteration,
+			"vars":      d.Variables(),
+		})
+		if err != nil {
+			return "", err
+		}
+		s = sb.String()
+	}
+
+	return d.Replace(s), nil
+}
@@ -0,0 +1,94 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package data_test
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pwmorreale/rapid/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+
+	d := data.New()
+
+	err := d.AddReplacement("AUTH_TOKEN", "secret")
+	assert.Nil(t, err)
+
+	s, err := d.Render("no actions", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, "no actions", s)
+
+	s, err = d.Render("iteration={{ .iteration }} token={{ .vars.AUTH_TOKEN }}", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, "iteration=3 token=secret", s)
+
+	// Replacements still apply...
+	s, err = d.Render("{{ upper \"bearer\" }} AUTH_TOKEN", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "BEARER secret", s)
+}
+
+func TestRenderFunctions(t *testing.T) {
+
+	d := data.New()
+
+	t.Setenv("RAPID_TEST_ENV", "from-env")
+
+	s, err := d.Render(`{{ env "RAPID_TEST_ENV" }}`, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "from-env", s)
+
+	s, err = d.Render("{{ uuid }}", 0)
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), s)
+
+	u, err := d.Render("{{ uuid }}", 0)
+	assert.Nil(t, err)
+	assert.NotEqual(t, s, u)
+
+	s, err = d.Render("{{ now | rfc3339 }}", 0)
+	assert.Nil(t, err)
+	_, err = time.Parse(time.RFC3339, s)
+	assert.Nil(t, err)
+
+	for range 20 {
+		s, err = d.Render("{{ randInt 1 3 }}", 0)
+		assert.Nil(t, err)
+		n, err := strconv.Atoi(s)
+		assert.Nil(t, err)
+		assert.True(t, n >= 1 && n <= 3)
+	}
+
+	s, err = d.Render("{{ randString 12 }}", 0)
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[a-zA-Z0-9]{12}$`), s)
+}
+
+func TestRenderErrors(t *testing.T) {
+
+	d := data.New()
+
+	_, err := d.Render("{{ .vars.MISSING }}", 0)
+	assert.NotNil(t, err)
+
+	_, err = d.Render("{{ randInt 10 1 }}", 0)
+	assert.NotNil(t, err)
+
+	_, err = d.Render("{{ nosuchfunc }}", 0)
+	assert.NotNil(t, err)
+}
+
+func TestCheckTemplate(t *testing.T) {
+
+	assert.Nil(t, data.CheckTemplate("plain"))
+	assert.Nil(t, data.CheckTemplate("{{ uuid }}"))
+	assert.NotNil(t, data.CheckTemplate("{{ uuid "))
+	assert.NotNil(t, data.CheckTemplate("{{ nosuchfunc }}"))
+}
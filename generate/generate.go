@@ -0,0 +1,484 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+// Package generate creates scenarios from OpenAPI documents.
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/pwmorreale/rapid/config"
+	"github.com/pwmorreale/rapid/openapi"
+)
+
+const (
+	// DefaultServerURL is used when the document has no absolute server URL.
+	DefaultServerURL = "http://localhost"
+
+	// Limit recursion when building examples from (possibly cyclic) schemas.
+	maxExampleDepth = 8
+)
+
+// Used to build request and response names.
+var nonAlnum = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// Used to match status code ranges, eg: 2XX
+var statusRange = regexp.MustCompile(`^[1-5][xX][xX]$`)
+
+// Used to locate path template parameters, eg: /users/{id}
+var templateParam = regexp.MustCompile(`\{([^}/]+)\}`)
+
+// Context defines a scenario generator.
+type Context struct {
+	specPath     string
+	doc          *openapi3.T
+	replacements map[string]string
+	warnings     []string
+}
+
+// New loads the OpenAPI document at specPath.
+func New(specPath string) (*Context, error) {
+
+	spec, err := openapi.New(specPath, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Context{
+		specPath:     specPath,
+		doc:          spec.Document(),
+		replacements: make(map[string]string),
+	}, nil
+}
+
+// Scenario creates a scenario with one request per operation.  Path
+// parameters remain in the URL as {name} and are filled in via find_replace.
+func (g *Context) Scenario() *config.Scenario {
+
+	sc := &config.Scenario{
+		Name:    slug(g.doc.Info.Title),
+		Version: g.doc.Info.Version,
+		Comment: fmt.Sprintf("Generated from %s", g.specPath),
+		OpenAPI: config.OpenAPIConfig{
+			Path: g.specPath,
+		},
+	}
+
+	sc.Sequence.Iterations = 1
+
+	server := g.serverURL()
+
+	paths := make([]string, 0, g.doc.Paths.Len())
+	for path := range g.doc.Paths.Map() {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		pathItem := g.doc.Paths.Value(path)
+
+		ops := pathItem.Operations()
+		methods := make([]string, 0, len(ops))
+		for method := range ops {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			request := g.request(server, path, method, pathItem, ops[method])
+			sc.Sequence.Requests = append(sc.Sequence.Requests, request)
+		}
+	}
+
+	names := make([]string, 0, len(g.replacements))
+	for name := range g.replacements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sc.Replacements = append(sc.Replacements, config.ReplaceData{
+			Regex: regexp.QuoteMeta("{" + name + "}"),
+			Value: g.replacements[name],
+		})
+	}
+
+	return sc
+}
+
+// Warnings returns the parts of the document that were not generated.
+func (g *Context) Warnings() []string {
+	return g.warnings
+}
+
+// serverURL returns the first absolute server URL in the document.
+func (g *Context) serverURL() string {
+
+	for _, server := range g.doc.Servers {
+		s := server.URL
+		for name, v := range server.Variables {
+			s = strings.ReplaceAll(s, "{"+name+"}", v.Default)
+		}
+
+		u, err := url.Parse(s)
+		if err == nil && u.IsAbs() {
+			return strings.TrimSuffix(s, "/")
+		}
+
+		// Relative server URL, eg: /v1
+		if err == nil && strings.HasPrefix(s, "/") {
+			return DefaultServerURL + strings.TrimSuffix(s, "/")
+		}
+	}
+
+	return DefaultServerURL
+}
+
+func (g *Context) request(server, path, method string, pathItem *openapi3.PathItem, op *openapi3.Operation) config.Request {
+
+	name := op.OperationID
+	if name == "" {
+		name = strings.ToLower(method) + "-" + slug(path)
+	}
+
+	request := config.Request{
+		Name:   name,
+		Method: strings.ToLower(method),
+		URL:    server + path,
+		ThunderingHerd: config.Stampede{
+			Max:  1,
+			Size: 1,
+		},
+	}
+
+	// Operation parameters override path level parameters of the same name.
+	params := map[string]*openapi3.Parameter{}
+	for _, p := range append(pathItem.Parameters, op.Parameters...) {
+		if p.Value != nil {
+			params[p.Value.In+":"+p.Value.Name] = p.Value
+		}
+	}
+
+	for _, m := range templateParam.FindAllStringSubmatch(path, -1) {
+		g.addReplacement(m[1], params[openapi3.ParameterInPath+":"+m[1]])
+	}
+
+	var query []string
+	for _, p := range sortedParameters(params, openapi3.ParameterInQuery) {
+		if p.Required {
+			query = append(query, url.QueryEscape(p.Name)+"={"+p.Name+"}")
+			g.addReplacement(p.Name, p)
+		}
+	}
+	if len(query) > 0 {
+		request.URL += "?" + strings.Join(query, "&")
+	}
+
+	for _, p := range sortedParameters(params, openapi3.ParameterInHeader) {
+		if p.Required {
+			request.ExtraHeaders = append(request.ExtraHeaders, config.HeaderData{
+				Name:  p.Name,
+				Value: fmt.Sprint(parameterExample(p)),
+			})
+		}
+	}
+
+	if op.RequestBody != nil && op.RequestBody.Value != nil {
+		mediaType, media := selectMedia(op.RequestBody.Value.Content)
+		if media != nil && isJSON(mediaType) {
+			b, err := json.Marshal(mediaExample(media))
+			if err == nil {
+				request.Content = string(b)
+				request.ContentType = mediaType
+			}
+		}
+	}
+
+	request.Responses = g.responses(name, op)
+
+	return request
+}
+
+// responses returns a response for each documented status code.  A range,
+// eg: 4XX, is represented by its first code, eg: 400, unless a code in the
+// range is documented.  The default response is represented by 200, unless
+// a success code is documented.
+func (g *Context) responses(name string, op *openapi3.Operation) []*config.Response {
+
+	var all []*config.Response
+
+	if op.Responses == nil {
+		return all
+	}
+
+	refs := map[int]*openapi3.ResponseRef{}
+	ranges := map[int]*openapi3.ResponseRef{}
+	var defaultRef *openapi3.ResponseRef
+
+	for key, ref := range op.Responses.Map() {
+		code, err := strconv.Atoi(key)
+		switch {
+		case err == nil:
+			refs[code] = ref
+		case key == "default":
+			defaultRef = ref
+		case statusRange.MatchString(key):
+			ranges[int(key[0]-'0')*100] = ref
+		default:
+			g.warnings = append(g.warnings, fmt.Sprintf("%s: unknown response %q is not generated", name, key))
+		}
+	}
+
+	for code, ref := range ranges {
+		if !documented(refs, code) {
+			refs[code] = ref
+		}
+	}
+
+	if defaultRef != nil {
+		if documented(refs, http.StatusOK) {
+			g.warnings = append(g.warnings, fmt.Sprintf("%s: default response is not generated, success responses are documented", name))
+		} else {
+			refs[http.StatusOK] = defaultRef
+		}
+	}
+
+	codes := make([]int, 0, len(refs))
+	for code := range refs {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	for _, code := range codes {
+
+		response := &config.Response{
+			Name:       slug(http.StatusText(code)),
+			StatusCode: code,
+		}
+		if response.Name == "" {
+			response.Name = strconv.Itoa(code)
+		}
+
+		ref := refs[code]
+		if ref != nil && ref.Value != nil {
+			mediaType, media := selectMedia(ref.Value.Content)
+			if media != nil {
+				response.Content.Expected = true
+				response.Content.MediaType = mediaType
+			}
+		}
+
+		all = append(all, response)
+	}
+
+	return all
+}
+
+// documented returns true if a code in the class of code, eg: 2xx, is
+// documented.
+func documented(refs map[int]*openapi3.ResponseRef, code int) bool {
+
+	for c := range refs {
+		if c/100 == code/100 {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Context) addReplacement(name string, p *openapi3.Parameter) {
+
+	if _, ok := g.replacements[name]; ok {
+		return
+	}
+
+	v := "1"
+	if p != nil {
+		v = fmt.Sprint(parameterExample(p))
+	}
+	g.replacements[name] = v
+}
+
+func sortedParameters(params map[string]*openapi3.Parameter, in string) []*openapi3.Parameter {
+
+	var all []*openapi3.Parameter
+	for _, p := range params {
+		if p.In == in {
+			all = append(all, p)
+		}
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// selectMedia prefers a JSON media type.
+func selectMedia(content openapi3.Content) (string, *openapi3.MediaType) {
+
+	types := make([]string, 0, len(content))
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+
+	for _, mediaType := range types {
+		if isJSON(mediaType) {
+			return mediaType, content[mediaType]
+		}
+	}
+
+	if len(types) > 0 {
+		return types[0], content[types[0]]
+	}
+
+	return "", nil
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func slug(s string) string {
+	return strings.Trim(strings.ToLower(nonAlnum.ReplaceAllString(s, "-")), "-")
+}
+
+func firstExample(examples openapi3.Examples) any {
+
+	names := make([]string, 0, len(examples))
+	for name := range examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if ex := examples[name]; ex != nil && ex.Value != nil && ex.Value.Value != nil {
+			return ex.Value.Value
+		}
+	}
+
+	return nil
+}
+
+func parameterExample(p *openapi3.Parameter) any {
+
+	if p.Example != nil {
+		return p.Example
+	}
+
+	if ex := firstExample(p.Examples); ex != nil {
+		return ex
+	}
+
+	if v := schemaExample(p.Schema, 0); v != nil {
+		return v
+	}
+
+	return "1"
+}
+
+func mediaExample(m *openapi3.MediaType) any {
+
+	if m.Example != nil {
+		return m.Example
+	}
+
+	if ex := firstExample(m.Examples); ex != nil {
+		return ex
+	}
+
+	return schemaExample(m.Schema, 0)
+}
+
+// schemaExample builds an example value from a schema.
+func schemaExample(ref *openapi3.SchemaRef, depth int) any {
+
+	if ref == nil || ref.Value == nil || depth > maxExampleDepth {
+		return nil
+	}
+
+	s := ref.Value
+
+	switch {
+	case s.Example != nil:
+		return s.Example
+	case s.Default != nil:
+		return s.Default
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	case len(s.AllOf) > 0:
+		all := map[string]any{}
+		for _, sub := range s.AllOf {
+			if m, ok := schemaExample(sub, depth+1).(map[string]any); ok {
+				for k, v := range m {
+					all[k] = v
+				}
+			}
+		}
+		return all
+	case len(s.OneOf) > 0:
+		return schemaExample(s.OneOf[0], depth+1)
+	case len(s.AnyOf) > 0:
+		return schemaExample(s.AnyOf[0], depth+1)
+	}
+
+	switch {
+	case s.Type.Is(openapi3.TypeObject) || len(s.Properties) > 0:
+		obj := map[string]any{}
+		for name, prop := range s.Properties {
+			if v := schemaExample(prop, depth+1); v != nil {
+				obj[name] = v
+			}
+		}
+		return obj
+	case s.Type.Is(openapi3.TypeArray):
+		if v := schemaExample(s.Items, depth+1); v != nil {
+			return []any{v}
+		}
+		return []any{}
+	case s.Type.Is(openapi3.TypeInteger):
+		if s.Min != nil {
+			return int64(*s.Min)
+		}
+		return 1
+	case s.Type.Is(openapi3.TypeNumber):
+		if s.Min != nil {
+			return *s.Min
+		}
+		return 1.5
+	case s.Type.Is(openapi3.TypeBoolean):
+		return true
+	case s.Type.Is(openapi3.TypeString):
+		return stringExample(s.Format)
+	}
+
+	return nil
+}
+
+func stringExample(format string) string {
+
+	switch format {
+	case "date-time":
+		return "2025-01-01T00:00:00Z"
+	case "date":
+		return "2025-01-01"
+	case "email":
+		return "user@example.com"
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000001"
+	case "uri", "url":
+		return "https://example.com"
+	case "ipv4":
+		return "192.0.2.1"
+	}
+
+	return "string"
+}
@@ -0,0 +1,115 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+// Package generate_test contains unit tests for the generate module.
+package generate_test
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/pwmorreale/rapid/config"
+	"github.com/pwmorreale/rapid/generate"
+	"github.com/pwmorreale/rapid/logger"
+	"github.com/pwmorreale/rapid/verify"
+	"github.com/stretchr/testify/assert"
+	"go.yaml.in/yaml/v3"
+)
+
+const specFile = "../testdata/openapi/painters.yaml"
+
+func initLogger(wr io.Writer) {
+
+	opts := logger.Options{
+		Handler:   "text",
+		Timestamp: false,
+		Level:     "Info",
+		Writer:    wr,
+	}
+
+	logger.Init(&opts)
+}
+
+func TestScenario(t *testing.T) {
+
+	g, err := generate.New(specFile)
+	assert.Nil(t, err)
+
+	sc := g.Scenario()
+	assert.Equal(t, "painters", sc.Name)
+	assert.Equal(t, "1.0", sc.Version)
+	assert.Equal(t, specFile, sc.OpenAPI.Path)
+	assert.Equal(t, 1, sc.Sequence.Iterations)
+	assert.Equal(t, 6, len(sc.Sequence.Requests))
+
+	request := sc.Sequence.Requests[1]
+	assert.Equal(t, "post-painters", request.Name)
+	assert.Equal(t, "post", request.Method)
+	assert.Equal(t, "https://bob_ross.com/v1/painters", request.URL)
+	assert.Equal(t, "application/json", request.ContentType)
+	assert.JSONEq(t, `{"happy":true,"id":1,"name":"string"}`, request.Content)
+	assert.Equal(t, 201, request.Responses[0].StatusCode)
+	assert.False(t, request.Responses[0].Content.Expected)
+
+	request = sc.Sequence.Requests[3]
+	assert.Equal(t, "https://bob_ross.com/v1/painters/{id}", request.URL)
+	assert.Equal(t, 2, len(request.Responses))
+	assert.Equal(t, "ok", request.Responses[0].Name)
+	assert.Equal(t, "application/json", request.Responses[0].Content.MediaType)
+	assert.Equal(t, "not-found", request.Responses[1].Name)
+
+	assert.Equal(t, []config.ReplaceData{{Regex: `\{id\}`, Value: "1"}}, sc.Replacements)
+
+	// Only a default response, which is a success...
+	request = sc.Sequence.Requests[4]
+	assert.Equal(t, "get-status", request.Name)
+	assert.Equal(t, 1, len(request.Responses))
+	assert.Equal(t, 200, request.Responses[0].StatusCode)
+	assert.True(t, request.Responses[0].Content.Expected)
+
+	// Ranges are represented by their first code...
+	request = sc.Sequence.Requests[5]
+	assert.Equal(t, 2, len(request.Responses))
+	assert.Equal(t, 204, request.Responses[0].StatusCode)
+	assert.Equal(t, 400, request.Responses[1].StatusCode)
+
+	assert.Equal(t, []string{"put-status: default response is not generated, success responses are documented"}, g.Warnings())
+}
+
+func TestRoundTrip(t *testing.T) {
+
+	g, err := generate.New(specFile)
+	assert.Nil(t, err)
+
+	blob, err := yaml.Marshal(g.Scenario())
+	assert.Nil(t, err)
+
+	f, err := os.CreateTemp("", "rapid-generate-*.yaml")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(blob)
+	assert.Nil(t, err)
+	f.Close()
+
+	c := config.New()
+	sc, err := c.ParseFile(f.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(sc.Sequence.Requests))
+	assert.NotNil(t, sc.OpenAPI.Spec)
+
+	initLogger(io.Discard)
+
+	err = verify.Check(f.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, logger.WarnCount())
+}
+
+func TestNewBadSpec(t *testing.T) {
+
+	g, err := generate.New("../testdata/openapi/no-such-spec.yaml")
+	assert.Nil(t, g)
+	assert.NotNil(t, err)
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/push"
 	"github.com/pwmorreale/rapid/config"
+	"github.com/pwmorreale/rapid/stats"
 )
 
 const (
@@ -28,9 +29,10 @@ const (
 
 // Metrics defines the interface.
 type Metrics interface {
-	Requests(int, string, string, string)
-	Errors(int, string, string)
-	Durations(time.Time, int, string, string, string, string)
+	Requests(int, string, string, string, string, string)
+	Errors(int, string, string, string, string)
+	Durations(time.Time, int, string, string, string, string, string, string)
+	Phases(int, string, string, string, *stats.Timings)
 	Push() error
 }
 
@@ -42,6 +44,7 @@ type Context struct {
 	requests  *prometheus.CounterVec
 	errors    *prometheus.CounterVec
 	durations *prometheus.HistogramVec
+	phases    map[stats.Phase]*prometheus.HistogramVec
 	sc        *config.Scenario
 }
 
@@ -65,9 +68,9 @@ func New(sc *config.Scenario) *Context {
 			Namespace: namespace,
 			Subsystem: sc.Name,
 			Name:      "responses",
-			Help:      "How many HTTP Responses processed, partitioned by iteration, request name, response name, and status code",
+			Help:      "How many HTTP Responses processed, partitioned by iteration, sequence, stage, request name, response name, and status code",
 		},
-		[]string{"iteration", "request", "response", "code"},
+		[]string{"iteration", "sequence", "stage", "request", "response", "code"},
 	)
 
 	ctx.Reg.MustRegister(ctx.requests)
@@ -77,9 +80,9 @@ func New(sc *config.Scenario) *Context {
 			Namespace: namespace,
 			Subsystem: sc.Name,
 			Name:      "errors",
-			Help:      "How many HTTP client/transmission errors, partitioned by iteration and request name",
+			Help:      "How many HTTP client/transmission errors, partitioned by iteration, sequence, stage, and request name",
 		},
-		[]string{"iteration", "request", "response"},
+		[]string{"iteration", "sequence", "stage", "request", "response"},
 	)
 
 	ctx.Reg.MustRegister(ctx.errors)
@@ -104,37 +107,69 @@ func New(sc *config.Scenario) *Context {
 			Namespace: namespace,
 			Subsystem: sc.Name,
 			Name:      "requests",
-			Help:      "Time durations (in milliseconds) for HTTP Requests, partitioned by iteration, request name, and method",
+			Help:      "Time durations (in milliseconds) for HTTP Requests, partitioned by iteration, sequence, stage, request name, and method",
 			Buckets:   prometheus.ExponentialBucketsRange(float64(minBucket), float64(maxBucket), count),
 		},
-		[]string{"iteration", "request", "method", "response", "status"},
+		[]string{"iteration", "sequence", "stage", "request", "method", "response", "status"},
 	)
 	ctx.Reg.MustRegister(ctx.durations)
 
+	ctx.phases = make(map[stats.Phase]*prometheus.HistogramVec)
+	for _, phase := range stats.Phases() {
+		ctx.phases[phase] = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Subsystem: sc.Name,
+				Name:      phase.String() + "_phase",
+				Help:      fmt.Sprintf("Time durations (in milliseconds) for the %s phase of HTTP Requests, partitioned by iteration, sequence, stage, and request name", phase),
+				Buckets:   prometheus.ExponentialBucketsRange(float64(minBucket), float64(maxBucket), count),
+			},
+			[]string{"iteration", "sequence", "stage", "request"},
+		)
+		ctx.Reg.MustRegister(ctx.phases[phase])
+	}
+
 	return ctx
 }
 
-// Requests is the counter for requests made.
-func (p *Context) Requests(iteration int, requestName, responseName, status string) {
+// Requests is the counter for requests made.  The sequence is empty unless
+// the scenario has named sequences, and the stage is empty unless the
+// request has a staged thundering herd.
+func (p *Context) Requests(iteration int, sequence, stage, requestName, responseName, status string) {
 
 	if p.Reg != nil {
-		p.requests.WithLabelValues(strconv.Itoa(iteration), requestName, responseName, status).Add(1)
+		p.requests.WithLabelValues(strconv.Itoa(iteration), sequence, stage, requestName, responseName, status).Add(1)
 	}
 }
 
 // Errors is the counter for errors.
-func (p *Context) Errors(iteration int, requestName, responseName string) {
+func (p *Context) Errors(iteration int, sequence, stage, requestName, responseName string) {
 
 	if p.Reg != nil {
-		p.errors.WithLabelValues(strconv.Itoa(iteration), requestName, responseName).Add(1)
+		p.errors.WithLabelValues(strconv.Itoa(iteration), sequence, stage, requestName, responseName).Add(1)
 	}
 }
 
 // Durations records request durations in the histogram.
-func (p *Context) Durations(start time.Time, iteration int, requestName, method, responseName, status string) {
+func (p *Context) Durations(start time.Time, iteration int, sequence, stage, requestName, method, responseName, status string) {
 
 	if p.Reg != nil {
-		p.durations.WithLabelValues(strconv.Itoa(iteration), requestName, method, responseName, status).Observe(float64(time.Since(start).Milliseconds()))
+		p.durations.WithLabelValues(strconv.Itoa(iteration), sequence, stage, requestName, method, responseName, status).Observe(float64(time.Since(start).Milliseconds()))
+	}
+}
+
+// Phases records the phase durations of a request in the phase histograms.
+// Phases that did not occur (eg: DNS on a reused connection) are skipped.
+func (p *Context) Phases(iteration int, sequence, stage, requestName string, timings *stats.Timings) {
+
+	if p.Reg == nil {
+		return
+	}
+
+	for _, phase := range stats.Phases() {
+		if d := timings[phase]; d > 0 {
+			p.phases[phase].WithLabelValues(strconv.Itoa(iteration), sequence, stage, requestName).Observe(float64(d) / float64(time.Millisecond))
+		}
 	}
 }
 
@@ -11,6 +11,7 @@ import (
 	"github.com/prometheus/common/expfmt"
 	"github.com/pwmorreale/rapid/config"
 	"github.com/pwmorreale/rapid/metrics"
+	"github.com/pwmorreale/rapid/stats"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -59,13 +60,13 @@ func TestMetrics(t *testing.T) {
 	pc := metrics.New(sc)
 	assert.NotNil(t, pc)
 
-	pc.Requests(1, "req1", "resp1", "200")
-	pc.Errors(0, "req1", "resp1")
+	pc.Requests(1, "", "", "req1", "resp1", "200")
+	pc.Errors(0, "", "", "req1", "resp1")
 
 	start := time.Now()
 	time.Sleep(time.Millisecond * 10)
 
-	pc.Durations(start, 1, "req2", "GET", "resp3", "201")
+	pc.Durations(start, 1, "", "", "req2", "GET", "resp3", "201")
 
 	mfs, err := pc.Reg.Gather()
 	assert.Nil(t, err)
@@ -138,13 +139,13 @@ func TestTLSMetrics(t *testing.T) {
 	pc := metrics.New(sc)
 	assert.NotNil(t, pc)
 
-	pc.Requests(1, "req1", "resp1", "200")
-	pc.Errors(0, "req1", "resp1")
+	pc.Requests(1, "", "", "req1", "resp1", "200")
+	pc.Errors(0, "", "", "req1", "resp1")
 
 	start := time.Now()
 	time.Sleep(time.Millisecond * 10)
 
-	pc.Durations(start, 1, "req2", "GET", "resp3", "201")
+	pc.Durations(start, 1, "", "", "req2", "GET", "resp3", "201")
 
 	mfs, err := pc.Reg.Gather()
 	assert.Nil(t, err)
@@ -182,14 +183,105 @@ func TestNoPrometheus(t *testing.T) {
 
 	assert.Nil(t, pc.Reg)
 
-	pc.Requests(1, "req1", "resp1", "200")
-	pc.Errors(0, "req1", "resp1")
+	pc.Requests(1, "", "", "req1", "resp1", "200")
+	pc.Errors(0, "", "", "req1", "resp1")
 
 	start := time.Now()
 	time.Sleep(time.Millisecond * 10)
 
-	pc.Durations(start, 1, "req2", "GET", "resp3", "201")
+	pc.Durations(start, 1, "", "", "req2", "GET", "resp3", "201")
+	pc.Phases(1, "", "", "req2", &stats.Timings{stats.PhaseTTFB: time.Millisecond})
 
 	err = pc.Push()
 	assert.Nil(t, err)
 }
+
+func TestStageLabel(t *testing.T) {
+
+	c := config.New()
+	sc, err := c.ParseFile("../testdata/configs/test_scenario.yaml")
+	assert.Nil(t, err)
+
+	sc.Prom.PushURL = "http://localhost"
+
+	pc := metrics.New(sc)
+	assert.NotNil(t, pc.Reg)
+
+	pc.Requests(1, "", "peak", "req1", "resp1", "200")
+
+	mfs, err := pc.Reg.Gather()
+	assert.Nil(t, err)
+
+	found := false
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "stage" && l.GetValue() == "peak" {
+					found = true
+				}
+			}
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestSequenceLabel(t *testing.T) {
+
+	c := config.New()
+	sc, err := c.ParseFile("../testdata/configs/test_scenario.yaml")
+	assert.Nil(t, err)
+
+	sc.Prom.PushURL = "http://localhost"
+
+	pc := metrics.New(sc)
+	assert.NotNil(t, pc.Reg)
+
+	pc.Requests(1, "checkout", "", "req1", "resp1", "200")
+	pc.Errors(1, "checkout", "", "req1", "resp1")
+	pc.Durations(time.Now(), 1, "checkout", "", "req1", "GET", "resp1", "200")
+
+	mfs, err := pc.Reg.Gather()
+	assert.Nil(t, err)
+
+	found := 0
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "sequence" && l.GetValue() == "checkout" {
+					found++
+				}
+			}
+		}
+	}
+	assert.Equal(t, 3, found)
+}
+
+func TestPhases(t *testing.T) {
+
+	c := config.New()
+	sc, err := c.ParseFile("../testdata/configs/test_scenario.yaml")
+	assert.Nil(t, err)
+
+	sc.Prom.PushURL = "http://localhost"
+
+	pc := metrics.New(sc)
+	assert.NotNil(t, pc.Reg)
+
+	pc.Phases(1, "", "", "req1", &stats.Timings{stats.PhaseConnect: time.Millisecond, stats.PhaseTTFB: 5 * time.Millisecond})
+
+	mfs, err := pc.Reg.Gather()
+	assert.Nil(t, err)
+
+	observed := map[string]uint64{}
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			if h := m.GetHistogram(); h != nil {
+				observed[mf.GetName()] += h.GetSampleCount()
+			}
+		}
+	}
+
+	assert.Equal(t, uint64(1), observed["rapid_"+sc.Name+"_connect_phase"])
+	assert.Equal(t, uint64(1), observed["rapid_"+sc.Name+"_ttfb_phase"])
+	assert.Equal(t, uint64(0), observed["rapid_"+sc.Name+"_dns_phase"])
+}
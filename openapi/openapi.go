@@ -0,0 +1,304 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+// Package openapi verifies REST traffic against an OpenAPI v3 document.
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+)
+
+// ErrUndocumentedStatus is returned when a response status code is not
+// documented for the operation.
+var ErrUndocumentedStatus = errors.New("undocumented status code")
+
+// Used to locate path template parameters, eg: /users/{id}
+var templateParam = regexp.MustCompile(`\{([^}/]+)\}`)
+
+// route defines a single operation and its path matcher.
+type route struct {
+	method   string
+	template string
+	regx     *regexp.Regexp
+	params   []string
+	pathItem *openapi3.PathItem
+	op       *openapi3.Operation
+}
+
+// Context defines a loaded OpenAPI document.
+type Context struct {
+	doc    *openapi3.T
+	routes []route
+	opts   *openapi3filter.Options
+}
+
+// New loads and validates the OpenAPI document at path.  Request URL paths
+// are matched after removing basePath.  If basePath is empty, the path
+// portion of each server URL in the document is tried instead.
+func New(path, basePath string) (*Context, error) {
+
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+
+	doc, err := loader.LoadFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = doc.Validate(loader.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Context{
+		doc: doc,
+		opts: &openapi3filter.Options{
+			MultiError:         true,
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	}
+
+	bases := []string{basePath}
+	if basePath == "" {
+		bases = serverPaths(doc)
+	}
+
+	err = c.buildRoutes(bases)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Document returns the loaded OpenAPI document.
+func (c *Context) Document() *openapi3.T {
+	return c.doc
+}
+
+// serverPaths returns the path portion of each server URL.
+func serverPaths(doc *openapi3.T) []string {
+
+	var all []string
+	for _, server := range doc.Servers {
+		s := server.URL
+		for name, v := range server.Variables {
+			s = strings.ReplaceAll(s, "{"+name+"}", v.Default)
+		}
+
+		u, err := url.Parse(s)
+		if err != nil {
+			continue
+		}
+
+		p := strings.TrimSuffix(u.Path, "/")
+		if p != "" {
+			all = append(all, p)
+		}
+	}
+
+	return all
+}
+
+func (c *Context) buildRoutes(bases []string) error {
+
+	var prefix []string
+	for _, b := range bases {
+		b = strings.TrimSuffix(b, "/")
+		if b != "" {
+			prefix = append(prefix, regexp.QuoteMeta(b))
+		}
+	}
+
+	base := ""
+	if len(prefix) > 0 {
+		base = "(?:" + strings.Join(prefix, "|") + ")?"
+	}
+
+	for template, pathItem := range c.doc.Paths.Map() {
+
+		var params []string
+		expr := "^" + base
+		last := 0
+		for _, m := range templateParam.FindAllStringSubmatchIndex(template, -1) {
+			expr += regexp.QuoteMeta(template[last:m[0]]) + "([^/]+)"
+			params = append(params, template[m[2]:m[3]])
+			last = m[1]
+		}
+		expr += regexp.QuoteMeta(template[last:]) + "$"
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("openapi: path %s: %w", template, err)
+		}
+
+		for method, op := range pathItem.Operations() {
+			c.routes = append(c.routes, route{
+				method:   method,
+				template: template,
+				regx:     re,
+				params:   params,
+				pathItem: pathItem,
+				op:       op,
+			})
+		}
+	}
+
+	// Literal paths take precedence over templated ones, eg: /users/me before /users/{id}
+	sort.Slice(c.routes, func(i, j int) bool {
+		if len(c.routes[i].params) != len(c.routes[j].params) {
+			return len(c.routes[i].params) < len(c.routes[j].params)
+		}
+		if len(c.routes[i].template) != len(c.routes[j].template) {
+			return len(c.routes[i].template) > len(c.routes[j].template)
+		}
+		if c.routes[i].template != c.routes[j].template {
+			return c.routes[i].template < c.routes[j].template
+		}
+		return c.routes[i].method < c.routes[j].method
+	})
+
+	return nil
+}
+
+// Find returns the operation matching the method and URL, along with the
+// values of any path parameters.
+func (c *Context) Find(method string, u *url.URL) (*routers.Route, map[string]string, error) {
+
+	method = strings.ToUpper(method)
+
+	for i := range c.routes {
+		rt := &c.routes[i]
+		if rt.method != method {
+			continue
+		}
+
+		m := rt.regx.FindStringSubmatch(u.Path)
+		if m == nil {
+			continue
+		}
+
+		params := make(map[string]string, len(rt.params))
+		for n, name := range rt.params {
+			params[name] = m[n+1]
+		}
+
+		return &routers.Route{
+			Spec:      c.doc,
+			Path:      rt.template,
+			PathItem:  rt.pathItem,
+			Method:    method,
+			Operation: rt.op,
+		}, params, nil
+	}
+
+	return nil, nil, fmt.Errorf("openapi: no operation found for %s %s", method, u.Path)
+}
+
+// Validate verifies the request and response against the matching operation.
+// Every violation is returned as a separate error joined with errors.Join.
+func (c *Context) Validate(ctx context.Context, req *http.Request, resp *http.Response, body []byte) error {
+
+	rt, params, err := c.Find(req.Method, req.URL)
+	if err != nil {
+		return err
+	}
+
+	// The original body was consumed when the request was sent.
+	rq := req.Clone(ctx)
+	rq.Body = http.NoBody
+	if req.GetBody != nil {
+		rq.Body, err = req.GetBody()
+		if err != nil {
+			return err
+		}
+	}
+
+	input := &openapi3filter.RequestValidationInput{
+		Request:    rq,
+		PathParams: params,
+		Route:      rt,
+		Options:    c.opts,
+	}
+
+	var all []error
+
+	err = openapi3filter.ValidateRequest(ctx, input)
+	if err != nil {
+		all = append(all, violations("request", err)...)
+	}
+
+	responses := rt.Operation.Responses
+	if responses.Status(resp.StatusCode) == nil && responses.Default() == nil {
+		all = append(all, fmt.Errorf("openapi: %w %d for %s %s", ErrUndocumentedStatus, resp.StatusCode, rt.Method, rt.Path))
+		return errors.Join(all...)
+	}
+
+	output := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 resp.StatusCode,
+		Header:                 resp.Header,
+		Body:                   io.NopCloser(bytes.NewReader(body)),
+		Options:                c.opts,
+	}
+
+	err = openapi3filter.ValidateResponse(ctx, output)
+	if err != nil {
+		all = append(all, violations("response", err)...)
+	}
+
+	return errors.Join(all...)
+}
+
+// violations flattens validation errors into one error per violation.
+func violations(label string, err error) []error {
+
+	var all []error
+
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, me := range e {
+			all = append(all, violations(label, me)...)
+		}
+	case *openapi3filter.RequestError:
+		switch {
+		case e.Parameter != nil:
+			label = fmt.Sprintf("request %s parameter %q", e.Parameter.In, e.Parameter.Name)
+		case e.RequestBody != nil:
+			label = "request body"
+		}
+		if e.Err == nil {
+			return []error{fmt.Errorf("openapi: %s: %s", label, e.Reason)}
+		}
+		all = violations(label, e.Err)
+	case *openapi3filter.ResponseError:
+		if e.Reason != "" {
+			label = e.Reason
+		}
+		if e.Err == nil {
+			return []error{fmt.Errorf("openapi: %s", label)}
+		}
+		all = violations(label, e.Err)
+	case *openapi3.SchemaError:
+		pointer := "/" + strings.Join(e.JSONPointer(), "/")
+		all = append(all, fmt.Errorf("openapi: %s: %s: %s", label, pointer, e.Reason))
+	default:
+		all = append(all, fmt.Errorf("openapi: %s: %v", label, err))
+	}
+
+	return all
+}
@@ -0,0 +1,169 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+// Package openapi_test contains unit tests for the openapi module.
+package openapi_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pwmorreale/rapid/openapi"
+	"github.com/stretchr/testify/assert"
+)
+
+const specFile = "../testdata/openapi/painters.yaml"
+
+func newRequest(t *testing.T, method, u, body string) *http.Request {
+
+	var rdr io.Reader = http.NoBody
+	if body != "" {
+		rdr = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, u, rdr)
+	assert.Nil(t, err)
+
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req
+}
+
+func newResponse(status int, body string, headers map[string]string) *http.Response {
+
+	resp := &http.Response{
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+	}
+
+	if body != "" {
+		resp.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		resp.Header.Set(k, v)
+	}
+	return resp
+}
+
+func TestNew(t *testing.T) {
+
+	spec, err := openapi.New(specFile, "")
+	assert.Nil(t, err)
+	assert.NotNil(t, spec)
+	assert.Equal(t, "Painters", spec.Document().Info.Title)
+
+	spec, err = openapi.New("../testdata/openapi/no-such-spec.yaml", "")
+	assert.Nil(t, spec)
+	assert.NotNil(t, err)
+}
+
+func TestFind(t *testing.T) {
+
+	spec, err := openapi.New(specFile, "")
+	assert.Nil(t, err)
+
+	u, _ := url.Parse("https://localhost:8080/v1/painters/42")
+	rt, params, err := spec.Find("get", u)
+	assert.Nil(t, err)
+	assert.Equal(t, "/painters/{id}", rt.Path)
+	assert.Equal(t, "42", params["id"])
+
+	// Literal paths take precedence...
+	u, _ = url.Parse("https://localhost:8080/v1/painters/me")
+	rt, _, err = spec.Find("GET", u)
+	assert.Nil(t, err)
+	assert.Equal(t, "/painters/me", rt.Path)
+
+	// Server base path is optional...
+	u, _ = url.Parse("https://localhost:8080/painters")
+	rt, _, err = spec.Find("POST", u)
+	assert.Nil(t, err)
+	assert.Equal(t, "/painters", rt.Path)
+
+	u, _ = url.Parse("https://localhost:8080/v1/painters/42")
+	_, _, err = spec.Find("DELETE", u)
+	assert.Equal(t, "openapi: no operation found for DELETE /v1/painters/42", err.Error())
+}
+
+func TestFindBasePath(t *testing.T) {
+
+	spec, err := openapi.New(specFile, "/api/v2")
+	assert.Nil(t, err)
+
+	u, _ := url.Parse("https://localhost:8080/api/v2/painters")
+	rt, _, err := spec.Find("GET", u)
+	assert.Nil(t, err)
+	assert.Equal(t, "/painters", rt.Path)
+
+	u, _ = url.Parse("https://localhost:8080/v1/painters")
+	_, _, err = spec.Find("GET", u)
+	assert.NotNil(t, err)
+}
+
+func TestValidate(t *testing.T) {
+
+	spec, err := openapi.New(specFile, "")
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+
+	body := `{"id": 42, "name": "Bob Ross", "happy": true}`
+	req := newRequest(t, "GET", "https://bob_ross.com/v1/painters/42", "")
+	err = spec.Validate(ctx, req, newResponse(200, body, nil), []byte(body))
+	assert.Nil(t, err)
+
+	req = newRequest(t, "POST", "https://bob_ross.com/v1/painters", `{"name": "Bob Ross"}`)
+	err = spec.Validate(ctx, req, newResponse(201, "", map[string]string{"Location": "/v1/painters/42"}), nil)
+	assert.Nil(t, err)
+}
+
+func TestValidateViolations(t *testing.T) {
+
+	spec, err := openapi.New(specFile, "")
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+
+	// Bad path parameter and a response body with two violations.
+	body := `{"id": "forty-two", "happy": "very"}`
+	req := newRequest(t, "GET", "https://bob_ross.com/v1/painters/forty-two", "")
+	err = spec.Validate(ctx, req, newResponse(200, body, nil), []byte(body))
+	assert.NotNil(t, err)
+
+	all := err.(interface{ Unwrap() []error }).Unwrap()
+	assert.Equal(t, 4, len(all))
+	assert.Contains(t, all[0].Error(), `openapi: request path parameter "id"`)
+	assert.Contains(t, err.Error(), "/happy:")
+	assert.Contains(t, err.Error(), "/id:")
+
+	// Missing request body and required response header.
+	req = newRequest(t, "POST", "https://bob_ross.com/v1/painters", "")
+	err = spec.Validate(ctx, req, newResponse(201, "", nil), nil)
+	assert.Contains(t, err.Error(), "openapi: request body:")
+	assert.Contains(t, err.Error(), "Location")
+}
+
+func TestValidateUndocumented(t *testing.T) {
+
+	spec, err := openapi.New(specFile, "")
+	assert.Nil(t, err)
+
+	req := newRequest(t, "GET", "https://bob_ross.com/v1/painters", "")
+	err = spec.Validate(context.Background(), req, newResponse(418, "", nil), nil)
+	assert.True(t, errors.Is(err, openapi.ErrUndocumentedStatus))
+	assert.Contains(t, err.Error(), "undocumented status code 418 for GET /painters")
+
+	req = newRequest(t, "GET", "https://bob_ross.com/v1/easels", "")
+	err = spec.Validate(context.Background(), req, newResponse(200, "", nil), nil)
+	assert.False(t, errors.Is(err, openapi.ErrUndocumentedStatus))
+	assert.Contains(t, err.Error(), "no operation found")
+}
@@ -10,41 +10,221 @@ import (
 	"encoding/xml"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/pwmorreale/rapid/config"
+	"github.com/pwmorreale/rapid/data"
+	"github.com/pwmorreale/rapid/stats"
 )
 
 // RequestResult holds results for a single request.
 type RequestResult struct {
-	Name     string           `json:"name" xml:"name,attr"`
-	Method   string           `json:"method" xml:"method,attr"`
-	Count    int64            `json:"count" xml:"count,attr"`
-	Errors   int64            `json:"errors" xml:"errors,attr"`
-	MinTime  string           `json:"min_time" xml:"min-time,attr"`
-	MaxTime  string           `json:"max_time" xml:"max-time,attr"`
-	AvgTime  string           `json:"avg_time" xml:"avg-time,attr"`
-	Responses []ResponseResult `json:"responses" xml:"response"`
+	Name       string            `json:"name" xml:"name,attr"`
+	Sequence   string            `json:"sequence,omitempty" xml:"sequence,attr,omitempty"`
+	Method     string            `json:"method" xml:"method,attr"`
+	Count      int64             `json:"count" xml:"count,attr"`
+	Errors     int64             `json:"errors" xml:"errors,attr"`
+	MinTime    string            `json:"min_time" xml:"min-time,attr"`
+	MaxTime    string            `json:"max_time" xml:"max-time,attr"`
+	AvgTime    string            `json:"avg_time" xml:"avg-time,attr"`
+	P50        string            `json:"p50" xml:"p50,attr"`
+	P90        string            `json:"p90" xml:"p90,attr"`
+	P99        string            `json:"p99" xml:"p99,attr"`
+	P999       string            `json:"p99_9" xml:"p99-9,attr"`
+	StdDev     string            `json:"std_dev" xml:"std-dev,attr"`
+	Dropped    int64             `json:"dropped,omitempty" xml:"dropped,attr,omitempty"`
+	Phases     []PhaseResult     `json:"phases,omitempty" xml:"phase,omitempty"`
+	Stages     []StageResult     `json:"stages,omitempty" xml:"stage,omitempty"`
+	Poll       *PollResult       `json:"poll,omitempty" xml:"poll,omitempty"`
+	Thresholds []ThresholdResult `json:"thresholds,omitempty" xml:"threshold,omitempty"`
+	Responses  []ResponseResult  `json:"responses" xml:"response"`
+}
+
+// SequenceResult holds the results of a named sequence.  Times are for
+// whole iterations of the sequence.
+type SequenceResult struct {
+	Name          string `json:"name" xml:"name,attr"`
+	Weight        int    `json:"weight,omitempty" xml:"weight,attr,omitempty"`
+	Users         int    `json:"users" xml:"users,attr"`
+	Iterations    int    `json:"iterations" xml:"iterations,attr"`
+	Count         int64  `json:"count" xml:"count,attr"`
+	Errors        int64  `json:"errors" xml:"errors,attr"`
+	MinTime       string `json:"min_time" xml:"min-time,attr"`
+	MaxTime       string `json:"max_time" xml:"max-time,attr"`
+	AvgTime       string `json:"avg_time" xml:"avg-time,attr"`
+	P50           string `json:"p50" xml:"p50,attr"`
+	P90           string `json:"p90" xml:"p90,attr"`
+	P99           string `json:"p99" xml:"p99,attr"`
+	Requests      int64  `json:"requests" xml:"requests,attr"`
+	RequestErrors int64  `json:"request_errors" xml:"request-errors,attr"`
+}
+
+// sequenceResults returns the results of each named sequence, and of the
+// setup and teardown phases, if any.
+func sequenceResults(sc *config.Scenario) []SequenceResult {
+	var all []SequenceResult
+	users := sc.SequenceUsers()
+	for i, seq := range sc.AllSequences() {
+		if seq.Name != "" {
+			all = append(all, sequenceResult(seq, users[i]))
+		}
+	}
+
+	for _, seq := range sc.Phases() {
+		if len(seq.Requests) > 0 {
+			all = append(all, sequenceResult(seq, 1))
+		}
+	}
+
+	return all
+}
+
+// iterations returns the iterations of every user of every sequence.
+func iterations(sc *config.Scenario) int {
+	n := 0
+	users := sc.SequenceUsers()
+	for i, seq := range sc.AllSequences() {
+		n += seq.Iterations * users[i]
+	}
+	return n
+}
+
+func sequenceResult(seq *config.Sequence, users int) SequenceResult {
+	sr := SequenceResult{
+		Name:       seq.Name,
+		Weight:     seq.Weight,
+		Users:      users,
+		Iterations: seq.Iterations,
+		Count:      seq.Stats.GetCount(),
+		Errors:     seq.Stats.GetErrors(),
+		MinTime:    seq.Stats.GetMinDuration().String(),
+		MaxTime:    seq.Stats.GetMaxDuration().String(),
+		AvgTime:    avgDuration(seq.Stats.GetDuration(), seq.Stats.GetCount()+seq.Stats.GetErrors()),
+		P50:        seq.Stats.Percentile(50).String(),
+		P90:        seq.Stats.Percentile(90).String(),
+		P99:        seq.Stats.Percentile(99).String(),
+	}
+	for j := range seq.Requests {
+		sr.Requests += seq.Requests[j].Stats.GetCount() + seq.Requests[j].Stats.GetErrors()
+		sr.RequestErrors += seq.Requests[j].Stats.GetErrors()
+	}
+
+	return sr
+}
+
+// PollResult holds the results of polling a request.  Times are the total
+// wait until the poll condition was met, or not.
+type PollResult struct {
+	Count   int64  `json:"count" xml:"count,attr"`
+	Errors  int64  `json:"errors" xml:"errors,attr"`
+	Polls   int64  `json:"polls" xml:"polls,attr"`
+	MinTime string `json:"min_time" xml:"min-time,attr"`
+	MaxTime string `json:"max_time" xml:"max-time,attr"`
+	AvgTime string `json:"avg_time" xml:"avg-time,attr"`
+	P90     string `json:"p90" xml:"p90,attr"`
+}
+
+// pollResult returns the polling results, or nil if the request is not polled.
+func pollResult(req *config.Request) *PollResult {
+	if req.Poll == nil {
+		return nil
+	}
+	p := &req.Polling
+	return &PollResult{
+		Count:   p.GetCount(),
+		Errors:  p.GetErrors(),
+		Polls:   p.GetPolls(),
+		MinTime: p.GetMinDuration().String(),
+		MaxTime: p.GetMaxDuration().String(),
+		AvgTime: avgDuration(p.GetDuration(), p.GetCount()+p.GetErrors()),
+		P90:     p.Percentile(90).String(),
+	}
+}
+
+// StageResult holds results for a single stage of a staged request.
+type StageResult struct {
+	Name     string  `json:"name" xml:"name,attr"`
+	Size     int     `json:"concurrent_requests,omitempty" xml:"concurrent-requests,attr,omitempty"`
+	Rate     float64 `json:"rate,omitempty" xml:"rate,attr,omitempty"`
+	Duration string  `json:"duration" xml:"duration,attr"`
+	Count    int64   `json:"count" xml:"count,attr"`
+	Errors   int64   `json:"errors" xml:"errors,attr"`
+	Dropped  int64   `json:"dropped,omitempty" xml:"dropped,attr,omitempty"`
+	MinTime  string  `json:"min_time" xml:"min-time,attr"`
+	MaxTime  string  `json:"max_time" xml:"max-time,attr"`
+	AvgTime  string  `json:"avg_time" xml:"avg-time,attr"`
+	P50      string  `json:"p50" xml:"p50,attr"`
+	P90      string  `json:"p90" xml:"p90,attr"`
+	P99      string  `json:"p99" xml:"p99,attr"`
+	P999     string  `json:"p99_9" xml:"p99-9,attr"`
+	StdDev   string  `json:"std_dev" xml:"std-dev,attr"`
 }
 
 // ResponseResult holds results for a single response.
 type ResponseResult struct {
-	Name       string `json:"name" xml:"name,attr"`
-	StatusCode int    `json:"status_code" xml:"status-code,attr"`
-	Count      int64  `json:"count" xml:"count,attr"`
-	Errors     int64  `json:"errors" xml:"errors,attr"`
-	MinTime    string `json:"min_time" xml:"min-time,attr"`
-	MaxTime    string `json:"max_time" xml:"max-time,attr"`
-	AvgTime    string `json:"avg_time" xml:"avg-time,attr"`
+	Name           string            `json:"name" xml:"name,attr"`
+	StatusCode     int               `json:"status_code" xml:"status-code,attr"`
+	Count          int64             `json:"count" xml:"count,attr"`
+	Errors         int64             `json:"errors" xml:"errors,attr"`
+	MinTime        string            `json:"min_time" xml:"min-time,attr"`
+	MaxTime        string            `json:"max_time" xml:"max-time,attr"`
+	AvgTime        string            `json:"avg_time" xml:"avg-time,attr"`
+	P50            string            `json:"p50" xml:"p50,attr"`
+	P90            string            `json:"p90" xml:"p90,attr"`
+	P99            string            `json:"p99" xml:"p99,attr"`
+	P999           string            `json:"p99_9" xml:"p99-9,attr"`
+	StdDev         string            `json:"std_dev" xml:"std-dev,attr"`
+	Phases         []PhaseResult     `json:"phases,omitempty" xml:"phase,omitempty"`
+	Thresholds     []ThresholdResult `json:"thresholds,omitempty" xml:"threshold,omitempty"`
+	Snapshot       bool              `json:"snapshot,omitempty" xml:"snapshot,attr,omitempty"`
+	SnapshotDiffs  []string          `json:"snapshot_diffs,omitempty" xml:"snapshot-diff,omitempty"`
+	MinDuration    string            `json:"min_duration,omitempty" xml:"min-duration,attr,omitempty"`
+	MaxDuration    string            `json:"max_duration,omitempty" xml:"max-duration,attr,omitempty"`
+	DurationErrors int64             `json:"duration_errors,omitempty" xml:"duration-errors,attr,omitempty"`
+}
+
+// PhaseResult holds the timing of one phase (eg: dns, ttfb) of a request.
+type PhaseResult struct {
+	Phase   string `json:"phase" xml:"phase,attr"`
+	Count   int64  `json:"count" xml:"count,attr"`
+	AvgTime string `json:"avg_time" xml:"avg-time,attr"`
+	MaxTime string `json:"max_time" xml:"max-time,attr"`
 }
 
 // Summary holds the full scenario report.
 type Summary struct {
-	Name       string          `json:"name" xml:"name,attr"`
-	Version    string          `json:"version" xml:"version,attr"`
-	Timestamp  string          `json:"timestamp" xml:"timestamp,attr"`
-	Iterations int             `json:"iterations" xml:"iterations,attr"`
-	Requests   []RequestResult `json:"requests" xml:"request"`
+	Name         string                    `json:"name" xml:"name,attr"`
+	Version      string                    `json:"version" xml:"version,attr"`
+	Timestamp    string                    `json:"timestamp" xml:"timestamp,attr"`
+	Iterations   int                       `json:"iterations" xml:"iterations,attr"`
+	Thresholds   []ThresholdResult         `json:"thresholds,omitempty" xml:"threshold,omitempty"`
+	Sequences    []SequenceResult          `json:"sequences,omitempty" xml:"sequence,omitempty"`
+	Requests     []RequestResult           `json:"requests" xml:"request"`
+	Variables    map[string]string         `json:"variables,omitempty" xml:"-"`
+	VirtualUsers map[int]map[string]string `json:"virtual_users,omitempty" xml:"-"`
+	Users        map[int]UserVariables     `json:"users,omitempty" xml:"-"`
+}
+
+// UserVariables holds the variables of a user of a sequence, and of its
+// virtual users.
+type UserVariables struct {
+	Variables    map[string]string         `json:"variables,omitempty"`
+	VirtualUsers map[int]map[string]string `json:"virtual_users,omitempty"`
+}
+
+// userVariables returns the variables of each user.
+func userVariables(snapshot data.Snapshot) map[int]UserVariables {
+
+	if len(snapshot.Users) == 0 {
+		return nil
+	}
+
+	users := make(map[int]UserVariables, len(snapshot.Users))
+	for user, s := range snapshot.Users {
+		users[user] = UserVariables{Variables: s.Shared, VirtualUsers: s.VirtualUsers}
+	}
+	return users
 }
 
 func avgDuration(total time.Duration, count int64) string {
@@ -54,53 +234,125 @@ func avgDuration(total time.Duration, count int64) string {
 	return (total / time.Duration(count)).String()
 }
 
+// phaseResults returns the recorded phases, if any.
+func phaseResults(s *stats.Statistics) []PhaseResult {
+
+	var all []PhaseResult
+	for _, p := range stats.Phases() {
+		if s.GetPhaseCount(p) == 0 {
+			continue
+		}
+		all = append(all, PhaseResult{
+			Phase:   p.String(),
+			Count:   s.GetPhaseCount(p),
+			AvgTime: s.GetPhaseAvg(p).String(),
+			MaxTime: s.GetPhaseMax(p).String(),
+		})
+	}
+
+	return all
+}
+
+func responseResult(resp *config.Response) ResponseResult {
+	return ResponseResult{
+		Name:           resp.Name,
+		StatusCode:     resp.StatusCode,
+		Count:          resp.Stats.GetCount(),
+		Errors:         resp.Stats.GetErrors(),
+		MinTime:        resp.Stats.GetMinDuration().String(),
+		MaxTime:        resp.Stats.GetMaxDuration().String(),
+		AvgTime:        avgDuration(resp.Stats.GetDuration(), resp.Stats.GetCount()),
+		P50:            resp.Stats.Percentile(50).String(),
+		P90:            resp.Stats.Percentile(90).String(),
+		P99:            resp.Stats.Percentile(99).String(),
+		P999:           resp.Stats.Percentile(99.9).String(),
+		StdDev:         resp.Stats.StdDev().String(),
+		Phases:         phaseResults(&resp.Stats),
+		Thresholds:     ResponseThresholds(resp),
+		Snapshot:       resp.Snapshot != nil,
+		SnapshotDiffs:  resp.SnapshotDiffs,
+		MinDuration:    durationLimit(resp.MinDuration),
+		MaxDuration:    durationLimit(resp.MaxDuration),
+		DurationErrors: resp.Stats.GetDurationErrors(),
+	}
+}
+
+// durationLimit returns a configured duration, or "" if not set.
+func durationLimit(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
 // BuildSummary creates a Summary from a completed scenario.
 func BuildSummary(sc *config.Scenario) *Summary {
 	s := &Summary{
-		Name:       sc.Name,
-		Version:    sc.Version,
-		Timestamp:  time.Now().UTC().Format(time.RFC3339),
-		Iterations: sc.Sequence.Iterations,
+		Name:         sc.Name,
+		Version:      sc.Version,
+		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+		Iterations:   iterations(sc),
+		Thresholds:   ScenarioThresholds(sc),
+		Sequences:    sequenceResults(sc),
+		Variables:    sc.Variables.Shared,
+		VirtualUsers: sc.Variables.VirtualUsers,
+		Users:        userVariables(sc.Variables),
 	}
 
-	for i := range sc.Sequence.Requests {
-		req := &sc.Sequence.Requests[i]
+	for _, req := range sc.LoadRequests() {
 		rr := RequestResult{
-			Name:    req.Name,
-			Method:  req.Method,
-			Count:   req.Stats.GetCount(),
-			Errors:  req.Stats.GetErrors(),
-			MinTime: req.Stats.GetMinDuration().String(),
-			MaxTime: req.Stats.GetMaxDuration().String(),
-			AvgTime: avgDuration(req.Stats.GetDuration(), req.Stats.GetCount()),
-		}
-
-		for j := range req.Responses {
-			resp := req.Responses[j]
-			rr.Responses = append(rr.Responses, ResponseResult{
-				Name:       resp.Name,
-				StatusCode: resp.StatusCode,
-				Count:      resp.Stats.GetCount(),
-				Errors:     resp.Stats.GetErrors(),
-				MinTime:    resp.Stats.GetMinDuration().String(),
-				MaxTime:    resp.Stats.GetMaxDuration().String(),
-				AvgTime:    avgDuration(resp.Stats.GetDuration(), resp.Stats.GetCount()),
-			})
+			Name:       req.Name,
+			Sequence:   req.Sequence,
+			Method:     req.Method,
+			Count:      req.Stats.GetCount(),
+			Errors:     req.Stats.GetErrors(),
+			MinTime:    req.Stats.GetMinDuration().String(),
+			MaxTime:    req.Stats.GetMaxDuration().String(),
+			AvgTime:    avgDuration(req.Stats.GetDuration(), req.Stats.GetCount()),
+			P50:        req.Stats.Percentile(50).String(),
+			P90:        req.Stats.Percentile(90).String(),
+			P99:        req.Stats.Percentile(99).String(),
+			P999:       req.Stats.Percentile(99.9).String(),
+			StdDev:     req.Stats.StdDev().String(),
+			Dropped:    req.Stats.GetDropped(),
+			Phases:     phaseResults(&req.Stats),
+			Thresholds: RequestThresholds(req),
+			Poll:       pollResult(req),
 		}
 
-		for j := range req.UnknownResponses {
-			resp := req.UnknownResponses[j]
-			rr.Responses = append(rr.Responses, ResponseResult{
-				Name:       resp.Name,
-				StatusCode: resp.StatusCode,
-				Count:      resp.Stats.GetCount(),
-				Errors:     resp.Stats.GetErrors(),
-				MinTime:    resp.Stats.GetMinDuration().String(),
-				MaxTime:    resp.Stats.GetMaxDuration().String(),
-				AvgTime:    avgDuration(resp.Stats.GetDuration(), resp.Stats.GetCount()),
+		for j := range req.ThunderingHerd.Stages {
+			stage := &req.ThunderingHerd.Stages[j]
+			rr.Stages = append(rr.Stages, StageResult{
+				Name:     stage.Name,
+				Size:     stage.Size,
+				Rate:     stage.Rate,
+				Duration: stage.Duration.String(),
+				Count:    stage.Stats.GetCount(),
+				Errors:   stage.Stats.GetErrors(),
+				Dropped:  stage.Stats.GetDropped(),
+				MinTime:  stage.Stats.GetMinDuration().String(),
+				MaxTime:  stage.Stats.GetMaxDuration().String(),
+				AvgTime:  avgDuration(stage.Stats.GetDuration(), stage.Stats.GetCount()),
+				P50:      stage.Stats.Percentile(50).String(),
+				P90:      stage.Stats.Percentile(90).String(),
+				P99:      stage.Stats.Percentile(99).String(),
+				P999:     stage.Stats.Percentile(99.9).String(),
+				StdDev:   stage.Stats.StdDev().String(),
 			})
 		}
 
+		for _, resp := range req.Responses {
+			rr.Responses = append(rr.Responses, responseResult(resp))
+		}
+
+		for _, resp := range req.UnknownResponses {
+			rr.Responses = append(rr.Responses, responseResult(resp))
+		}
+
+		for _, resp := range req.UndocumentedResponses {
+			rr.Responses = append(rr.Responses, responseResult(resp))
+		}
+
 		s.Requests = append(s.Requests, rr)
 	}
 
@@ -147,15 +399,132 @@ type JUnitFailure struct {
 	Type    string `xml:"type,attr"`
 }
 
+// addThresholds adds a test case for each threshold.
+func addThresholds(suite *JUnitTestSuite, prefix string, results []ThresholdResult) {
+	for _, r := range results {
+		suite.Tests++
+		tc := JUnitTestCase{
+			Name: fmt.Sprintf("%s threshold: %s", prefix, r.Expression),
+			Time: "0s",
+		}
+		if !r.Passed {
+			suite.Failures++
+			tc.Failure = &JUnitFailure{
+				Message: fmt.Sprintf("threshold %s failed, actual: %s", r.Expression, r.Actual),
+				Type:    "ThresholdFailure",
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+}
+
+// addSnapshot adds a test case for a response snapshot.
+func addSnapshot(suite *JUnitTestSuite, prefix string, diffs []string) {
+	suite.Tests++
+	tc := JUnitTestCase{
+		Name: fmt.Sprintf("%s snapshot", prefix),
+		Time: "0s",
+	}
+	if len(diffs) > 0 {
+		suite.Failures++
+		tc.Failure = &JUnitFailure{
+			Message: strings.Join(diffs, "; "),
+			Type:    "SnapshotMismatch",
+		}
+	}
+	suite.Cases = append(suite.Cases, tc)
+}
+
+// addDuration adds a test case for the response's expected duration.
+func addDuration(suite *JUnitTestSuite, prefix string, resp ResponseResult) {
+	suite.Tests++
+	tc := JUnitTestCase{
+		Name: fmt.Sprintf("%s duration", prefix),
+		Time: resp.MaxTime,
+	}
+	if resp.DurationErrors > 0 {
+		suite.Failures++
+		var limits []string
+		if resp.MinDuration != "" {
+			limits = append(limits, "min_duration "+resp.MinDuration)
+		}
+		if resp.MaxDuration != "" {
+			limits = append(limits, "max_duration "+resp.MaxDuration)
+		}
+		tc.Failure = &JUnitFailure{
+			Message: fmt.Sprintf("%d of %d executions outside %s", resp.DurationErrors, resp.Count+resp.Errors, strings.Join(limits, ", ")),
+			Type:    "DurationError",
+		}
+	}
+	suite.Cases = append(suite.Cases, tc)
+}
+
+// addPoll adds a test case for a polled request.
+func addPoll(suite *JUnitTestSuite, prefix string, poll *PollResult) {
+	suite.Tests++
+	tc := JUnitTestCase{
+		Name: fmt.Sprintf("%s poll", prefix),
+		Time: poll.AvgTime,
+	}
+	if poll.Errors > 0 {
+		suite.Failures++
+		tc.Failure = &JUnitFailure{
+			Message: fmt.Sprintf("%d of %d polls did not complete", poll.Errors, poll.Count+poll.Errors),
+			Type:    "PollError",
+		}
+	}
+	suite.Cases = append(suite.Cases, tc)
+}
+
+// sequenceSuite returns a suite with a test case for the iterations of a
+// sequence.
+func sequenceSuite(seq SequenceResult) JUnitTestSuite {
+	suite := JUnitTestSuite{
+		Name:  seq.Name,
+		Tests: 1,
+		Time:  seq.AvgTime,
+	}
+	tc := JUnitTestCase{
+		Name: fmt.Sprintf("%s iterations", seq.Name),
+		Time: seq.AvgTime,
+	}
+	if seq.Errors > 0 {
+		suite.Failures++
+		tc.Failure = &JUnitFailure{
+			Message: fmt.Sprintf("%d errors in %d iterations by %d users", seq.Errors, seq.Count+seq.Errors, seq.Users),
+			Type:    "IterationError",
+		}
+	}
+	suite.Cases = append(suite.Cases, tc)
+	return suite
+}
+
 // WriteJUnit writes the summary as JUnit XML to the given file.
 func WriteJUnit(path string, sc *config.Scenario) error {
 	s := BuildSummary(sc)
 
 	suites := JUnitTestSuites{}
 
+	if len(s.Thresholds) > 0 {
+		suite := JUnitTestSuite{
+			Name: s.Name,
+			Time: "0s",
+		}
+		addThresholds(&suite, s.Name, s.Thresholds)
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	for _, seq := range s.Sequences {
+		suites.Suites = append(suites.Suites, sequenceSuite(seq))
+	}
+
 	for _, req := range s.Requests {
+		name := req.Name
+		if req.Sequence != "" {
+			name = req.Sequence + "/" + req.Name
+		}
 		suite := JUnitTestSuite{
-			Name:     req.Name,
+			Name:     name,
 			Tests:    0,
 			Failures: 0,
 			Time:     req.AvgTime,
@@ -167,14 +536,48 @@ func WriteJUnit(path string, sc *config.Scenario) error {
 				Name: fmt.Sprintf("%s/%s [%d]", req.Name, resp.Name, resp.StatusCode),
 				Time: resp.AvgTime,
 			}
-			if resp.Errors > 0 {
+			// Duration errors have their own case...
+			if errs := resp.Errors - resp.DurationErrors; errs > 0 {
 				suite.Failures++
 				tc.Failure = &JUnitFailure{
-					Message: fmt.Sprintf("%d errors in %d executions", resp.Errors, resp.Count+resp.Errors),
+					Message: fmt.Sprintf("%d errors in %d executions", errs, resp.Count+resp.Errors),
 					Type:    "ValidationError",
 				}
 			}
 			suite.Cases = append(suite.Cases, tc)
+
+			if resp.MinDuration != "" || resp.MaxDuration != "" {
+				addDuration(&suite, fmt.Sprintf("%s/%s [%d]", req.Name, resp.Name, resp.StatusCode), resp)
+			}
+
+			if resp.Snapshot {
+				addSnapshot(&suite, fmt.Sprintf("%s/%s [%d]", req.Name, resp.Name, resp.StatusCode), resp.SnapshotDiffs)
+			}
+
+			addThresholds(&suite, fmt.Sprintf("%s/%s [%d]", req.Name, resp.Name, resp.StatusCode), resp.Thresholds)
+		}
+
+		addThresholds(&suite, req.Name, req.Thresholds)
+
+		if req.Poll != nil {
+			addPoll(&suite, req.Name, req.Poll)
+		}
+
+		// Staged requests show which stage(s) failed.
+		for _, stage := range req.Stages {
+			suite.Tests++
+			tc := JUnitTestCase{
+				Name: fmt.Sprintf("%s (stage %s)", req.Name, stage.Name),
+				Time: stage.AvgTime,
+			}
+			if stage.Errors > 0 {
+				suite.Failures++
+				tc.Failure = &JUnitFailure{
+					Message: fmt.Sprintf("%d errors in %d executions", stage.Errors, stage.Count+stage.Errors),
+					Type:    "StageError",
+				}
+			}
+			suite.Cases = append(suite.Cases, tc)
 		}
 
 		// Request-level errors that didn't match any configured response.
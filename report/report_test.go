@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"github.com/pwmorreale/rapid/config"
+	"github.com/pwmorreale/rapid/data"
+	"github.com/pwmorreale/rapid/stats"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -62,14 +64,153 @@ func TestBuildSummary(t *testing.T) {
 	assert.Equal(t, "get", req.Method)
 	assert.Equal(t, int64(2), req.Count)
 	assert.Equal(t, int64(1), req.Errors)
+	assert.Equal(t, int64(0), req.Dropped)
 	assert.Len(t, req.Responses, 2)
 
 	assert.Equal(t, "success", req.Responses[0].Name)
 	assert.Equal(t, int64(2), req.Responses[0].Count)
+	assert.NotEqual(t, "0s", req.Responses[0].P50)
+	assert.NotEqual(t, "0s", req.P99)
+	assert.NotEmpty(t, req.StdDev)
 	assert.Equal(t, "not-found", req.Responses[1].Name)
 	assert.Equal(t, int64(1), req.Responses[1].Errors)
 }
 
+func TestBuildSummaryPhases(t *testing.T) {
+
+	sc := makeScenario()
+
+	s := BuildSummary(sc)
+	assert.Empty(t, s.Requests[0].Phases)
+
+	timings := &stats.Timings{stats.PhaseConnect: 2 * time.Millisecond, stats.PhaseTTFB: 8 * time.Millisecond}
+	sc.Sequence.Requests[0].Stats.Timing(timings)
+	sc.Sequence.Requests[0].Responses[0].Stats.Timing(timings)
+
+	s = BuildSummary(sc)
+
+	phases := s.Requests[0].Phases
+	assert.Len(t, phases, 2)
+	assert.Equal(t, PhaseResult{Phase: "connect", Count: 1, AvgTime: "2ms", MaxTime: "2ms"}, phases[0])
+	assert.Equal(t, "ttfb", phases[1].Phase)
+	assert.Len(t, s.Requests[0].Responses[0].Phases, 2)
+	assert.Empty(t, s.Requests[0].Responses[1].Phases)
+}
+
+func TestBuildSummaryDropped(t *testing.T) {
+
+	sc := makeScenario()
+	sc.Sequence.Requests[0].Stats.Drop()
+
+	s := BuildSummary(sc)
+	assert.Equal(t, int64(1), s.Requests[0].Dropped)
+}
+
+func TestBuildSummaryStages(t *testing.T) {
+
+	sc := makeScenario()
+
+	request := &sc.Sequence.Requests[0]
+	request.ThunderingHerd.Stages = []config.Stage{
+		{Name: "warm", Size: 10, Duration: time.Second},
+		{Name: "peak", Size: 500, Duration: time.Minute},
+	}
+
+	start := time.Now().Add(-50 * time.Millisecond)
+	request.ThunderingHerd.Stages[0].Stats.Success(start)
+	request.ThunderingHerd.Stages[1].Stats.Error(start)
+
+	s := BuildSummary(sc)
+	assert.Len(t, s.Requests[0].Stages, 2)
+	assert.Equal(t, "warm", s.Requests[0].Stages[0].Name)
+	assert.Equal(t, int64(1), s.Requests[0].Stages[0].Count)
+	assert.Equal(t, "1m0s", s.Requests[0].Stages[1].Duration)
+	assert.Equal(t, int64(1), s.Requests[0].Stages[1].Errors)
+
+	path := filepath.Join(t.TempDir(), "report.xml")
+	err := WriteJUnit(path, sc)
+	assert.Nil(t, err)
+
+	raw, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(raw), "get-users (stage peak)")
+	assert.Contains(t, string(raw), "StageError")
+}
+
+func TestThresholds(t *testing.T) {
+
+	sc := makeScenario()
+
+	var err error
+	sc.ThresholdsCompiled, err = config.CompileThresholds([]string{"count(status=404) == 0", "error_rate <= 50%"})
+	assert.Nil(t, err)
+
+	request := &sc.Sequence.Requests[0]
+	request.ThresholdsCompiled, err = config.CompileThresholds([]string{"p99 < 1s"})
+	assert.Nil(t, err)
+
+	request.Responses[0].ThresholdsCompiled, err = config.CompileThresholds([]string{"count == 2"})
+	assert.Nil(t, err)
+
+	s := BuildSummary(sc)
+
+	// 4 executions, 1 without a response, 1 404 error...
+	assert.Len(t, s.Thresholds, 2)
+	assert.False(t, s.Thresholds[0].Passed)
+	assert.Equal(t, "1", s.Thresholds[0].Actual)
+	assert.True(t, s.Thresholds[1].Passed)
+	assert.Equal(t, "50.000%", s.Thresholds[1].Actual)
+
+	assert.Len(t, s.Requests[0].Thresholds, 1)
+	assert.True(t, s.Requests[0].Thresholds[0].Passed)
+
+	assert.Len(t, s.Requests[0].Responses[0].Thresholds, 1)
+	assert.True(t, s.Requests[0].Responses[0].Thresholds[0].Passed)
+	assert.Nil(t, s.Requests[0].Responses[1].Thresholds)
+
+	path := filepath.Join(t.TempDir(), "report.xml")
+	err = WriteJUnit(path, sc)
+	assert.Nil(t, err)
+
+	raw, err := os.ReadFile(path)
+	assert.Nil(t, err)
+
+	var suites JUnitTestSuites
+	err = xml.Unmarshal(raw, &suites)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "test-scenario", suites.Suites[0].Name)
+	assert.Equal(t, 2, suites.Suites[0].Tests)
+	assert.Equal(t, 1, suites.Suites[0].Failures)
+	assert.Equal(t, "ThresholdFailure", suites.Suites[0].Cases[0].Failure.Type)
+	assert.Contains(t, string(raw), "get-users threshold: p99 &lt; 1s")
+}
+
+func TestThresholdsLatency(t *testing.T) {
+
+	sc := makeScenario()
+	request := &sc.Sequence.Requests[0]
+
+	// A slow response error, and a slow error without a response...
+	request.Responses[1].Stats.Error(time.Now().Add(-2 * time.Second))
+	request.Unanswered.Error(time.Now().Add(-3 * time.Second))
+
+	var err error
+	request.ThresholdsCompiled, err = config.CompileThresholds([]string{"max > 2500ms", "p50 < 1s", "count == 5"})
+	assert.Nil(t, err)
+
+	sc.ThresholdsCompiled, err = config.CompileThresholds([]string{"max > 2500ms"})
+	assert.Nil(t, err)
+
+	s := BuildSummary(sc)
+
+	assert.Len(t, s.Requests[0].Thresholds, 3)
+	for _, r := range s.Requests[0].Thresholds {
+		assert.True(t, r.Passed, r.Expression)
+	}
+	assert.True(t, s.Thresholds[0].Passed)
+}
+
 func TestWriteJSON(t *testing.T) {
 
 	sc := makeScenario()
@@ -88,6 +229,43 @@ func TestWriteJSON(t *testing.T) {
 	assert.Equal(t, "test-scenario", s.Name)
 	assert.Len(t, s.Requests, 1)
 	assert.Equal(t, int64(2), s.Requests[0].Count)
+
+	assert.Contains(t, string(data), `"p99_9"`)
+	assert.Contains(t, string(data), `"std_dev"`)
+}
+
+func TestWriteJSONVariables(t *testing.T) {
+
+	sc := makeScenario()
+	path := filepath.Join(t.TempDir(), "report.json")
+
+	err := WriteJSON(path, sc)
+	assert.Nil(t, err)
+
+	blob, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(blob), `"variables"`)
+
+	sc.Variables.Shared = map[string]string{"HOST": "bob_ross.com"}
+	sc.Variables.VirtualUsers = map[int]map[string]string{3: {"AUTH_TOKEN": "abc"}}
+	sc.Variables.Users = map[int]data.Snapshot{1: {
+		Shared:       map[string]string{"AUTH_TOKEN": "def"},
+		VirtualUsers: map[int]map[string]string{0: {"ORDER_ID": "7"}},
+	}}
+
+	err = WriteJSON(path, sc)
+	assert.Nil(t, err)
+
+	blob, err = os.ReadFile(path)
+	assert.Nil(t, err)
+
+	var s Summary
+	err = json.Unmarshal(blob, &s)
+	assert.Nil(t, err)
+	assert.Equal(t, "bob_ross.com", s.Variables["HOST"])
+	assert.Equal(t, "abc", s.VirtualUsers[3]["AUTH_TOKEN"])
+	assert.Equal(t, "def", s.Users[1].Variables["AUTH_TOKEN"])
+	assert.Equal(t, "7", s.Users[1].VirtualUsers[0]["ORDER_ID"])
 }
 
 func TestWriteJUnit(t *testing.T) {
@@ -119,6 +297,236 @@ func TestWriteJUnit(t *testing.T) {
 	assert.Contains(t, suite.Cases[1].Failure.Message, "1 errors")
 }
 
+func TestWriteJUnitSnapshot(t *testing.T) {
+
+	sc := makeScenario()
+	sc.Sequence.Requests[0].Responses[0].Snapshot = &config.SnapshotConfig{}
+	sc.Sequence.Requests[0].Responses[0].SnapshotDiffs = []string{`name: expected "Bob Ross", got "Frida Kahlo"`, `total: missing, expected 2`}
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	err := WriteJSON(path, sc)
+	assert.Nil(t, err)
+
+	blob, err := os.ReadFile(path)
+	assert.Nil(t, err)
+
+	var s Summary
+	err = json.Unmarshal(blob, &s)
+	assert.Nil(t, err)
+	assert.True(t, s.Requests[0].Responses[0].Snapshot)
+	assert.Len(t, s.Requests[0].Responses[0].SnapshotDiffs, 2)
+	assert.False(t, s.Requests[0].Responses[1].Snapshot)
+
+	path = filepath.Join(t.TempDir(), "report.xml")
+	err = WriteJUnit(path, sc)
+	assert.Nil(t, err)
+
+	blob, err = os.ReadFile(path)
+	assert.Nil(t, err)
+
+	var suites JUnitTestSuites
+	err = xml.Unmarshal(blob, &suites)
+	assert.Nil(t, err)
+
+	suite := suites.Suites[0]
+	assert.Equal(t, 3, suite.Tests)
+	assert.Equal(t, 2, suite.Failures)
+
+	tc := suite.Cases[1]
+	assert.Equal(t, "get-users/success [200] snapshot", tc.Name)
+	assert.NotNil(t, tc.Failure)
+	assert.Equal(t, "SnapshotMismatch", tc.Failure.Type)
+	assert.Equal(t, `name: expected "Bob Ross", got "Frida Kahlo"; total: missing, expected 2`, tc.Failure.Message)
+}
+
+func TestWriteJUnitDuration(t *testing.T) {
+
+	sc := makeScenario()
+
+	// The not-found error is a duration error...
+	response := sc.Sequence.Requests[0].Responses[1]
+	response.MaxDuration = 10 * time.Millisecond
+	response.Stats.DurationError(time.Now().Add(-50 * time.Millisecond))
+
+	path := filepath.Join(t.TempDir(), "report.xml")
+	err := WriteJUnit(path, sc)
+	assert.Nil(t, err)
+
+	blob, err := os.ReadFile(path)
+	assert.Nil(t, err)
+
+	var suites JUnitTestSuites
+	err = xml.Unmarshal(blob, &suites)
+	assert.Nil(t, err)
+
+	suite := suites.Suites[0]
+	assert.Equal(t, 3, suite.Tests)
+	assert.Equal(t, 2, suite.Failures)
+
+	assert.Contains(t, suite.Cases[1].Failure.Message, "1 errors in 2 executions")
+	assert.Equal(t, "ValidationError", suite.Cases[1].Failure.Type)
+
+	tc := suite.Cases[2]
+	assert.Equal(t, "get-users/not-found [404] duration", tc.Name)
+	assert.Equal(t, "DurationError", tc.Failure.Type)
+	assert.Equal(t, "1 of 2 executions outside max_duration 10ms", tc.Failure.Message)
+
+	s := BuildSummary(sc)
+	assert.Equal(t, int64(1), s.Requests[0].Responses[1].DurationErrors)
+	assert.Equal(t, "10ms", s.Requests[0].Responses[1].MaxDuration)
+	assert.Empty(t, s.Requests[0].Responses[1].MinDuration)
+}
+
+func TestWriteJUnitPoll(t *testing.T) {
+
+	sc := makeScenario()
+
+	request := &sc.Sequence.Requests[0]
+	request.Poll = &config.PollConfig{Interval: time.Second}
+
+	start := time.Now().Add(-2 * time.Second)
+	request.Polling.Poll()
+	request.Polling.Poll()
+	request.Polling.Success(start)
+	request.Polling.Poll()
+	request.Polling.Error(start)
+
+	path := filepath.Join(t.TempDir(), "report.xml")
+	err := WriteJUnit(path, sc)
+	assert.Nil(t, err)
+
+	blob, err := os.ReadFile(path)
+	assert.Nil(t, err)
+
+	var suites JUnitTestSuites
+	err = xml.Unmarshal(blob, &suites)
+	assert.Nil(t, err)
+
+	suite := suites.Suites[0]
+	tc := suite.Cases[len(suite.Cases)-1]
+	assert.Equal(t, "get-users poll", tc.Name)
+	assert.Equal(t, "PollError", tc.Failure.Type)
+	assert.Equal(t, "1 of 2 polls did not complete", tc.Failure.Message)
+
+	s := BuildSummary(sc)
+	p := s.Requests[0].Poll
+	assert.Equal(t, int64(1), p.Count)
+	assert.Equal(t, int64(1), p.Errors)
+	assert.Equal(t, int64(3), p.Polls)
+
+	// Not reported without a poll...
+	request.Poll = nil
+	s = BuildSummary(sc)
+	assert.Nil(t, s.Requests[0].Poll)
+}
+
+func TestWriteJUnitSequences(t *testing.T) {
+
+	sc := makeScenario()
+	sc.Sequences = []config.Sequence{
+		{Name: "browse", Weight: 3, Iterations: 2, Requests: sc.Sequence.Requests},
+		{Name: "checkout", Concurrency: 2, Iterations: 1, Requests: []config.Request{{Name: "pay", Method: "post", Sequence: "checkout"}}},
+	}
+	sc.Sequence = config.Sequence{}
+	sc.Sequences[0].Requests[0].Sequence = "browse"
+
+	start := time.Now().Add(-time.Second)
+	browse := &sc.Sequences[0]
+	for range 6 {
+		browse.Stats.Success(start)
+	}
+	checkout := &sc.Sequences[1]
+	checkout.Stats.Success(start)
+	checkout.Stats.Error(start)
+	checkout.Requests[0].Stats.Error(start)
+
+	s := BuildSummary(sc)
+
+	// 3 users of browse, and 2 of checkout...
+	assert.Equal(t, 8, s.Iterations)
+
+	assert.Len(t, s.Sequences, 2)
+	assert.Equal(t, "browse", s.Sequences[0].Name)
+	assert.Equal(t, 3, s.Sequences[0].Weight)
+	assert.Equal(t, 3, s.Sequences[0].Users)
+	assert.Equal(t, int64(6), s.Sequences[0].Count)
+	assert.Equal(t, int64(3), s.Sequences[0].Requests)
+	assert.Equal(t, int64(1), s.Sequences[0].RequestErrors)
+	assert.Equal(t, 2, s.Sequences[1].Users)
+	assert.Equal(t, int64(1), s.Sequences[1].Errors)
+
+	assert.Len(t, s.Requests, 2)
+	assert.Equal(t, "browse", s.Requests[0].Sequence)
+	assert.Equal(t, "checkout", s.Requests[1].Sequence)
+
+	path := filepath.Join(t.TempDir(), "report.xml")
+	err := WriteJUnit(path, sc)
+	assert.Nil(t, err)
+
+	blob, err := os.ReadFile(path)
+	assert.Nil(t, err)
+
+	var suites JUnitTestSuites
+	err = xml.Unmarshal(blob, &suites)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "browse", suites.Suites[0].Name)
+	assert.Equal(t, 0, suites.Suites[0].Failures)
+	assert.Equal(t, "browse iterations", suites.Suites[0].Cases[0].Name)
+
+	tc := suites.Suites[1].Cases[0]
+	assert.Equal(t, "checkout iterations", tc.Name)
+	assert.Equal(t, "IterationError", tc.Failure.Type)
+	assert.Equal(t, "1 errors in 2 iterations by 2 users", tc.Failure.Message)
+
+	assert.Equal(t, "browse/get-users", suites.Suites[2].Name)
+	assert.Equal(t, "checkout/pay", suites.Suites[3].Name)
+
+	// A single sequence has no sequence results...
+	s = BuildSummary(makeScenario())
+	assert.Nil(t, s.Sequences)
+	assert.Empty(t, s.Requests[0].Sequence)
+}
+
+func TestWriteJUnitPhases(t *testing.T) {
+
+	sc := makeScenario()
+	sc.Setup = []config.Request{{Name: "create-tenant", Method: "post", Sequence: config.PhaseSetup}}
+	sc.SetupSequence = config.Sequence{Name: config.PhaseSetup, Iterations: 1, Requests: sc.Setup}
+
+	start := time.Now().Add(-time.Second)
+	sc.SetupSequence.Stats.Error(start)
+	sc.Setup[0].Stats.Error(start)
+
+	// Setup is reported, but excluded from the statistics...
+	s := BuildSummary(sc)
+	assert.Len(t, s.Sequences, 1)
+	assert.Equal(t, config.PhaseSetup, s.Sequences[0].Name)
+	assert.Equal(t, 1, s.Sequences[0].Users)
+	assert.Equal(t, int64(1), s.Sequences[0].RequestErrors)
+	assert.Len(t, s.Requests, 1)
+
+	path := filepath.Join(t.TempDir(), "report.xml")
+	err := WriteJUnit(path, sc)
+	assert.Nil(t, err)
+
+	blob, err := os.ReadFile(path)
+	assert.Nil(t, err)
+
+	var suites JUnitTestSuites
+	err = xml.Unmarshal(blob, &suites)
+	assert.Nil(t, err)
+
+	tc := suites.Suites[0].Cases[0]
+	assert.Equal(t, "setup iterations", tc.Name)
+	assert.Equal(t, "1 errors in 1 iterations by 1 users", tc.Failure.Message)
+
+	sc.PhaseStats = true
+	s = BuildSummary(sc)
+	assert.Len(t, s.Requests, 2)
+	assert.Equal(t, config.PhaseSetup, s.Requests[1].Sequence)
+}
+
 func TestWriteJUnitRequestLevelErrors(t *testing.T) {
 
 	sc := &config.Scenario{
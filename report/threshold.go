@@ -0,0 +1,135 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package report
+
+import (
+	"github.com/pwmorreale/rapid/config"
+	"github.com/pwmorreale/rapid/stats"
+	"github.com/pwmorreale/rapid/threshold"
+)
+
+// ThresholdResult holds the outcome of a single threshold.
+type ThresholdResult struct {
+	Expression string `json:"expression" xml:"expression,attr"`
+	Actual     string `json:"actual" xml:"actual,attr"`
+	Passed     bool   `json:"passed" xml:"passed,attr"`
+}
+
+func evaluate(thresholds []*threshold.Threshold, in *threshold.Input) []ThresholdResult {
+
+	var all []ThresholdResult
+	for _, t := range thresholds {
+		r := t.Evaluate(in)
+		all = append(all, ThresholdResult{
+			Expression: r.Expression,
+			Actual:     r.Actual,
+			Passed:     r.Passed,
+		})
+	}
+
+	return all
+}
+
+func responseInput(response *config.Response) *threshold.Input {
+
+	n := response.Stats.GetCount() + response.Stats.GetErrors()
+
+	return &threshold.Input{
+		Stats:        &response.Stats,
+		Total:        n,
+		Errors:       response.Stats.GetErrors(),
+		Elapsed:      response.Stats.GetElapsed(),
+		StatusCounts: map[int]int64{response.StatusCode: n},
+	}
+}
+
+// latency returns the execution times of every response to the request,
+// valid or not, and of its errors without a response.
+func latency(request *config.Request) *stats.Statistics {
+
+	all := &stats.Statistics{}
+	all.Merge(&request.Unanswered)
+	for _, response := range request.AllResponses() {
+		all.Merge(&response.Stats)
+	}
+	return all
+}
+
+// requestInput combines the request statistics (successes and errors
+// without a response) with response validation errors.
+func requestInput(request *config.Request) *threshold.Input {
+
+	in := &threshold.Input{
+		Stats:        latency(request),
+		Errors:       request.Stats.GetErrors(),
+		Dropped:      request.Stats.GetDropped(),
+		StatusCounts: map[int]int64{},
+	}
+
+	for _, response := range request.AllResponses() {
+		in.Errors += response.Stats.GetErrors()
+		in.StatusCounts[response.StatusCode] += response.Stats.GetCount() + response.Stats.GetErrors()
+	}
+
+	in.Total = request.Stats.GetCount() + in.Errors
+	in.Elapsed = in.Stats.GetElapsed()
+
+	return in
+}
+
+// scenarioInput combines all requests.
+func scenarioInput(sc *config.Scenario) *threshold.Input {
+
+	in := &threshold.Input{
+		Stats:        &stats.Statistics{},
+		StatusCounts: map[int]int64{},
+	}
+
+	for _, request := range sc.LoadRequests() {
+
+		ri := requestInput(request)
+		in.Stats.Merge(ri.Stats)
+		in.Total += ri.Total
+		in.Errors += ri.Errors
+		in.Dropped += ri.Dropped
+		for code, n := range ri.StatusCounts {
+			in.StatusCounts[code] += n
+		}
+	}
+
+	in.Elapsed = in.Stats.GetElapsed()
+
+	return in
+}
+
+// ScenarioThresholds evaluates the scenario thresholds.
+func ScenarioThresholds(sc *config.Scenario) []ThresholdResult {
+
+	if len(sc.ThresholdsCompiled) == 0 {
+		return nil
+	}
+
+	return evaluate(sc.ThresholdsCompiled, scenarioInput(sc))
+}
+
+// RequestThresholds evaluates the request thresholds.
+func RequestThresholds(request *config.Request) []ThresholdResult {
+
+	if len(request.ThresholdsCompiled) == 0 {
+		return nil
+	}
+
+	return evaluate(request.ThresholdsCompiled, requestInput(request))
+}
+
+// ResponseThresholds evaluates the response thresholds.
+func ResponseThresholds(response *config.Response) []ThresholdResult {
+
+	if len(response.ThresholdsCompiled) == 0 {
+		return nil
+	}
+
+	return evaluate(response.ThresholdsCompiled, responseInput(response))
+}
@@ -246,7 +246,7 @@ func TestRequestToTestServer(t *testing.T) {
 		ctx := context.Background()
 
 		test.request.URL = ts.URL
-		_, err = r.Gestalt(ctx, &test.request)
+		_, err = r.Gestalt(ctx, data.New(), 1, &test.request)
 		if test.requestError != "" {
 			assert.Contains(t, err.Error(), test.requestError, test.name)
 		} else {
@@ -0,0 +1,74 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package rest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pwmorreale/rapid/config"
+	"github.com/pwmorreale/rapid/data"
+)
+
+type elementKey struct{}
+
+// WithElement returns a context for executing a foreach request with an
+// element.
+func WithElement(ctx context.Context, element string) context.Context {
+	return context.WithValue(ctx, elementKey{}, element)
+}
+
+// elementFrom returns the foreach element for a context, if any.
+func elementFrom(ctx context.Context) (string, bool) {
+
+	e, ok := ctx.Value(elementKey{}).(string)
+	return e, ok
+}
+
+// Elements returns the elements a foreach request is executed with.  The
+// content is taken from the variables of the context's user (see WithUser).
+func (r *Context) Elements(ctx context.Context, request *config.Request) ([]string, error) {
+
+	f := request.ForEach
+
+	content := r.userScope(ctx).Lookup(f.Variable)
+	if content == "" {
+		return nil, fmt.Errorf("foreach: variable %s is not set", f.Variable)
+	}
+
+	var elements []string
+	var err error
+
+	switch f.Type {
+	case "json":
+		elements, err = data.JSONElements(f.Path, []byte(content))
+	case "xml":
+		elements, err = data.XMLElements(f.Path, []byte(content))
+	default:
+		return nil, fmt.Errorf("foreach: unknown type: %q (must be json or xml)", f.Type)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("foreach: %w", err)
+	}
+
+	return elements, nil
+}
+
+// bindElement binds the foreach element, if any, as a variable.
+func bindElement(ctx context.Context, datum data.Data, request *config.Request) error {
+
+	element, ok := elementFrom(ctx)
+	if !ok || request.ForEach == nil {
+		return nil
+	}
+
+	err := datum.AddReplacement(request.ForEach.Match, element)
+	if err != nil {
+		return fmt.Errorf("foreach: %w", err)
+	}
+
+	return nil
+}
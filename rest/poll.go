@@ -0,0 +1,91 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pwmorreale/rapid/assertion"
+	"github.com/pwmorreale/rapid/config"
+	"github.com/pwmorreale/rapid/data"
+	"github.com/pwmorreale/rapid/logger"
+)
+
+// ErrPollPending is returned for a valid response that does not yet meet
+// the request's poll condition.
+var ErrPollPending = errors.New("poll pending")
+
+// verifyPoll returns an error, wrapping ErrPollPending, until the response
+// meets the poll condition.
+func verifyPoll(contentBytes []byte, httpResponse *http.Response, until *config.PollUntil) error {
+
+	if until.StatusCode != 0 && httpResponse.StatusCode != until.StatusCode {
+		return fmt.Errorf("%w: status %d", ErrPollPending, httpResponse.StatusCode)
+	}
+
+	for i := range until.Headers {
+		err := verifyHeaderValues(httpResponse.Header, &until.Headers[i])
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrPollPending, err)
+		}
+	}
+
+	err := assertion.EvaluateJSON(until.AssertCompiled, contentBytes)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrPollPending, err)
+	}
+
+	return nil
+}
+
+// poll sends the request until the poll condition is met.  Each poll is
+// recorded as an execution, and the total wait from start is recorded in
+// the request's polling statistics.  Returns true if an error occurred, or
+// the condition was not met in time.
+func (r *Context) poll(ctx context.Context, iteration int, datum data.Data, request *config.Request, stage *config.Stage, start time.Time, seenErrors *sync.Map) bool {
+
+	p := request.Poll
+	sent := start
+
+	for n := 1; ; n++ {
+
+		request.Polling.Poll()
+
+		err := r.send(ctx, iteration, datum, request, stage, sent, seenErrors)
+		if err == nil {
+			logger.Debug(request, nil, "poll condition met after %d polls in %s", n, time.Since(start))
+			request.Polling.Success(start)
+			return false
+		}
+
+		if !only(err, ErrPollPending) {
+			request.Polling.Error(start)
+			return true
+		}
+
+		logger.Debug(request, nil, "poll %d: %v", n, err)
+
+		elapsed := time.Since(start)
+		if (p.MaxPolls > 0 && n >= p.MaxPolls) || (p.Timeout > 0 && elapsed+p.Interval > p.Timeout) {
+			logErrors(request, fmt.Errorf("poll condition not met after %d polls in %s: %v", n, elapsed.Round(time.Millisecond), err), seenErrors)
+			request.Polling.Error(start)
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			request.Polling.Error(start)
+			return true
+		case <-time.After(p.Interval):
+		}
+
+		sent = time.Now()
+	}
+}
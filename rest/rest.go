@@ -9,27 +9,41 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/pwmorreale/rapid/condition"
 	"github.com/pwmorreale/rapid/config"
 	"github.com/pwmorreale/rapid/data"
 	"github.com/pwmorreale/rapid/logger"
 	"github.com/pwmorreale/rapid/metrics"
 )
 
+// DefaultTLSHandshakeTimeout is used if the transport doesn't specify one.
+const DefaultTLSHandshakeTimeout = 10 * time.Second
+
+// drainLimit is the most remaining content read to reuse a connection.
+const drainLimit = 256 << 10
+
 // Rest  defines the interface for managing requests and responses
 //
 //go:generate go tool counterfeiter -o ../testdata/mocks/fake_rest.go . Rest
 type Rest interface {
-	Execute(context.Context, int, *config.Request, *sync.Map) bool
+	Execute(context.Context, int, int, *config.Request, Options) bool
+	StartIteration(context.Context, *config.Sequence, int)
+	Evaluate(context.Context, *config.Sequence, *condition.Condition, string) bool
+	Elements(context.Context, *config.Request) ([]string, error)
 	Push() error
 }
 
@@ -40,6 +54,16 @@ type Context struct {
 	metrics metrics.Metrics
 	dump    io.Writer
 
+	// One client, and transport, per transport configuration.
+	clientsMu sync.Mutex
+	clients   map[*config.TransportConfig]*http.Client
+
+	// Snapshots, by response.
+	snapshots sync.Map
+
+	// The most recent outcomes of each user's sequence, by request name.
+	outcomes sync.Map
+
 	// For unit tests to set a mock roundtripper...
 	mockRoundTripper http.RoundTripper
 }
@@ -51,6 +75,7 @@ func New(sc *config.Scenario, d data.Data, dump io.Writer) *Context {
 		sc:      sc,
 		metrics: metrics.New(sc),
 		dump:    dump,
+		clients: make(map[*config.TransportConfig]*http.Client),
 	}
 }
 
@@ -90,12 +115,25 @@ func (r *Context) CreateTLSConfig(certPath, keyPath, caPath string, enableInsecu
 	}, nil
 }
 
-func (r *Context) addCookies(req *http.Request, request *config.Request) error {
+// render applies the replacements to s, after executing any template
+// actions if the scenario enables templates.
+func (r *Context) render(datum data.Data, s string, iteration int) (string, error) {
+
+	if !r.sc.Templates {
+		return datum.Replace(s), nil
+	}
+	return datum.Render(s, iteration)
+}
+
+func (r *Context) addCookies(req *http.Request, datum data.Data, iteration int, request *config.Request) error {
 
 	for i := range request.Cookies {
 
 		// Perform any substitutions on cookies.
-		ck := r.datum.Replace(request.Cookies[i].Value)
+		ck, err := r.render(datum, request.Cookies[i].Value, iteration)
+		if err != nil {
+			return fmt.Errorf("cookie: %w", err)
+		}
 
 		cookies, err := http.ParseCookie(ck)
 		if err != nil {
@@ -110,19 +148,29 @@ func (r *Context) addCookies(req *http.Request, request *config.Request) error {
 	return nil
 }
 
-func (r *Context) getContentReader(request *config.Request) *strings.Reader {
+func (r *Context) getContentReader(datum data.Data, iteration int, request *config.Request) (*strings.Reader, error) {
 
-	// Perform any substitutions on cookie values.
-	content := r.datum.Replace(request.Content)
-	return strings.NewReader(content)
+	// Perform any substitutions on the content.
+	content, err := r.render(datum, request.Content, iteration)
+	if err != nil {
+		return nil, fmt.Errorf("content: %w", err)
+	}
+	return strings.NewReader(content), nil
 }
 
-func (r *Context) createRequest(ctx context.Context, request *config.Request) (*http.Request, error) {
+func (r *Context) createRequest(ctx context.Context, datum data.Data, iteration int, request *config.Request) (*http.Request, error) {
 
 	// Perform any substitutions on the url.
-	url := r.datum.Replace(request.URL)
+	url, err := r.render(datum, request.URL, iteration)
+	if err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+
+	rdr, err := r.getContentReader(datum, iteration, request)
+	if err != nil {
+		return nil, err
+	}
 
-	rdr := r.getContentReader(request)
 	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(request.Method), url, rdr)
 	if err != nil {
 		return nil, err
@@ -136,12 +184,15 @@ func (r *Context) createRequest(ctx context.Context, request *config.Request) (*
 	for i := range request.ExtraHeaders {
 
 		// Perform any substitutions on any extra headers.
-		hv := r.datum.Replace(request.ExtraHeaders[i].Value)
+		hv, err := r.render(datum, request.ExtraHeaders[i].Value, iteration)
+		if err != nil {
+			return nil, fmt.Errorf("header: %s: %w", request.ExtraHeaders[i].Name, err)
+		}
 
 		req.Header.Add(request.ExtraHeaders[i].Name, hv)
 	}
 
-	err = r.addCookies(req, request)
+	err = r.addCookies(req, datum, iteration, request)
 	if err != nil {
 		return nil, err
 	}
@@ -149,7 +200,7 @@ func (r *Context) createRequest(ctx context.Context, request *config.Request) (*
 	return req, nil
 }
 
-func (r *Context) createClient() (*http.Client, error) {
+func (r *Context) createClient(tc *config.TransportConfig) (*http.Client, error) {
 
 	client := &http.Client{
 		Timeout: r.sc.RequestTimeout,
@@ -167,19 +218,120 @@ func (r *Context) createClient() (*http.Client, error) {
 		return nil, err
 	}
 
+	tlsHandshakeTimeout := tc.TLSHandshakeTimeout
+	if tlsHandshakeTimeout == 0 {
+		tlsHandshakeTimeout = DefaultTLSHandshakeTimeout
+	}
+
+	dialer := &net.Dialer{
+		Timeout: tc.DialTimeout,
+	}
+
+	protocols, err := transportProtocols(tc.Protocol)
+	if err != nil {
+		return nil, err
+	}
+
 	client.Transport = &http.Transport{
-		DisableKeepAlives:   true, // Always, one request per connection.
-		TLSClientConfig:     tlsConfig,
-		TLSHandshakeTimeout: 10 * time.Second,
+		Protocols:             protocols,
+		DialContext:           dialer.DialContext,
+		DisableKeepAlives:     !tc.KeepAlives,
+		MaxIdleConns:          tc.MaxIdleConns,
+		MaxIdleConnsPerHost:   tc.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       tc.MaxConnsPerHost,
+		IdleConnTimeout:       tc.IdleTimeout,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		ResponseHeaderTimeout: tc.ResponseHeaderTimeout,
 	}
 
 	return client, nil
 }
 
+// transportProtocols returns the protocols for a transport.  For h2c,
+// HTTP/2 is spoken over cleartext connections with prior knowledge.  When
+// unset, nil selects the Go defaults, where a client certificate limits the
+// transport to HTTP/1.1.
+func transportProtocols(protocol string) (*http.Protocols, error) {
+
+	p := &http.Protocols{}
+
+	switch protocol {
+	case "":
+		return nil, nil
+	case config.ProtocolAuto:
+		p.SetHTTP1(true)
+		p.SetHTTP2(true)
+	case config.ProtocolHTTP1:
+		p.SetHTTP1(true)
+	case config.ProtocolH2:
+		p.SetHTTP2(true)
+	case config.ProtocolH2C:
+		p.SetUnencryptedHTTP2(true)
+	default:
+		return nil, fmt.Errorf("unknown protocol: %q (must be http1, h2, h2c, or auto)", protocol)
+	}
+
+	return p, nil
+}
+
+// transport returns the request's transport configuration, or the
+// scenario's.
+func (r *Context) transport(request *config.Request) *config.TransportConfig {
+
+	if request.Transport != nil {
+		return request.Transport
+	}
+	return &r.sc.Transport
+}
+
+// client returns the shared client for the request's transport
+// configuration, creating it on first use.
+func (r *Context) client(request *config.Request) (*http.Client, error) {
+
+	tc := r.transport(request)
+
+	// Tests may change the mock between calls...
+	if r.mockRoundTripper != nil {
+		return r.createClient(tc)
+	}
+
+	r.clientsMu.Lock()
+	defer r.clientsMu.Unlock()
+
+	if client, ok := r.clients[tc]; ok {
+		return client, nil
+	}
+
+	client, err := r.createClient(tc)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.clients == nil {
+		r.clients = make(map[*config.TransportConfig]*http.Client)
+	}
+	r.clients[tc] = client
+
+	return client, nil
+}
+
+// closeBody reads any remaining content, up to drainLimit, so a kept-alive
+// connection may be reused.  Without keep-alives, or with more content, the
+// connection is closed instead.
+func closeBody(resp *http.Response, keepAlives bool) {
+
+	if keepAlives {
+		_, _ = io.CopyN(io.Discard, resp.Body, drainLimit)
+	}
+	resp.Body.Close()
+}
+
 // Gestalt creates and executes the request then validates the response.
-func (r *Context) Gestalt(ctx context.Context, request *config.Request) (*config.Response, error) {
+// Substitutions and extracted data use the datum scope.
+func (r *Context) Gestalt(ctx context.Context, datum data.Data, iteration int, request *config.Request) (*config.Response, error) {
 
-	client, err := r.createClient()
+	client, err := r.client(request)
 	if err != nil {
 		return nil, err
 	}
@@ -189,9 +341,10 @@ func (r *Context) Gestalt(ctx context.Context, request *config.Request) (*config
 		maxAttempts = 1
 	}
 
+	var req *http.Request
 	var resp *http.Response
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		req, err := r.createRequest(ctx, request)
+		req, err = r.createRequest(ctx, datum, iteration, request)
 		if err != nil {
 			return nil, err
 		}
@@ -206,7 +359,7 @@ func (r *Context) Gestalt(ctx context.Context, request *config.Request) (*config
 			logger.Debug(request, nil, "retry %d/%d after connection error: %v", attempt, maxAttempts, err)
 		} else if len(request.Retry.StatusCodes) > 0 && shouldRetry(resp.StatusCode, request.Retry.StatusCodes) && attempt < maxAttempts {
 			r.dumpResponse(request, resp)
-			resp.Body.Close()
+			closeBody(resp, r.transport(request).KeepAlives)
 			logger.Debug(request, nil, "retry %d/%d after status %d", attempt, maxAttempts, resp.StatusCode)
 		} else {
 			break
@@ -223,9 +376,13 @@ func (r *Context) Gestalt(ctx context.Context, request *config.Request) (*config
 	}
 
 	r.dumpResponse(request, resp)
-	defer resp.Body.Close()
+	defer closeBody(resp, r.transport(request).KeepAlives)
+
+	response, err := r.validateResponse(req, resp, datum, request)
+
+	r.dumpVariables(request, datum)
 
-	return r.validateResponse(resp, request)
+	return response, err
 }
 
 func (r *Context) dumpRequest(request *config.Request, req *http.Request) {
@@ -252,6 +409,90 @@ func (r *Context) dumpResponse(request *config.Request, resp *http.Response) {
 	fmt.Fprintf(r.dump, "<<< RESPONSE [%s] <<<\n%s\n", request.Name, string(dump))
 }
 
+func (r *Context) dumpVariables(request *config.Request, datum data.Data) {
+	if r.dump == nil {
+		return
+	}
+	v := datum.Variables()
+	fmt.Fprintf(r.dump, "=== VARIABLES [%s] ===\n", request.Name)
+	for _, name := range slices.Sorted(maps.Keys(v)) {
+		fmt.Fprintf(r.dump, "%s=%s\n", name, v[name])
+	}
+	fmt.Fprintln(r.dump)
+}
+
+// StartIteration removes the sequence's variables that are reset each
+// iteration, from the user's scope (see WithUser) and its virtual users.
+// The outcomes of the user's previous iteration are forgotten.
+func (r *Context) StartIteration(ctx context.Context, seq *config.Sequence, iteration int) {
+
+	r.outcomes.Delete(outcomeKey{userFrom(ctx), seq.Name})
+
+	var names []string
+	for i := range seq.Requests {
+		for _, response := range seq.Requests[i].Responses {
+			for _, e := range response.Content.Extract {
+				if e.ResetEachIteration {
+					names = append(names, e.Name)
+				}
+			}
+		}
+	}
+
+	if len(names) > 0 {
+		logger.Debug(nil, nil, "iteration %d: reset variables: %s", iteration, strings.Join(names, ", "))
+		r.userScope(ctx).Reset(names...)
+	}
+}
+
+// outcomeKey identifies the outcomes of a user's sequence.  Requests of
+// different sequences may have the same name.
+type outcomeKey struct {
+	user     int
+	sequence string
+}
+
+// userOutcomes returns the outcomes of the context's user, for a sequence.
+func (r *Context) userOutcomes(ctx context.Context, sequence string) *sync.Map {
+
+	v, _ := r.outcomes.LoadOrStore(outcomeKey{userFrom(ctx), sequence}, &sync.Map{})
+	return v.(*sync.Map)
+}
+
+// setOutcome records the response to a request, for conditions.
+func (r *Context) setOutcome(ctx context.Context, request *config.Request, response *config.Response) {
+
+	outcome := condition.Outcome{}
+	if response != nil {
+		outcome.Response = response.Name
+		outcome.Status = response.StatusCode
+	}
+
+	r.userOutcomes(ctx, request.Sequence).Store(request.Name, outcome)
+}
+
+// Evaluate evaluates a condition against the variables of the context's
+// user (see WithUser), and the most recent responses to the requests of its
+// sequence.  Previous names the previously executed request.
+func (r *Context) Evaluate(ctx context.Context, seq *config.Sequence, c *condition.Condition, previous string) bool {
+
+	outcomes := r.userOutcomes(ctx, seq.Name)
+
+	in := &condition.Input{
+		Outcome: func(name string) condition.Outcome {
+			if name == "" {
+				name = previous
+			}
+			v, _ := outcomes.Load(name)
+			outcome, _ := v.(condition.Outcome)
+			return outcome
+		},
+		Lookup: r.userScope(ctx).Lookup,
+	}
+
+	return c.Evaluate(in)
+}
+
 // Push sends collected metrics to the Prometheus push gateway.
 func (r *Context) Push() error {
 	return r.metrics.Push()
@@ -277,40 +518,197 @@ func retryDelay(attempt int, baseDelay, maxDelay time.Duration) time.Duration {
 	return d
 }
 
+// logErrors logs an error.  Joined errors (eg: schema violations) are
+// logged individually.  When seenErrors is non-nil, duplicates are suppressed.
+func logErrors(request *config.Request, err error, seenErrors *sync.Map) {
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			logErrors(request, e, seenErrors)
+		}
+		return
+	}
+
+	duplicate := false
+	if seenErrors != nil {
+		_, duplicate = seenErrors.LoadOrStore(err.Error(), true)
+	}
+
+	if !duplicate {
+		logger.Error(request, nil, "%v", err)
+	}
+}
+
+type userKey struct{}
+
+// WithUser returns a context for executing requests as a user of a
+// sequence, with its own data scope.
+func WithUser(ctx context.Context, user int) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// userFrom returns the context's user, or data.Shared.
+func userFrom(ctx context.Context) int {
+
+	user, ok := ctx.Value(userKey{}).(int)
+	if !ok {
+		return data.Shared
+	}
+	return user
+}
+
+// userScope returns the data scope of the context's user, or the scenario
+// scope.
+func (r *Context) userScope(ctx context.Context) data.Data {
+	return r.datum.User(userFrom(ctx))
+}
+
+// bindRow binds the columns of the next data source row, if any, as
+// variables of the data scope.
+func bindRow(datum data.Data, request *config.Request) error {
+
+	if request.Feeder == nil {
+		return nil
+	}
+
+	row, err := request.Feeder.Next(datum)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range row {
+		err = datum.AddReplacement(name, value)
+		if err != nil {
+			return fmt.Errorf("data source %s: %w", request.Feeder.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// Options are the optional settings of an execution.
+type Options struct {
+	// The stage, if the thundering herd is staged...
+	Stage *config.Stage
+
+	// The intended send time, durations are measured from it.  If zero,
+	// from now...
+	Scheduled time.Time
+
+	// If set, duplicate error messages are not logged, but are still
+	// counted in the statistics...
+	SeenErrors *sync.Map
+}
+
 // Execute creates and executes the request then validates the response.
-// Returns true if an error occurred. When seenErrors is non-nil, duplicate
-// error messages are suppressed from logging (but still counted in stats).
-func (r *Context) Execute(ctx context.Context, iteration int, request *config.Request, seenErrors *sync.Map) bool {
+// Returns true if an error occurred.  The vu selects the virtual user's
+// data scope, or data.Shared, within the user's scope (see WithUser).  The
+// foreach element, if any, is taken from ctx (see WithElement).
+//
+// Nothing is executed without a data source row when on_exhaust is set to
+// stop.  A request with a poll is sent until its condition is met.
+func (r *Context) Execute(ctx context.Context, iteration int, vu int, request *config.Request, opts Options) bool {
+
+	stage := opts.Stage
+	seenErrors := opts.SeenErrors
+
+	start := opts.Scheduled
+	if start.IsZero() {
+		start = time.Now()
+	}
+
+	datum := r.userScope(ctx).Scope(vu)
 
-	start := time.Now()
+	err := bindRow(datum, request)
+	if errors.Is(err, data.ErrExhausted) && request.Feeder.Stops() {
+		return false
+	}
+
+	if err == nil {
+		err = bindElement(ctx, datum, request)
+	}
 
-	response, err := r.Gestalt(ctx, request)
 	if err != nil {
-		errMsg := err.Error()
-		duplicate := false
-		if seenErrors != nil {
-			_, duplicate = seenErrors.LoadOrStore(errMsg, true)
+		return r.record(ctx, iteration, request, stage, nil, nil, start, err, seenErrors) != nil
+	}
+
+	if request.Poll != nil {
+		return r.poll(ctx, iteration, datum, request, stage, start, seenErrors)
+	}
+
+	return r.send(ctx, iteration, datum, request, stage, start, seenErrors) != nil
+}
+
+// send sends the request and records the outcome.
+func (r *Context) send(ctx context.Context, iteration int, datum data.Data, request *config.Request, stage *config.Stage, start time.Time, seenErrors *sync.Map) error {
+
+	ctx, t := withTrace(ctx)
+
+	response, err := r.Gestalt(ctx, datum, iteration, request)
+
+	return r.record(ctx, iteration, request, stage, response, t, start, err, seenErrors)
+}
+
+// record records the outcome of a request in the statistics and metrics.
+// Responses to a pending poll are successful.  Returns err.
+func (r *Context) record(ctx context.Context, iteration int, request *config.Request, stage *config.Stage, response *config.Response, t *trace, start time.Time, err error, seenErrors *sync.Map) error {
+
+	stageName := ""
+	if stage != nil {
+		stageName = stage.Name
+	}
+
+	r.setOutcome(ctx, request, response)
+
+	// Phases are only meaningful once a response is received...
+	if response != nil {
+		timings := t.timings()
+		r.metrics.Phases(iteration, request.Sequence, stageName, request.Name, timings)
+		request.Stats.Timing(timings)
+		response.Stats.Timing(timings)
+		if stage != nil {
+			stage.Stats.Timing(timings)
 		}
+	}
+
+	if err != nil && !(response != nil && only(err, ErrPollPending)) {
+		logErrors(request, err, seenErrors)
 
-		if !duplicate {
-			logger.Error(request, nil, "%v", err)
+		// Otherwise valid responses outside the expected duration are
+		// counted separately...
+		slow := response != nil && only(err, ErrDuration)
+
+		if stage != nil {
+			if slow {
+				stage.Stats.DurationError(start)
+			} else {
+				stage.Stats.Error(start)
+			}
 		}
 
-		if response == nil {
-			r.metrics.Errors(iteration, request.Name, metrics.NoResponseName)
+		switch {
+		case response == nil:
+			r.metrics.Errors(iteration, request.Sequence, stageName, request.Name, metrics.NoResponseName)
 			request.Stats.Error(start)
-		} else {
-			r.metrics.Errors(iteration, request.Name, response.Name)
+			request.Unanswered.Error(start)
+		case slow:
+			r.metrics.Errors(iteration, request.Sequence, stageName, request.Name, response.Name)
+			response.Stats.DurationError(start)
+		default:
+			r.metrics.Errors(iteration, request.Sequence, stageName, request.Name, response.Name)
 			response.Stats.Error(start)
 		}
-		return true
+		return err
 	}
 
 	status := strconv.Itoa(response.StatusCode)
 
-	r.metrics.Durations(start, iteration, request.Name, request.Method, response.Name, status)
-	r.metrics.Requests(iteration, request.Name, response.Name, status)
+	r.metrics.Durations(start, iteration, request.Sequence, stageName, request.Name, request.Method, response.Name, status)
+	r.metrics.Requests(iteration, request.Sequence, stageName, request.Name, response.Name, status)
 	request.Stats.Success(start)
 	response.Stats.Success(start)
-	return false
+	if stage != nil {
+		stage.Stats.Success(start)
+	}
+	return err
 }
@@ -8,16 +8,27 @@ package rest
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/pwmorreale/rapid/assertion"
+	"github.com/pwmorreale/rapid/condition"
 	"github.com/pwmorreale/rapid/config"
 	"github.com/pwmorreale/rapid/data"
 	"github.com/pwmorreale/rapid/logger"
+	"github.com/pwmorreale/rapid/stats"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -138,7 +149,29 @@ func TestExecute(t *testing.T) {
 
 	ctx := context.Background()
 
-	r.Execute(ctx, 1, &sc.Sequence.Requests[0], nil)
+	r.Execute(ctx, 1, data.Shared, &sc.Sequence.Requests[0], Options{})
+}
+
+func TestExecuteScheduled(t *testing.T) {
+
+	r, sc, _, err := initTestService(t)
+	assert.Nil(t, err)
+
+	initLogger(io.Discard)
+
+	request := &sc.Sequence.Requests[0]
+	httpResponse := makeResponseFromResponse(request.Responses[0], []byte(json))
+
+	r.mockRoundTripper = &TestingTransport{
+		Response: httpResponse,
+		Error:    nil,
+	}
+
+	// Durations include the time spent waiting to be sent.
+	scheduled := time.Now().Add(-time.Second)
+	errored := r.Execute(context.Background(), 1, data.Shared, request, Options{Scheduled: scheduled})
+	assert.False(t, errored)
+	assert.GreaterOrEqual(t, request.Stats.GetMinDuration(), time.Second)
 }
 func TestCreateRequest(t *testing.T) {
 
@@ -149,7 +182,7 @@ func TestCreateRequest(t *testing.T) {
 
 	ctx := context.Background()
 
-	request, err := r.createRequest(ctx, &sc.Sequence.Requests[0])
+	request, err := r.createRequest(ctx, r.datum, 1, &sc.Sequence.Requests[0])
 	assert.Nil(t, err)
 	assert.Equal(t, testURL, request.URL.String())
 
@@ -306,7 +339,7 @@ func TestVerifyJSONContent(t *testing.T) {
 	err = r.verifyContent([]byte(json), response, configResponse)
 	assert.Nil(t, err)
 
-	err = r.extractContent([]byte(json), configResponse)
+	err = r.extractContent(r.datum, []byte(json), response, configResponse)
 	assert.Nil(t, err)
 
 	assert.Equal(t, "doo", d.Lookup("foo"))
@@ -325,12 +358,216 @@ func TestVerifyXMLContent(t *testing.T) {
 	err = r.verifyContent([]byte(xml), response, configResponse)
 	assert.Nil(t, err)
 
-	err = r.extractContent([]byte(xml), configResponse)
+	err = r.extractContent(r.datum, []byte(xml), response, configResponse)
 	assert.Nil(t, err)
 
 	assert.Equal(t, "Bob Ross", d.Lookup("who"))
 }
 
+func TestVerifyAssertions(t *testing.T) {
+
+	r, _, _, err := initTestService(t)
+	assert.Nil(t, err)
+
+	c := config.New()
+	sc, err := c.ParseFile("../testdata/configs/assert.yaml")
+	assert.Nil(t, err)
+
+	configResponse := sc.Sequence.Requests[0].Responses[0]
+
+	content := []byte(`{"items": [1], "user": {"email": "bob@corp"}, "status": "active", "errors": 0}`)
+	response := makeResponse(200, "application/json", content, int64(len(content)), nil, nil)
+
+	err = r.verifyContent(content, response, configResponse)
+	assert.Nil(t, err)
+
+	content = []byte(`{"items": [], "user": {"email": "bob@corp"}, "status": "closed", "errors": 0}`)
+	response = makeResponse(200, "application/json", content, int64(len(content)), nil, nil)
+
+	err = r.verifyContent(content, response, configResponse)
+	assert.EqualError(t, err, "assert items.# >= 1: got 0\nassert status in [\"active\",\"pending\"]: got \"closed\"")
+}
+
+func TestVerifyXPathAssertions(t *testing.T) {
+
+	r, _, _, err := initTestService(t)
+	assert.Nil(t, err)
+
+	c := config.New()
+	sc, err := c.ParseFile("../testdata/configs/assert.yaml")
+	assert.Nil(t, err)
+
+	configResponse := sc.Sequence.Requests[1].Responses[0]
+
+	content := []byte(`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body>
+<PaintersResponse xmlns="urn:painters"><Painter id="1"><Name>Bob Ross</Name></Painter><Painter id="2"><Name>Frida Kahlo</Name></Painter></PaintersResponse>
+</s:Body></s:Envelope>`)
+	response := makeResponse(200, "text/xml", content, int64(len(content)), nil, nil)
+
+	// Prefixes need not match the document's...
+	err = r.verifyContent(content, response, configResponse)
+	assert.Nil(t, err)
+
+	content = []byte(`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body>
+<PaintersResponse xmlns="urn:painters"><Painter id="1"><Name>Mary Cassatt</Name></Painter></PaintersResponse>
+</s:Body></s:Envelope>`)
+	response = makeResponse(200, "text/xml", content, int64(len(content)), nil, nil)
+
+	err = r.verifyContent(content, response, configResponse)
+	assert.EqualError(t, err, "assert //p:Painter count 2: got 1\nassert //p:Painter[@id='1']/p:Name == \"Bob Ross\": got \"Mary Cassatt\"")
+}
+
+func TestVerifySchema(t *testing.T) {
+
+	r, sc, _, err := initTestService(t)
+	assert.NotNil(t, r)
+	assert.NotNil(t, sc)
+	assert.Nil(t, err)
+
+	configResponse := sc.Sequence.Requests[0].Responses[0]
+	assert.NotNil(t, configResponse.Content.SchemaCompiled)
+
+	err = r.verifySchema([]byte(json), configResponse)
+	assert.Nil(t, err)
+
+	// Two violations, each reported separately with its JSON pointer.
+	bad := `{"foo": 42, "goo": {"moo": {"boo": "moo"}}}`
+	err = r.verifySchema([]byte(bad), configResponse)
+	assert.NotNil(t, err)
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(joined.Unwrap()))
+	assert.Contains(t, err.Error(), "schema: /foo:")
+	assert.Contains(t, err.Error(), "schema: /goo/moo/boo:")
+
+	assert.Equal(t, "(root)", schemaPointer(nil))
+	assert.Equal(t, "/", schemaPointer([]string{""}))
+	assert.Equal(t, "/a~1b/~0c", schemaPointer([]string{"a/b", "~c"}))
+
+	err = r.verifySchema([]byte("not json"), configResponse)
+	assert.Contains(t, err.Error(), "schema: content is not valid JSON")
+
+	// No schema configured...
+	err = r.verifySchema([]byte("not json"), sc.Sequence.Requests[0].Responses[2])
+	assert.Nil(t, err)
+}
+
+func TestLogErrorsJoined(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	request := &config.Request{Name: "joined"}
+
+	err := errors.Join(errors.New("one"), errors.New("two"), errors.New("one"))
+
+	logErrors(request, err, nil)
+	assert.Equal(t, 3, logger.ErrorCount())
+
+	initLogger(io.Discard)
+
+	logErrors(request, err, &sync.Map{})
+	assert.Equal(t, 2, logger.ErrorCount())
+}
+
+func TestValidateConformance(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	c := config.New()
+	sc, err := c.ParseFile("../testdata/configs/openapi.yaml")
+	assert.Nil(t, err)
+	assert.NotNil(t, sc.OpenAPI.Spec)
+
+	r := New(sc, data.New(), nil)
+	request := &sc.Sequence.Requests[0]
+
+	ctx := context.Background()
+
+	req, err := r.createRequest(ctx, r.datum, 1, request)
+	assert.Nil(t, err)
+
+	// Conforming...
+	body := `{"id": 42, "name": "Bob Ross"}`
+	resp, err := r.validateResponse(req, makeResponse(200, "application/json", []byte(body), int64(len(body)), nil, nil), r.datum, request)
+	assert.Nil(t, err)
+	assert.Equal(t, "success", resp.Name)
+
+	// Configured response, but the body doesn't conform.
+	body = `{"id": 42}`
+	resp, err = r.validateResponse(req, makeResponse(200, "application/json", []byte(body), int64(len(body)), nil, nil), r.datum, request)
+	assert.Contains(t, err.Error(), `property "name" is missing`)
+	assert.Equal(t, "success", resp.Name)
+
+	// Configured response, but not documented in the spec.
+	resp, err = r.validateResponse(req, makeResponse(500, "", []byte{}, 0, nil, nil), r.datum, request)
+	assert.Contains(t, err.Error(), "undocumented status code 500")
+	assert.Equal(t, config.UndocumentedResponseName, resp.Name)
+	assert.Equal(t, 1, len(request.UndocumentedResponses))
+	assert.Empty(t, request.UnknownResponses)
+
+	// Same status code reuses the entry...
+	_, _ = r.validateResponse(req, makeResponse(500, "", []byte{}, 0, nil, nil), r.datum, request)
+	assert.Equal(t, 1, len(request.UndocumentedResponses))
+}
+
+func TestSnapshot(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	c := config.New()
+	sc, err := c.ParseFile("../testdata/configs/snapshot.yaml")
+	assert.Nil(t, err)
+	sc.SnapshotDir = t.TempDir()
+
+	r := New(sc, data.New(), nil)
+	request := &sc.Sequence.Requests[0]
+
+	req, err := r.createRequest(context.Background(), r.datum, 1, request)
+	assert.Nil(t, err)
+
+	// The first response is recorded...
+	body := `{"items": [{"id": 1, "name": "Bob Ross"}], "updated_at": "2025-01-01"}`
+	resp, err := r.validateResponse(req, makeResponse(200, "application/json", []byte(body), int64(len(body)), nil, nil), r.datum, request)
+	assert.Nil(t, err)
+	assert.Equal(t, "success", resp.Name)
+	assert.FileExists(t, filepath.Join(sc.SnapshotDir, "painters_success.snap"))
+
+	// Ignored paths may differ...
+	body = `{"items": [{"id": 7, "name": "Bob Ross"}], "updated_at": "2025-06-01"}`
+	_, err = r.validateResponse(req, makeResponse(200, "application/json", []byte(body), int64(len(body)), nil, nil), r.datum, request)
+	assert.Nil(t, err)
+	assert.Empty(t, resp.SnapshotDiffs)
+
+	body = `{"items": [{"id": 7, "name": "Frida Kahlo"}], "updated_at": "2025-06-01"}`
+	_, err = r.validateResponse(req, makeResponse(200, "application/json", []byte(body), int64(len(body)), nil, nil), r.datum, request)
+	path := filepath.Join(sc.SnapshotDir, "painters_success.snap")
+	assert.EqualError(t, err, "snapshot "+path+`: items.0.name: expected "Bob Ross", got "Frida Kahlo"`)
+	assert.Equal(t, []string{`items.0.name: expected "Bob Ross", got "Frida Kahlo"`}, resp.SnapshotDiffs)
+
+	// The same difference is kept once...
+	_, _ = r.validateResponse(req, makeResponse(200, "application/json", []byte(body), int64(len(body)), nil, nil), r.datum, request)
+	assert.Len(t, resp.SnapshotDiffs, 1)
+
+	// Updating rewrites the snapshot...
+	sc.UpdateSnapshots = true
+	r = New(sc, data.New(), nil)
+	_, err = r.validateResponse(req, makeResponse(200, "application/json", []byte(body), int64(len(body)), nil, nil), r.datum, request)
+	assert.Nil(t, err)
+
+	b, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "Frida Kahlo")
+
+	// Content larger than max_content is not recorded...
+	sc.SnapshotDir = t.TempDir()
+	request.Responses[0].Content.MaxSize = 16
+	r = New(sc, data.New(), nil)
+	_, err = r.validateResponse(req, makeResponse(200, "application/json", []byte(body), int64(len(body)), nil, nil), r.datum, request)
+	assert.EqualError(t, err, "snapshot: content exceeds max_content (16 bytes)")
+	assert.NoFileExists(t, filepath.Join(sc.SnapshotDir, "painters_success.snap"))
+}
+
 func TestFindOrCreateUnknown(t *testing.T) {
 
 	r, sc, _, err := initTestService(t)
@@ -396,7 +633,7 @@ func TestRetryOnStatusCode(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	resp, err := r.Gestalt(ctx, request)
+	resp, err := r.Gestalt(ctx, r.datum, 1, request)
 	assert.Nil(t, err)
 	assert.NotNil(t, resp)
 	assert.Equal(t, 200, resp.StatusCode)
@@ -431,7 +668,7 @@ func TestRetryExhausted(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	resp, err := r.Gestalt(ctx, request)
+	resp, err := r.Gestalt(ctx, r.datum, 1, request)
 	// When retries are exhausted, the last response is validated normally.
 	// 503 doesn't match any configured response, so findOrCreateUnknown runs.
 	assert.Nil(t, err)
@@ -472,7 +709,7 @@ func TestRetryOnConnectionError(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	resp, err := r.Gestalt(ctx, request)
+	resp, err := r.Gestalt(ctx, r.datum, 1, request)
 	assert.Nil(t, err)
 	assert.NotNil(t, resp)
 	assert.Equal(t, 200, resp.StatusCode)
@@ -505,7 +742,7 @@ func TestNoRetryByDefault(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	r.Gestalt(ctx, request)
+	r.Gestalt(ctx, r.datum, 1, request)
 	assert.Equal(t, 1, callCount)
 }
 
@@ -521,3 +758,940 @@ func (t *countingTransport) RoundTrip(*http.Request) (*http.Response, error) {
 	}
 	return resp, nil
 }
+
+func TestClientShared(t *testing.T) {
+
+	sc := &config.Scenario{}
+	r := New(sc, data.New(), nil)
+
+	request1 := &config.Request{Name: "request1"}
+	request2 := &config.Request{Name: "request2"}
+
+	c1, err := r.client(request1)
+	assert.Nil(t, err)
+
+	c2, err := r.client(request2)
+	assert.Nil(t, err)
+	assert.Same(t, c1, c2)
+
+	tr := c1.Transport.(*http.Transport)
+	assert.True(t, tr.DisableKeepAlives)
+	assert.Equal(t, DefaultTLSHandshakeTimeout, tr.TLSHandshakeTimeout)
+
+	request2.Transport = &config.TransportConfig{
+		KeepAlives:            true,
+		MaxIdleConns:          10,
+		MaxIdleConnsPerHost:   5,
+		MaxConnsPerHost:       20,
+		IdleTimeout:           time.Minute,
+		TLSHandshakeTimeout:   time.Second,
+		ResponseHeaderTimeout: 2 * time.Second,
+	}
+
+	c2, err = r.client(request2)
+	assert.Nil(t, err)
+	assert.NotSame(t, c1, c2)
+
+	tr = c2.Transport.(*http.Transport)
+	assert.False(t, tr.DisableKeepAlives)
+	assert.Equal(t, 10, tr.MaxIdleConns)
+	assert.Equal(t, 5, tr.MaxIdleConnsPerHost)
+	assert.Equal(t, 20, tr.MaxConnsPerHost)
+	assert.Equal(t, time.Minute, tr.IdleConnTimeout)
+	assert.Equal(t, time.Second, tr.TLSHandshakeTimeout)
+	assert.Equal(t, 2*time.Second, tr.ResponseHeaderTimeout)
+}
+
+func TestKeepAlives(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var conns atomic.Int64
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("more content than is read"))
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	for _, keepAlives := range []bool{false, true} {
+
+		conns.Store(0)
+
+		sc := &config.Scenario{RequestTimeout: time.Second}
+		sc.Transport.KeepAlives = keepAlives
+
+		r := New(sc, data.New(), nil)
+
+		request := &config.Request{
+			Name:   "keep-alive",
+			Method: "get",
+			URL:    ts.URL,
+			Responses: []*config.Response{
+				{Name: "ok", StatusCode: 200, Content: config.ContentData{Expected: true, MediaType: "text/plain", MaxSize: 4}},
+			},
+		}
+
+		for range 5 {
+			errored := r.Execute(context.Background(), 1, data.Shared, request, Options{})
+			assert.False(t, errored)
+		}
+
+		if keepAlives {
+			assert.Equal(t, int64(1), conns.Load())
+		} else {
+			assert.Equal(t, int64(5), conns.Load())
+		}
+	}
+}
+
+func TestCloseBodyStreaming(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+		w.(http.Flusher).Flush()
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	sc := &config.Scenario{RequestTimeout: 5 * time.Second}
+
+	r := New(sc, data.New(), nil)
+
+	request := &config.Request{
+		Name:   "stream",
+		Method: "get",
+		URL:    ts.URL,
+		Responses: []*config.Response{
+			{Name: "ok", StatusCode: 200, Content: config.ContentData{Expected: true, MediaType: "text/plain", MaxSize: 2}},
+		},
+	}
+
+	// The rest of the stream is not read without keep-alives...
+	start := time.Now()
+	errored := r.Execute(context.Background(), 1, data.Shared, request, Options{})
+	assert.False(t, errored)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestH2CMultiplexed(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var conns atomic.Int64
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	ts.Config.Protocols = &http.Protocols{}
+	ts.Config.Protocols.SetHTTP1(true)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	sc := &config.Scenario{RequestTimeout: time.Second}
+	sc.Transport.KeepAlives = true
+	sc.Transport.Protocol = config.ProtocolH2C
+
+	r := New(sc, data.New(), nil)
+
+	response := &config.Response{Name: "ok", StatusCode: 200, Protocol: config.ProtocolH2C, Content: config.ContentData{Expected: true, MediaType: "text/plain"}}
+	request := &config.Request{
+		Name:      "h2c",
+		Method:    "get",
+		URL:       ts.URL,
+		Responses: []*config.Response{response},
+	}
+
+	// Establish the connection, then multiplex concurrent streams over it.
+	errored := r.Execute(context.Background(), 1, data.Shared, request, Options{})
+	assert.False(t, errored)
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			errored := r.Execute(context.Background(), 1, data.Shared, request, Options{})
+			assert.False(t, errored)
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1), conns.Load())
+	assert.Equal(t, int64(51), request.Stats.GetCount())
+	assert.Equal(t, int64(51), response.Stats.GetCount())
+	assert.Equal(t, int64(0), response.Stats.GetErrors())
+}
+
+func TestVerifyProtocol(t *testing.T) {
+
+	r, _, _, err := initTestService(t)
+	assert.Nil(t, err)
+
+	response := &config.Response{Name: "ok", StatusCode: 200}
+	resp := makeResponse(200, "", nil, 0, nil, nil)
+	resp.Proto, resp.ProtoMajor = "HTTP/1.1", 1
+
+	assert.Nil(t, r.verifyProtocol(resp, response))
+
+	response.Protocol = config.ProtocolHTTP1
+	assert.Nil(t, r.verifyProtocol(resp, response))
+
+	response.Protocol = config.ProtocolH2
+	err = r.verifyProtocol(resp, response)
+	assert.EqualError(t, err, "protocol: http1 (HTTP/1.1) != h2")
+
+	resp.Proto, resp.ProtoMajor = "HTTP/2.0", 2
+	resp.TLS = &tls.ConnectionState{}
+	assert.Nil(t, r.verifyProtocol(resp, response))
+
+	response.Protocol = config.ProtocolH2C
+	assert.NotNil(t, r.verifyProtocol(resp, response))
+}
+
+func TestTransportProtocols(t *testing.T) {
+
+	// The Go defaults...
+	p, err := transportProtocols("")
+	assert.Nil(t, err)
+	assert.Nil(t, p)
+
+	p, err = transportProtocols(config.ProtocolAuto)
+	assert.Nil(t, err)
+	assert.True(t, p.HTTP1() && p.HTTP2())
+
+	p, err = transportProtocols(config.ProtocolH2C)
+	assert.Nil(t, err)
+	assert.True(t, p.UnencryptedHTTP2())
+	assert.False(t, p.HTTP1())
+
+	_, err = transportProtocols("spdy")
+	assert.NotNil(t, err)
+}
+
+func TestPhaseTimings(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	sc := &config.Scenario{RequestTimeout: time.Second}
+	sc.Transport.KeepAlives = true
+
+	r := New(sc, data.New(), nil)
+
+	response := &config.Response{Name: "ok", StatusCode: 200, Content: config.ContentData{Expected: true, MediaType: "text/plain"}}
+	request := &config.Request{
+		Name:      "phases",
+		Method:    "get",
+		URL:       ts.URL,
+		Responses: []*config.Response{response},
+	}
+
+	for range 2 {
+		errored := r.Execute(context.Background(), 1, data.Shared, request, Options{})
+		assert.False(t, errored)
+	}
+
+	// The connection is reused by the second request...
+	assert.Equal(t, int64(1), request.Stats.GetPhaseCount(stats.PhaseConnect))
+	assert.Equal(t, int64(0), request.Stats.GetPhaseCount(stats.PhaseTLS))
+	assert.Equal(t, int64(2), request.Stats.GetPhaseCount(stats.PhaseTTFB))
+	assert.Equal(t, int64(2), response.Stats.GetPhaseCount(stats.PhaseTransfer))
+	assert.GreaterOrEqual(t, request.Stats.GetPhaseAvg(stats.PhaseTTFB), 20*time.Millisecond)
+	assert.Less(t, request.Stats.GetPhaseMax(stats.PhaseTransfer), request.Stats.GetPhaseAvg(stats.PhaseTTFB))
+}
+
+func TestResponseDuration(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/retry" && calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	sc := &config.Scenario{RequestTimeout: time.Second}
+	r := New(sc, data.New(), nil)
+
+	response := &config.Response{Name: "ok", StatusCode: 200, MaxDuration: 200 * time.Millisecond, Content: config.ContentData{Expected: true, MediaType: "text/plain"}}
+	request := &config.Request{
+		Name:      "duration",
+		Method:    "get",
+		URL:       ts.URL,
+		Responses: []*config.Response{response},
+	}
+
+	errored := r.Execute(context.Background(), 1, data.Shared, request, Options{})
+	assert.False(t, errored)
+
+	// Durations are measured from the final attempt, not the scheduled
+	// time, nor earlier attempts and their delays...
+	errored = r.Execute(context.Background(), 1, data.Shared, request, Options{Scheduled: time.Now().Add(-time.Second)})
+	assert.False(t, errored)
+
+	request.URL = ts.URL + "/retry"
+	request.Retry = config.RetryConfig{MaxAttempts: 2, StatusCodes: []int{503}, Delay: 300 * time.Millisecond}
+	errored = r.Execute(context.Background(), 1, data.Shared, request, Options{})
+	assert.False(t, errored)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, int64(3), response.Stats.GetCount())
+
+	// A tarpit...
+	response.MinDuration = 100 * time.Millisecond
+	errored = r.Execute(context.Background(), 1, data.Shared, request, Options{})
+	assert.True(t, errored)
+	assert.Equal(t, int64(1), response.Stats.GetErrors())
+	assert.Equal(t, int64(1), response.Stats.GetDurationErrors())
+	assert.Contains(t, response.Stats.String(), "duration_errors=1")
+
+	// Other errors are not duration errors...
+	response.Content.MediaType = "application/json"
+	errored = r.Execute(context.Background(), 1, data.Shared, request, Options{})
+	assert.True(t, errored)
+	assert.Equal(t, int64(2), response.Stats.GetErrors())
+	assert.Equal(t, int64(1), response.Stats.GetDurationErrors())
+}
+
+func TestPoll(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var polls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if polls.Add(1) < 3 {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"status": "running"}`))
+			return
+		}
+		w.Write([]byte(`{"status": "done"}`))
+	}))
+	defer ts.Close()
+
+	c := config.New()
+	sc, err := c.ParseFile("../testdata/configs/poll.yaml")
+	assert.Nil(t, err)
+
+	r := New(sc, data.New(), nil)
+	request := &sc.Sequence.Requests[0]
+	request.URL = ts.URL
+
+	errored := r.Execute(context.Background(), 1, data.Shared, request, Options{})
+	assert.False(t, errored)
+
+	// Each poll is an execution...
+	assert.Equal(t, int64(3), request.Stats.GetCount())
+	assert.Equal(t, int64(2), request.Responses[0].Stats.GetCount())
+	assert.Equal(t, int64(1), request.Responses[1].Stats.GetCount())
+
+	assert.Equal(t, int64(3), request.Polling.GetPolls())
+	assert.Equal(t, int64(1), request.Polling.GetCount())
+	assert.GreaterOrEqual(t, request.Polling.GetMaxDuration(), 20*time.Millisecond)
+
+	// Still running after max_polls...
+	polls.Store(-10)
+	errored = r.Execute(context.Background(), 1, data.Shared, request, Options{})
+	assert.True(t, errored)
+	assert.Equal(t, int64(8), request.Polling.GetPolls())
+	assert.Equal(t, int64(1), request.Polling.GetErrors())
+	assert.Equal(t, int64(0), request.Responses[0].Stats.GetErrors())
+
+	// Errors end polling...
+	polls.Store(0)
+	request.Responses = request.Responses[1:]
+	errored = r.Execute(context.Background(), 1, data.Shared, request, Options{})
+	assert.True(t, errored)
+	assert.Equal(t, int64(9), request.Polling.GetPolls())
+	assert.Equal(t, int64(2), request.Polling.GetErrors())
+}
+
+func TestForEach(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var mu sync.Mutex
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if req.URL.Path == "/v1/orders" {
+			w.Write([]byte(`{"orders": [{"id": 7}, {"id": 9}]}`))
+			return
+		}
+		mu.Lock()
+		paths = append(paths, req.URL.Path)
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	c := config.New()
+	sc, err := c.ParseFile("../testdata/configs/foreach.yaml")
+	assert.Nil(t, err)
+
+	d := data.New()
+	r := New(sc, d, nil)
+
+	list := &sc.Sequence.Requests[0]
+	list.URL = ts.URL + "/v1/orders"
+	get := &sc.Sequence.Requests[1]
+	get.URL = ts.URL + "/v1/orders/ORDER_ID"
+
+	assert.False(t, r.Execute(context.Background(), 1, data.Shared, list, Options{}))
+
+	elements, err := r.Elements(context.Background(), get)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"7", "9"}, elements)
+
+	// Each element is bound for its virtual user...
+	for vu, element := range elements {
+		ctx := WithElement(context.Background(), element)
+		assert.False(t, r.Execute(ctx, 1, vu, get, Options{}))
+	}
+	assert.Equal(t, []string{"/v1/orders/7", "/v1/orders/9"}, paths)
+	assert.Equal(t, "9", d.Scope(1).Lookup("ORDER_ID"))
+	assert.Empty(t, d.Lookup("ORDER_ID"))
+
+	// A user of a sequence has its own scope...
+	ctx := WithElement(WithUser(context.Background(), 5), "11")
+	assert.False(t, r.Execute(ctx, 1, data.Shared, get, Options{}))
+	assert.Equal(t, "11", d.User(5).Lookup("ORDER_ID"))
+	assert.Empty(t, d.Lookup("ORDER_ID"))
+
+	ctx = WithElement(WithUser(context.Background(), 5), "13")
+	assert.False(t, r.Execute(ctx, 1, 0, get, Options{}))
+	assert.Equal(t, "13", d.User(5).Scope(0).Lookup("ORDER_ID"))
+	assert.Equal(t, "11", d.User(5).Lookup("ORDER_ID"))
+	assert.Equal(t, "/v1/orders/13", paths[len(paths)-1])
+
+	// ...and its own elements.
+	err = d.User(5).AddReplacement("ORDERS", `[{"id": 21}]`)
+	assert.Nil(t, err)
+	elements, err = r.Elements(WithUser(context.Background(), 5), get)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"21"}, elements)
+	elements, err = r.Elements(WithUser(context.Background(), 6), get)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"7", "9"}, elements)
+
+	// XML...
+	invoices := &sc.Sequence.Requests[2]
+	_, err = r.Elements(context.Background(), invoices)
+	assert.EqualError(t, err, "foreach: variable INVOICES is not set")
+
+	err = d.AddReplacement("INVOICES", `<invoices><invoice id="a1"/><invoice id="b2"/></invoices>`)
+	assert.Nil(t, err)
+	elements, err = r.Elements(context.Background(), invoices)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a1", "b2"}, elements)
+
+	err = d.AddReplacement("ORDERS", `{"id": 7}`)
+	assert.Nil(t, err)
+	_, err = r.Elements(context.Background(), get)
+	assert.EqualError(t, err, "foreach: JSON: Not found: #.id")
+}
+
+func TestVerifyPoll(t *testing.T) {
+
+	until := &config.PollUntil{
+		StatusCode: 200,
+		Headers:    []config.HeaderData{{Name: "X-Job-State", Value: "complete"}},
+	}
+	a, err := assertion.New("status", "==", "done")
+	assert.Nil(t, err)
+	until.AssertCompiled = append(until.AssertCompiled, a)
+
+	headers := []config.HeaderData{{Name: "X-Job-State", Value: "complete"}}
+	content := []byte(`{"status": "done"}`)
+
+	err = verifyPoll(content, makeResponse(200, "application/json", content, int64(len(content)), &headers, nil), until)
+	assert.Nil(t, err)
+
+	err = verifyPoll(content, makeResponse(202, "application/json", content, int64(len(content)), &headers, nil), until)
+	assert.EqualError(t, err, "poll pending: status 202")
+	assert.True(t, errors.Is(err, ErrPollPending))
+
+	err = verifyPoll(content, makeResponse(200, "application/json", content, int64(len(content)), nil, nil), until)
+	assert.True(t, errors.Is(err, ErrPollPending))
+
+	content = []byte(`{"status": "running"}`)
+	err = verifyPoll(content, makeResponse(200, "application/json", content, int64(len(content)), &headers, nil), until)
+	assert.EqualError(t, err, `poll pending: assert status == "done": got "running"`)
+}
+
+func TestVerifyDuration(t *testing.T) {
+
+	response := &config.Response{MaxDuration: 200 * time.Millisecond, MinDuration: 10 * time.Millisecond}
+
+	assert.Nil(t, verifyDuration(0, response))
+	assert.Nil(t, verifyDuration(100*time.Millisecond, response))
+
+	err := verifyDuration(250*time.Millisecond, response)
+	assert.EqualError(t, err, "response time: 250ms exceeds max_duration 200ms")
+	assert.True(t, only(err, ErrDuration))
+
+	err = verifyDuration(5*time.Millisecond, response)
+	assert.EqualError(t, err, "response time: 5ms is less than min_duration 10ms")
+
+	assert.True(t, only(errors.Join(err, nil), ErrDuration))
+	assert.False(t, only(errors.Join(err, errors.New("header: ETag not found")), ErrDuration))
+}
+
+func TestEvaluate(t *testing.T) {
+
+	r, _, d, err := initTestService(t)
+	assert.Nil(t, err)
+
+	seq := &config.Sequence{Name: "paint"}
+	login := &config.Request{Name: "login", Sequence: seq.Name}
+	profile := &config.Request{Name: "profile", Sequence: seq.Name}
+
+	ctx := context.Background()
+
+	r.setOutcome(ctx, login, &config.Response{Name: "success", StatusCode: 200})
+	r.setOutcome(ctx, profile, nil)
+
+	evaluateAs := func(ctx context.Context, expr, previous string) bool {
+		c, err := condition.Parse(expr)
+		assert.Nil(t, err)
+		return r.Evaluate(ctx, seq, c, previous)
+	}
+	evaluate := func(expr, previous string) bool {
+		return evaluateAs(ctx, expr, previous)
+	}
+
+	assert.True(t, evaluate("status == 200", "login"))
+	assert.True(t, evaluate("response == success", "login"))
+	assert.True(t, evaluate("status == 0", "profile"))
+	assert.True(t, evaluate("login.status == 2xx", "profile"))
+	assert.True(t, evaluate("create.status == 0", "profile"))
+	assert.True(t, evaluate("status == 0", ""))
+
+	// The latest outcome...
+	r.setOutcome(ctx, login, &config.Response{Name: "unauthorized", StatusCode: 401})
+	assert.True(t, evaluate("login.response == unauthorized", ""))
+
+	assert.True(t, evaluate(`vars.TOKEN == ""`, ""))
+	err = d.AddReplacement("TOKEN", "happy-little-tree")
+	assert.Nil(t, err)
+	assert.True(t, evaluate("vars.TOKEN == happy-little-tree", ""))
+
+	// Each user has its own outcomes and variables...
+	user := WithUser(ctx, 1)
+	assert.True(t, evaluateAs(user, "login.status == 0", ""))
+	r.setOutcome(user, login, &config.Response{Name: "success", StatusCode: 200})
+	assert.True(t, evaluateAs(user, "login.status == 200", ""))
+	assert.True(t, evaluate("login.status == 401", ""))
+
+	assert.True(t, evaluateAs(user, "vars.TOKEN == happy-little-tree", ""))
+	err = d.User(1).AddReplacement("TOKEN", "mine")
+	assert.Nil(t, err)
+	assert.True(t, evaluateAs(user, "vars.TOKEN == mine", ""))
+	assert.True(t, evaluate("vars.TOKEN == happy-little-tree", ""))
+
+	// ...which are forgotten at the start of its iterations.
+	r.StartIteration(user, seq, 1)
+	assert.True(t, evaluateAs(user, "login.status == 0", ""))
+	assert.True(t, evaluate("login.status == 401", ""))
+
+	// Requests of other sequences may share a name.
+	other := &config.Request{Name: "login", Sequence: "frame"}
+	r.setOutcome(ctx, other, &config.Response{Name: "success", StatusCode: 200})
+	assert.True(t, evaluate("login.status == 401", ""))
+}
+
+func TestEvaluateUsers(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("user") == "1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	sc := &config.Scenario{RequestTimeout: time.Second}
+	d := data.New()
+	r := New(sc, d, nil)
+
+	sequences := []*config.Sequence{{Name: "paint"}, {Name: "frame"}}
+	parse := func(expr string) *condition.Condition {
+		c, err := condition.Parse(expr)
+		assert.Nil(t, err)
+		return c
+	}
+	when := parse(`vars.CANVAS != ""`)
+	skipIf := parse("login.status == 401")
+
+	// Each user logs in to each sequence, the second is refused.
+	var wg sync.WaitGroup
+	for user := range 2 {
+		err := d.User(user).AddReplacement("USER", strconv.Itoa(user))
+		assert.Nil(t, err)
+		if user == 0 {
+			err = d.User(user).AddReplacement("CANVAS", "linen")
+			assert.Nil(t, err)
+		}
+		for _, seq := range sequences {
+			wg.Go(func() {
+				ctx := WithUser(context.Background(), user)
+				r.StartIteration(ctx, seq, 1)
+				login := &config.Request{
+					Name:     "login",
+					Sequence: seq.Name,
+					Method:   "post",
+					URL:      ts.URL + "/login?user=USER",
+					Responses: []*config.Response{
+						{Name: "success", StatusCode: 200},
+						{Name: "unauthorized", StatusCode: 401},
+					},
+				}
+				r.Execute(ctx, 1, data.Shared, login, Options{})
+			})
+		}
+	}
+	wg.Wait()
+
+	for _, seq := range sequences {
+		first := WithUser(context.Background(), 0)
+		second := WithUser(context.Background(), 1)
+
+		assert.True(t, r.Evaluate(first, seq, when, "login"))
+		assert.False(t, r.Evaluate(second, seq, when, "login"))
+		assert.False(t, r.Evaluate(first, seq, skipIf, "login"))
+		assert.True(t, r.Evaluate(second, seq, skipIf, "login"))
+	}
+}
+
+func TestCreateRequestTemplate(t *testing.T) {
+
+	r, sc, d, err := initTestService(t)
+	assert.Nil(t, err)
+
+	err = d.AddReplacement("TOKEN", "abc123")
+	assert.Nil(t, err)
+
+	// Without templates, braces are sent as is...
+	literal := &config.Request{
+		Name:    "literal",
+		Method:  "post",
+		URL:     "https://bob_ross.com/v1/paintings",
+		Content: `{"mustache": "{{ .name }}", "token": "TOKEN"}`,
+	}
+
+	req, err := r.createRequest(context.Background(), r.datum, 7, literal)
+	assert.Nil(t, err)
+	contents, err := io.ReadAll(req.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"mustache": "{{ .name }}", "token": "abc123"}`, string(contents))
+
+	sc.Templates = true
+
+	request := &config.Request{
+		Name:         "template",
+		Method:       "post",
+		URL:          "https://bob_ross.com/v1/paintings/{{ .iteration }}",
+		Content:      `{"token": "{{ .vars.TOKEN }}"}`,
+		ExtraHeaders: []config.HeaderData{{Name: "X-Iteration", Value: "{{ .iteration }}"}},
+		Cookies:      []config.CookieData{{Value: "session={{ upper .vars.TOKEN }}"}},
+	}
+
+	req, err = r.createRequest(context.Background(), r.datum, 7, request)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://bob_ross.com/v1/paintings/7", req.URL.String())
+	assert.Equal(t, "7", req.Header.Get("X-Iteration"))
+	assert.Equal(t, "session=ABC123", req.Header.Get("Cookie"))
+
+	contents, err = io.ReadAll(req.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"token": "abc123"}`, string(contents))
+
+	request.URL = "https://bob_ross.com/{{ .vars.MISSING }}"
+	_, err = r.createRequest(context.Background(), r.datum, 7, request)
+	assert.ErrorContains(t, err, "url: ")
+}
+
+func TestVirtualUserScopes(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var logins atomic.Int64
+	var mu sync.Mutex
+	var auth []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if req.URL.Path == "/login" {
+			fmt.Fprintf(w, `{"token": "token-%d"}`, logins.Add(1))
+			return
+		}
+		mu.Lock()
+		auth = append(auth, req.Header.Get("Authorization"))
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	sc := &config.Scenario{RequestTimeout: time.Second}
+	d := data.New()
+	r := New(sc, d, nil)
+
+	login := &config.Request{
+		Name:   "login",
+		Method: "post",
+		URL:    ts.URL + "/login",
+		Responses: []*config.Response{{Name: "ok", StatusCode: 200, Content: config.ContentData{
+			Expected:  true,
+			MediaType: "application/json",
+			Extract:   []config.ExtractData{{Type: "json", Path: "token", Name: "AUTH_TOKEN"}},
+		}}},
+	}
+
+	fetch := &config.Request{
+		Name:         "fetch",
+		Method:       "get",
+		URL:          ts.URL + "/fetch",
+		ExtraHeaders: []config.HeaderData{{Name: "Authorization", Value: "Bearer AUTH_TOKEN"}},
+		Responses:    []*config.Response{{Name: "ok", StatusCode: 200, Content: config.ContentData{Expected: true, MediaType: "application/json"}}},
+	}
+
+	assert.False(t, r.Execute(context.Background(), 1, 0, login, Options{}))
+	assert.False(t, r.Execute(context.Background(), 1, 1, login, Options{}))
+
+	token0 := d.Scope(0).Lookup("AUTH_TOKEN")
+	token1 := d.Scope(1).Lookup("AUTH_TOKEN")
+	assert.NotEmpty(t, token0)
+	assert.NotEqual(t, token0, token1)
+	assert.Empty(t, d.Lookup("AUTH_TOKEN"))
+
+	assert.False(t, r.Execute(context.Background(), 1, 1, fetch, Options{}))
+	assert.False(t, r.Execute(context.Background(), 1, 0, fetch, Options{}))
+
+	assert.Equal(t, []string{"Bearer " + token1, "Bearer " + token0}, auth)
+}
+
+func TestDataSource(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var mu sync.Mutex
+	var paths []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		paths = append(paths, req.URL.Path)
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	sc := &config.Scenario{RequestTimeout: time.Second}
+	r := New(sc, data.New(), nil)
+
+	newRequest := func(onExhaust string) *config.Request {
+		f, err := data.NewFeeder("painters", "../testdata/sources/painters.csv", "", data.OrderSequential, onExhaust)
+		assert.Nil(t, err)
+		return &config.Request{
+			Name:      "painter",
+			Method:    "get",
+			URL:       ts.URL + "/painters/PAINTER_ID",
+			Feeder:    f,
+			Responses: []*config.Response{{Name: "ok", StatusCode: 200}},
+		}
+	}
+
+	request := newRequest(data.ExhaustStop)
+	for range 4 {
+		assert.False(t, r.Execute(context.Background(), 0, data.Shared, request, Options{}))
+	}
+
+	// Nothing is sent, or counted, once the source is exhausted...
+	assert.Equal(t, []string{"/painters/1", "/painters/2", "/painters/3"}, paths)
+	assert.Equal(t, int64(0), request.Stats.GetErrors())
+
+	request = newRequest(data.ExhaustError)
+	for range 3 {
+		assert.False(t, r.Execute(context.Background(), 0, data.Shared, request, Options{}))
+	}
+	assert.True(t, r.Execute(context.Background(), 0, data.Shared, request, Options{}))
+	assert.Equal(t, int64(1), request.Stats.GetErrors())
+	assert.Len(t, paths, 6)
+}
+
+func TestDataSourceUniqueUsers(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var mu sync.Mutex
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		paths = append(paths, req.URL.Path)
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	sc := &config.Scenario{RequestTimeout: time.Second}
+	r := New(sc, data.New(), nil)
+
+	f, err := data.NewFeeder("painters", "../testdata/sources/painters.csv", "", data.OrderUnique, data.ExhaustStop)
+	assert.Nil(t, err)
+	request := &config.Request{
+		Name:      "painter",
+		Method:    "get",
+		URL:       ts.URL + "/painters/PAINTER_ID",
+		Feeder:    f,
+		Responses: []*config.Response{{Name: "ok", StatusCode: 200}},
+	}
+
+	// Each user, and each of its virtual users, has its own row...
+	first := WithUser(context.Background(), 0)
+	second := WithUser(context.Background(), 1)
+	for range 2 {
+		assert.False(t, r.Execute(first, 0, data.Shared, request, Options{}))
+		assert.False(t, r.Execute(second, 0, data.Shared, request, Options{}))
+		assert.False(t, r.Execute(second, 0, 0, request, Options{}))
+	}
+	assert.Equal(t, []string{"/painters/1", "/painters/2", "/painters/3", "/painters/1", "/painters/2", "/painters/3"}, paths)
+
+	// ...and another user is refused.
+	assert.False(t, r.Execute(WithUser(context.Background(), 2), 0, data.Shared, request, Options{}))
+	assert.Len(t, paths, 6)
+}
+
+func TestExtractUnset(t *testing.T) {
+
+	r, _, d, err := initTestService(t)
+	assert.Nil(t, err)
+
+	err = d.AddReplacement("TOKEN", "abc")
+	assert.Nil(t, err)
+
+	response := &config.Response{
+		Name:       "logout",
+		StatusCode: 200,
+		Content:    config.ContentData{Extract: []config.ExtractData{{Name: "TOKEN", Unset: true}}},
+	}
+
+	err = r.extractContent(d, nil, nil, response)
+	assert.Nil(t, err)
+	assert.Equal(t, "", d.Lookup("TOKEN"))
+}
+
+func TestExtractResponseMetadata(t *testing.T) {
+
+	r, _, d, err := initTestService(t)
+	assert.Nil(t, err)
+
+	headers := []config.HeaderData{
+		{Name: "Location", Value: "/v1/painters/42"},
+		{Name: "ETag", Value: `"abc123"`},
+		{Name: "Set-Cookie", Value: "session=xyzzy; Path=/"},
+	}
+	httpResponse := makeResponse(201, "", nil, 0, &headers, nil)
+
+	response := &config.Response{
+		Name:       "created",
+		StatusCode: 201,
+		Content: config.ContentData{Extract: []config.ExtractData{
+			{Type: "header", Path: "location", Name: "PAINTER_URL"},
+			{Type: "header", Path: "ETag", Name: "ETAG"},
+			{Type: "cookie", Path: "session", Name: "SESSION"},
+			{Type: "status", Name: "STATUS"},
+		}},
+	}
+
+	err = r.extractContent(d, nil, httpResponse, response)
+	assert.Nil(t, err)
+	assert.Equal(t, "/v1/painters/42", d.Lookup("PAINTER_URL"))
+	assert.Equal(t, `"abc123"`, d.Lookup("ETAG"))
+	assert.Equal(t, "xyzzy", d.Lookup("SESSION"))
+	assert.Equal(t, "201", d.Lookup("STATUS"))
+
+	response.Content.Extract = []config.ExtractData{{Type: "text", Path: `id=(\d+)`, Group: "1", Index: "all", Name: "IDS"}}
+	err = r.extractContent(d, []byte("id=1 id=2"), httpResponse, response)
+	assert.Nil(t, err)
+	assert.Equal(t, "1,2", d.Lookup("IDS"))
+
+	response.Content.Extract = []config.ExtractData{{Type: "header", Path: "X-Request-Id", Name: "REQUEST_ID"}}
+	err = r.extractContent(d, nil, httpResponse, response)
+	assert.EqualError(t, err, "extract header not found: X-Request-Id")
+
+	response.Content.Extract = []config.ExtractData{{Type: "cookie", Path: "csrf", Name: "CSRF"}}
+	err = r.extractContent(d, nil, httpResponse, response)
+	assert.EqualError(t, err, "extract cookie not found: csrf")
+}
+
+func TestStartIteration(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	sc := &config.Scenario{}
+	sc.Sequence.Requests = []config.Request{{
+		Name: "login",
+		Responses: []*config.Response{{Content: config.ContentData{Extract: []config.ExtractData{
+			{Type: "json", Path: "token", Name: "TOKEN", ResetEachIteration: true},
+			{Type: "json", Path: "id", Name: "ID"},
+		}}}},
+	}}
+
+	d := data.New()
+	r := New(sc, d, nil)
+
+	assert.Nil(t, d.AddReplacement("TOKEN", "abc"))
+	assert.Nil(t, d.AddReplacement("ID", "1"))
+	assert.Nil(t, d.Scope(0).AddReplacement("TOKEN", "def"))
+
+	r.StartIteration(context.Background(), &sc.Sequence, 1)
+
+	assert.Equal(t, "", d.Lookup("TOKEN"))
+	assert.Equal(t, "", d.Scope(0).Lookup("TOKEN"))
+	assert.Equal(t, "1", d.Lookup("ID"))
+
+	// Only the user's variables...
+	assert.Nil(t, d.AddReplacement("TOKEN", "abc"))
+	assert.Nil(t, d.Scope(0).AddReplacement("TOKEN", "def"))
+	assert.Nil(t, d.User(1).AddReplacement("TOKEN", "ghi"))
+	assert.Nil(t, d.User(1).Scope(0).AddReplacement("TOKEN", "jkl"))
+
+	r.StartIteration(WithUser(context.Background(), 1), &sc.Sequence, 2)
+
+	assert.Equal(t, "abc", d.Lookup("TOKEN"))
+	assert.Equal(t, "def", d.Scope(0).Lookup("TOKEN"))
+	assert.Equal(t, "abc", d.User(1).Lookup("TOKEN"))
+	assert.Equal(t, "abc", d.User(1).Scope(0).Lookup("TOKEN"))
+}
+
+func TestDumpVariables(t *testing.T) {
+
+	var buf bytes.Buffer
+
+	d := data.New()
+	assert.Nil(t, d.AddReplacement("B", "2"))
+	assert.Nil(t, d.AddReplacement("A", "1"))
+
+	r := New(&config.Scenario{}, d, &buf)
+	r.dumpVariables(&config.Request{Name: "req"}, d)
+
+	assert.Equal(t, "=== VARIABLES [req] ===\nA=1\nB=2\n\n", buf.String())
+}
@@ -0,0 +1,97 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/pwmorreale/rapid/config"
+	"github.com/pwmorreale/rapid/logger"
+	"github.com/pwmorreale/rapid/snapshot"
+)
+
+// Most distinct differences kept for the report, per response.
+const maxSnapshotDiffs = 20
+
+// Protects the differences recorded on responses.
+var snapshotMutex sync.Mutex
+
+// golden defines the snapshot of a response, loaded (or recorded) once.
+type golden struct {
+	once    sync.Once
+	path    string
+	content []byte
+	err     error
+}
+
+// loadSnapshot returns the snapshot, recording the content if there is no
+// snapshot yet or snapshots are being updated.
+func (r *Context) loadSnapshot(request *config.Request, response *config.Response, contentBytes []byte) *golden {
+
+	v, _ := r.snapshots.LoadOrStore(response, &golden{})
+	g := v.(*golden)
+
+	g.once.Do(func() {
+
+		g.path = snapshot.Path(r.sc.SnapshotDir, response.SnapshotName(request))
+
+		if !r.sc.UpdateSnapshots {
+			g.content, g.err = snapshot.Read(g.path)
+			if g.err != nil || g.content != nil {
+				return
+			}
+		}
+
+		g.content = slices.Clone(contentBytes)
+		g.err = snapshot.Write(g.path, g.content)
+		if g.err == nil {
+			logger.Info(request, response, "snapshot recorded: %s", g.path)
+		}
+	})
+
+	return g
+}
+
+// verifySnapshot compares the content with the response's snapshot.  Each
+// difference is an error, and is kept for the report.  Truncated content is
+// an error, and is never recorded.
+func (r *Context) verifySnapshot(request *config.Request, response *config.Response, contentBytes []byte, truncated bool) error {
+
+	if response.Snapshot == nil {
+		return nil
+	}
+
+	if truncated {
+		return fmt.Errorf("snapshot: content exceeds max_content (%d bytes)", len(contentBytes))
+	}
+
+	g := r.loadSnapshot(request, response, contentBytes)
+	if g.err != nil {
+		return fmt.Errorf("snapshot: %w", g.err)
+	}
+
+	diffs := snapshot.Diff(g.content, contentBytes, response.Snapshot.Ignore)
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	snapshotMutex.Lock()
+	for _, d := range diffs {
+		if len(response.SnapshotDiffs) < maxSnapshotDiffs && !slices.Contains(response.SnapshotDiffs, d) {
+			response.SnapshotDiffs = append(response.SnapshotDiffs, d)
+		}
+	}
+	snapshotMutex.Unlock()
+
+	all := make([]error, len(diffs))
+	for i, d := range diffs {
+		all[i] = fmt.Errorf("snapshot %s: %s", g.path, d)
+	}
+
+	return errors.Join(all...)
+}
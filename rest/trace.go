@@ -0,0 +1,150 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package rest
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/pwmorreale/rapid/stats"
+)
+
+type traceKey struct{}
+
+// trace records the phase timestamps of a request.  Only the final attempt
+// is kept when a request is retried.  Dial callbacks may run on another
+// goroutine, hence the lock.
+type trace struct {
+	mu sync.Mutex
+	ts traceTimes
+}
+
+type traceTimes struct {
+	sent         time.Time // The final attempt
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wrote        time.Time
+	firstByte    time.Time
+	bodyRead     time.Time
+}
+
+// withTrace returns a context that traces requests made with it.
+func withTrace(ctx context.Context) (context.Context, *trace) {
+
+	t := &trace{}
+
+	ct := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.reset()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.set(&t.ts.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.set(&t.ts.dnsDone)
+		},
+		ConnectStart: func(string, string) {
+			t.setFirst(&t.ts.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			t.set(&t.ts.connectDone)
+		},
+		TLSHandshakeStart: func() {
+			t.set(&t.ts.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.set(&t.ts.tlsDone)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.set(&t.ts.wrote)
+		},
+		GotFirstResponseByte: func() {
+			t.set(&t.ts.firstByte)
+		},
+	}
+
+	ctx = context.WithValue(ctx, traceKey{}, t)
+	return httptrace.WithClientTrace(ctx, ct), t
+}
+
+// traceFrom returns the trace for a context, or nil if not traced.
+func traceFrom(ctx context.Context) *trace {
+
+	t, _ := ctx.Value(traceKey{}).(*trace)
+	return t
+}
+
+// reset starts an attempt.
+func (t *trace) reset() {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.ts = traceTimes{sent: time.Now()}
+}
+
+func (t *trace) set(ts *time.Time) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	*ts = time.Now()
+}
+
+// setFirst keeps the earliest time, eg: dialing multiple addresses.
+func (t *trace) setFirst(ts *time.Time) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ts.IsZero() {
+		*ts = time.Now()
+	}
+}
+
+// done marks the response body as read.
+func (t *trace) done() {
+	t.set(&t.ts.bodyRead)
+}
+
+// elapsed returns the time from the final attempt's send until the body
+// was read.  Schedule lag, and earlier attempts, are not included.
+func (t *trace) elapsed() time.Duration {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return span(t.ts.sent, t.ts.bodyRead)
+}
+
+func span(start, end time.Time) time.Duration {
+
+	if start.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// timings returns the phase durations.
+func (t *trace) timings() *stats.Timings {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return &stats.Timings{
+		stats.PhaseDNS:      span(t.ts.dnsStart, t.ts.dnsDone),
+		stats.PhaseConnect:  span(t.ts.connectStart, t.ts.connectDone),
+		stats.PhaseTLS:      span(t.ts.tlsStart, t.ts.tlsDone),
+		stats.PhaseTTFB:     span(t.ts.wrote, t.ts.firstByte),
+		stats.PhaseTransfer: span(t.ts.firstByte, t.ts.bodyRead),
+	}
+}
@@ -7,15 +7,24 @@ package rest
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gabriel-vasile/mimetype"
+	"github.com/pwmorreale/rapid/assertion"
 	"github.com/pwmorreale/rapid/config"
+	"github.com/pwmorreale/rapid/data"
+	"github.com/pwmorreale/rapid/openapi"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
 )
 
 // Used for adding unknown response structs to a request.
@@ -23,6 +32,9 @@ import (
 // While less than perfect, the only contention is ffrom the same request. (eg: thundering herd resolutions)
 var unknownResponseMutex sync.Mutex
 
+// Used to render schema violation messages.
+var schemaPrinter = message.NewPrinter(language.English)
+
 func cookieExists(expected string, all []string) bool {
 
 	for i := range all {
@@ -34,14 +46,25 @@ func cookieExists(expected string, all []string) bool {
 	return false
 }
 
-func readBody(httpResponse *http.Response, maxSize int64) ([]byte, error) {
+// readBody reads up to maxSize bytes of content.  If probe is set, a byte
+// more is read to detect truncation, and true is returned if the content
+// was truncated.
+func readBody(httpResponse *http.Response, maxSize int64, probe bool) ([]byte, bool, error) {
 
 	if maxSize == 0 {
 		maxSize = int64(config.DefaultContentLimit)
 	}
 
-	r := io.LimitReader(httpResponse.Body, maxSize)
-	return io.ReadAll(r)
+	if !probe {
+		b, err := io.ReadAll(io.LimitReader(httpResponse.Body, maxSize))
+		return b, false, err
+	}
+
+	b, err := io.ReadAll(io.LimitReader(httpResponse.Body, maxSize+1))
+	if int64(len(b)) > maxSize {
+		return b[:maxSize], true, err
+	}
+	return b, false, err
 }
 
 func verifyHeaderValues(httpHeaders http.Header, expectedHeader *config.HeaderData) error {
@@ -143,7 +166,7 @@ func (r *Context) verifyExpectedContentType(contentBytes []byte, httpResponse *h
 	return nil
 }
 
-func (r *Context) extractContent(contentBytes []byte, response *config.Response) error {
+func (r *Context) extractContent(datum data.Data, contentBytes []byte, httpResponse *http.Response, response *config.Response) error {
 
 	var v string
 	var err error
@@ -151,24 +174,35 @@ func (r *Context) extractContent(contentBytes []byte, response *config.Response)
 	for i := range response.Content.Extract {
 		e := &response.Content.Extract[i]
 
+		if e.Unset {
+			datum.Unset(e.Name)
+			continue
+		}
+
 		rb := bytes.NewReader(contentBytes)
 
 		switch e.Type {
 		case "json":
-			v, err = r.datum.ExtractJSON(e.Path, rb)
+			v, err = datum.ExtractJSON(e.Path, rb)
 		case "xml":
-			v, err = r.datum.ExtractXML(e.Path, rb)
+			v, err = datum.ExtractXML(e.Path, rb)
 		case "text":
-			v, err = r.datum.ExtractRegex(e.Path, rb)
+			v, err = datum.ExtractRegex(e.Path, rb, data.RegexMatch{Group: e.Group, Index: e.Index, Separator: e.Separator})
+		case "header":
+			v, err = extractHeader(e.Path, httpResponse)
+		case "cookie":
+			v, err = extractCookie(e.Path, httpResponse)
+		case "status":
+			v = strconv.Itoa(httpResponse.StatusCode)
 		default:
-			return fmt.Errorf("unknown extract type: %q (must be json, xml, or text)", e.Type)
+			return fmt.Errorf("unknown extract type: %q (must be json, xml, text, header, cookie, or status)", e.Type)
 		}
 
 		if err != nil {
 			return err
 		}
 
-		err = r.datum.AddReplacement(e.Name, v)
+		err = datum.AddReplacement(e.Name, v)
 		if err != nil {
 			return err
 		}
@@ -177,6 +211,29 @@ func (r *Context) extractContent(contentBytes []byte, response *config.Response)
 	return nil
 }
 
+// extractHeader returns the value of a response header.
+func extractHeader(name string, httpResponse *http.Response) (string, error) {
+
+	values := httpResponse.Header.Values(name)
+	if len(values) == 0 {
+		return "", fmt.Errorf("extract header not found: %s", name)
+	}
+
+	return values[0], nil
+}
+
+// extractCookie returns the value of a cookie set by the response.
+func extractCookie(name string, httpResponse *http.Response) (string, error) {
+
+	for _, c := range httpResponse.Cookies() {
+		if c.Name == name {
+			return c.Value, nil
+		}
+	}
+
+	return "", fmt.Errorf("extract cookie not found: %s", name)
+}
+
 func (r *Context) verifyContains(contentBytes []byte, response *config.Response) error {
 
 	for _, re := range response.Content.ContainsCompiled {
@@ -188,6 +245,58 @@ func (r *Context) verifyContains(contentBytes []byte, response *config.Response)
 	return nil
 }
 
+// schemaPointer converts a schema instance location into a JSON pointer.
+// The whole document, an empty pointer, is labeled (root).
+func schemaPointer(location []string) string {
+
+	if len(location) == 0 {
+		return "(root)"
+	}
+
+	var sb strings.Builder
+	for _, token := range location {
+		token = strings.ReplaceAll(token, "~", "~0")
+		token = strings.ReplaceAll(token, "/", "~1")
+		sb.WriteString("/" + token)
+	}
+	return sb.String()
+}
+
+// schemaViolations flattens a validation error into one error per violation.
+func schemaViolations(ve *jsonschema.ValidationError) []error {
+
+	if len(ve.Causes) == 0 {
+		return []error{fmt.Errorf("schema: %s: %s", schemaPointer(ve.InstanceLocation), ve.ErrorKind.LocalizedString(schemaPrinter))}
+	}
+
+	var all []error
+	for _, cause := range ve.Causes {
+		all = append(all, schemaViolations(cause)...)
+	}
+	return all
+}
+
+func (r *Context) verifySchema(contentBytes []byte, response *config.Response) error {
+
+	if response.Content.SchemaCompiled == nil {
+		return nil
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(contentBytes))
+	if err != nil {
+		return fmt.Errorf("schema: content is not valid JSON: %v", err)
+	}
+
+	err = response.Content.SchemaCompiled.Validate(doc)
+
+	var ve *jsonschema.ValidationError
+	if errors.As(err, &ve) {
+		return errors.Join(schemaViolations(ve)...)
+	}
+
+	return err
+}
+
 func (r *Context) verifyContentLength(httpLength int64, contentLength int64) error {
 
 	// httpLength is -1 when the server doesn't send Content-Length (chunked, etc.)
@@ -228,7 +337,15 @@ func (r *Context) verifyContent(contentBytes []byte, httpResponse *http.Response
 		return err
 	}
 
-	return r.verifyContains(contentBytes, response)
+	err = r.verifyContains(contentBytes, response)
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(
+		r.verifySchema(contentBytes, response),
+		assertion.EvaluateJSON(response.Content.AssertCompiled, contentBytes),
+		assertion.EvaluateXML(response.Content.XPathCompiled, contentBytes))
 }
 
 func lookupResponses(statusCode int, r []*config.Response) []*config.Response {
@@ -243,27 +360,79 @@ func lookupResponses(statusCode int, r []*config.Response) []*config.Response {
 	return matches
 }
 
-func (r *Context) findOrCreateUnknown(httpResponse *http.Response, request *config.Request) *config.Response {
+func findOrCreate(statusCode int, name string, all *[]*config.Response) *config.Response {
 
 	unknownResponseMutex.Lock()
 	defer unknownResponseMutex.Unlock()
 
-	matches := lookupResponses(httpResponse.StatusCode, request.UnknownResponses)
+	matches := lookupResponses(statusCode, *all)
 	if len(matches) > 0 {
 		return matches[0]
 	}
 
 	resp := new(config.Response)
-	request.UnknownResponses = append(request.UnknownResponses, resp)
-	resp.Name = config.DefaultResponseName
-	resp.StatusCode = httpResponse.StatusCode
+	*all = append(*all, resp)
+	resp.Name = name
+	resp.StatusCode = statusCode
 
 	return resp
 }
 
+func (r *Context) findOrCreateUnknown(httpResponse *http.Response, request *config.Request) *config.Response {
+	return findOrCreate(httpResponse.StatusCode, config.DefaultResponseName, &request.UnknownResponses)
+}
+
+func (r *Context) findOrCreateUndocumented(httpResponse *http.Response, request *config.Request) *config.Response {
+	return findOrCreate(httpResponse.StatusCode, config.UndocumentedResponseName, &request.UndocumentedResponses)
+}
+
+// verifyConformance validates the request and response against the OpenAPI spec, if any.
+func (r *Context) verifyConformance(req *http.Request, contentBytes []byte, httpResponse *http.Response) error {
+
+	spec := r.sc.OpenAPI.Spec
+	if spec == nil || req == nil {
+		return nil
+	}
+
+	return spec.Validate(req.Context(), req, httpResponse, contentBytes)
+}
+
+// responseProtocol returns the protocol the response was received on.
+func responseProtocol(httpResponse *http.Response) string {
+
+	if httpResponse.ProtoMajor != 2 {
+		return config.ProtocolHTTP1
+	}
+
+	if httpResponse.TLS == nil {
+		return config.ProtocolH2C
+	}
+
+	return config.ProtocolH2
+}
+
+func (r *Context) verifyProtocol(httpResponse *http.Response, response *config.Response) error {
+
+	if response.Protocol == "" {
+		return nil
+	}
+
+	actual := responseProtocol(httpResponse)
+	if actual != response.Protocol {
+		return fmt.Errorf("protocol: %s (%s) != %s", actual, httpResponse.Proto, response.Protocol)
+	}
+
+	return nil
+}
+
 func (r *Context) verifyResponse(contentBytes []byte, httpResponse *http.Response, response *config.Response) error {
 
-	err := r.verifyHeaders(httpResponse, response)
+	err := r.verifyProtocol(httpResponse, response)
+	if err != nil {
+		return err
+	}
+
+	err = r.verifyHeaders(httpResponse, response)
 	if err != nil {
 		return err
 	}
@@ -276,27 +445,84 @@ func (r *Context) verifyResponse(contentBytes []byte, httpResponse *http.Respons
 	return r.verifyContent(contentBytes, httpResponse, response)
 }
 
-func (r *Context) validateResponse(httpResponse *http.Response, request *config.Request) (*config.Response, error) {
+// ErrDuration is returned for a response received outside its expected
+// duration.
+var ErrDuration = errors.New("response time")
 
-	// Determine max content size from configured responses.
+// verifyDuration checks the time to receive the response.  Elapsed is zero
+// when the request was not traced, eg: in unit tests.
+func verifyDuration(elapsed time.Duration, response *config.Response) error {
+
+	if elapsed == 0 {
+		return nil
+	}
+
+	if response.MaxDuration > 0 && elapsed > response.MaxDuration {
+		return fmt.Errorf("%w: %s exceeds max_duration %s", ErrDuration, elapsed, response.MaxDuration)
+	}
+
+	if elapsed < response.MinDuration {
+		return fmt.Errorf("%w: %s is less than min_duration %s", ErrDuration, elapsed, response.MinDuration)
+	}
+
+	return nil
+}
+
+// only returns true if every error is target, eg: ErrDuration.
+func only(err error, target error) bool {
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if !only(e, target) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return errors.Is(err, target)
+}
+
+func (r *Context) validateResponse(req *http.Request, httpResponse *http.Response, datum data.Data, request *config.Request) (*config.Response, error) {
+
+	// Determine max content size from configured responses.  Snapshots
+	// require the entire content.
 	var maxSize int64
+	snapshots := false
 	for _, resp := range request.Responses {
 		if int64(resp.Content.MaxSize) > maxSize {
 			maxSize = int64(resp.Content.MaxSize)
 		}
+		if resp.Snapshot != nil {
+			snapshots = true
+		}
 	}
 
-	contentBytes, err := readBody(httpResponse, maxSize)
+	contentBytes, truncated, err := readBody(httpResponse, maxSize, snapshots)
 	if err != nil {
 		return nil, err
 	}
 
+	var elapsed time.Duration
+	if req != nil {
+		if t := traceFrom(req.Context()); t != nil {
+			t.done()
+			elapsed = t.elapsed()
+		}
+	}
+
+	// Status codes missing from the spec are tracked separately.
+	conformErr := r.verifyConformance(req, contentBytes, httpResponse)
+	if errors.Is(conformErr, openapi.ErrUndocumentedStatus) {
+		return r.findOrCreateUndocumented(httpResponse, request), conformErr
+	}
+
 	matches := lookupResponses(httpResponse.StatusCode, request.Responses)
 
 	// No configured response for this status code.
 	if len(matches) == 0 {
 		resp := r.findOrCreateUnknown(httpResponse, request)
-		return resp, r.verifyResponse(contentBytes, httpResponse, resp)
+		return resp, errors.Join(r.verifyResponse(contentBytes, httpResponse, resp), conformErr)
 	}
 
 	// Try each matching response; succeed on the first that passes.
@@ -304,10 +530,23 @@ func (r *Context) validateResponse(httpResponse *http.Response, request *config.
 	for _, resp := range matches {
 		err := r.verifyResponse(contentBytes, httpResponse, resp)
 		if err == nil {
-			return resp, r.extractContent(contentBytes, resp)
+			// Data is not extracted from a response to a pending poll, nor
+			// from a response that differs from its snapshot.
+			if request.Poll != nil {
+				err = verifyPoll(contentBytes, httpResponse, &request.Poll.Until)
+				if err != nil {
+					return resp, errors.Join(conformErr, err)
+				}
+			}
+
+			err = r.verifySnapshot(request, resp, contentBytes, truncated)
+			if err != nil {
+				return resp, errors.Join(err, conformErr)
+			}
+			return resp, errors.Join(conformErr, r.extractContent(datum, contentBytes, httpResponse, resp), verifyDuration(elapsed, resp))
 		}
 		lastErr = err
 	}
 
-	return matches[0], lastErr
+	return matches[0], errors.Join(lastErr, conformErr)
 }
@@ -7,12 +7,15 @@ package sequence
 
 import (
 	"context"
+	"errors"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gammazero/workerpool"
 	"github.com/pwmorreale/rapid/config"
+	"github.com/pwmorreale/rapid/data"
 	"github.com/pwmorreale/rapid/logger"
 	"github.com/pwmorreale/rapid/rest"
 )
@@ -24,9 +27,18 @@ type Sequence interface {
 	Run(context.Context, *config.Scenario) error
 }
 
+// ErrSetup is returned by Run when a setup request had an error.
+var ErrSetup = errors.New("setup failed")
+
+// ErrTeardown is returned by Run when a teardown request had an error.
+var ErrTeardown = errors.New("teardown failed")
+
 // Context defines a sequence
 type Context struct {
 	rest rest.Rest
+
+	// Guards once only requests, which concurrent users share.
+	onceMu sync.Mutex
 }
 
 // New creates a new context instance
@@ -36,23 +48,130 @@ func New(r rest.Rest) *Context {
 	}
 }
 
-// Run executes the sequence.
-func (s *Context) Run(ctx context.Context, sc *config.Scenario) error {
+// Run executes the setup requests, the sequences, and the teardown
+// requests.  Teardown executes even if setup fails, or ctx is canceled.
+// The sequences are not executed if setup fails.  A failed setup or
+// teardown returns ErrSetup or ErrTeardown.
+func (s *Context) Run(ctx context.Context, sc *config.Scenario) (err error) {
+
+	defer func() {
+		if s.RunPhase(context.WithoutCancel(ctx), &sc.TeardownSequence) {
+			logger.Error(nil, nil, "teardown failed")
+			err = errors.Join(err, ErrTeardown)
+		}
+	}()
+
+	if s.RunPhase(ctx, &sc.SetupSequence) {
+		logger.Error(nil, nil, "setup failed, sequences not executed")
+		return errors.Join(ErrSetup, ctx.Err())
+	}
+
+	return s.RunSequences(ctx, sc)
+}
+
+// RunPhase executes the requests of the setup or teardown phase, once, in
+// the scenario's data scope.  Returns true if any request had an error, or
+// ctx was canceled.
+func (s *Context) RunPhase(ctx context.Context, phase *config.Sequence) bool {
+
+	if len(phase.Requests) == 0 {
+		return false
+	}
+
+	logger.Info(nil, nil, "%s started", phase.Name)
+
+	start := time.Now()
+
+	hadError := s.ExecuteSequence(ctx, 0, phase) || ctx.Err() != nil
+	if hadError {
+		phase.Stats.Error(start)
+	} else {
+		phase.Stats.Success(start)
+	}
+
+	logger.Info(nil, nil, "%s complete: %s", phase.Name, phase.Stats.String())
+
+	return hadError
+}
+
+// RunSequences executes the sequences.  Each sequence is executed by its
+// users concurrently, and each user executes the sequence's iterations in
+// turn.  When more than one user executes, each has its own data scope.
+func (s *Context) RunSequences(ctx context.Context, sc *config.Scenario) error {
+
+	sequences := sc.AllSequences()
+	users := sc.SequenceUsers()
+
+	total := 0
+	for _, n := range users {
+		total += n
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var runErr error
+
+	// Released once every sequence ends...
+	var cancels []context.CancelFunc
 
-	if sc.Sequence.Iterations == 0 {
-		logger.Warn(nil, nil, "iterations is 0, nothing to execute")
-		return nil
+	user := 0
+	for i, seq := range sequences {
+
+		if seq.Iterations == 0 {
+			logger.Warn(nil, nil, "%siterations is 0, nothing to execute", seq.Prefix())
+			continue
+		}
+
+		if users[i] == 0 {
+			logger.Warn(nil, nil, "%sno users, nothing to execute", seq.Prefix())
+			continue
+		}
+
+		// Aborting on error ends every user of the sequence...
+		seqCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+
+		for range users[i] {
+			userCtx := seqCtx
+			if total > 1 {
+				userCtx = rest.WithUser(seqCtx, user)
+			}
+			user++
+
+			wg.Go(func() {
+				err := s.RunSequence(userCtx, seq, cancel)
+				if err != nil && ctx.Err() != nil {
+					mu.Lock()
+					runErr = err
+					mu.Unlock()
+				}
+			})
+		}
 	}
 
-	for i := 0; i < sc.Sequence.Iterations; i++ {
+	wg.Wait()
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+
+	return runErr
+}
+
+// RunSequence executes the iterations of a sequence, as one user.  Abort
+// is called if the sequence aborts on an error.
+func (s *Context) RunSequence(ctx context.Context, seq *config.Sequence, abort context.CancelFunc) error {
+
+	for i := 0; i < seq.Iterations; i++ {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
-		hadError := s.ExecuteIteration(ctx, sc, i)
-		if hadError && sc.Sequence.AbortOnError {
-			logger.Warn(nil, nil, "aborting on error at iteration %d", i)
+		hadError := s.ExecuteIteration(ctx, seq, i)
+		if hadError && seq.AbortOnError {
+			logger.Warn(nil, nil, "%saborting on error at iteration %d", seq.Prefix(), i)
+			abort()
 			return nil
 		}
 	}
@@ -61,46 +180,64 @@ func (s *Context) Run(ctx context.Context, sc *config.Scenario) error {
 }
 
 // ExecuteIteration executes a single iteration. Returns true if any request had an error.
-func (s *Context) ExecuteIteration(parent context.Context, sc *config.Scenario, iteration int) bool {
+func (s *Context) ExecuteIteration(parent context.Context, seq *config.Sequence, iteration int) bool {
 
 	var ctx context.Context
 	var cancel context.CancelFunc
 
-	if sc.Sequence.Limit > 0 {
-		ctx, cancel = context.WithTimeout(parent, sc.Sequence.Limit)
+	if seq.Limit > 0 {
+		ctx, cancel = context.WithTimeout(parent, seq.Limit)
 	} else {
 		ctx, cancel = context.WithCancel(parent)
 	}
 	defer cancel()
 
+	s.rest.StartIteration(parent, seq, iteration)
+
 	start := time.Now()
 
-	hadError := s.ExecuteSequence(ctx, iteration, sc)
+	hadError := s.ExecuteSequence(ctx, iteration, seq)
 
 	select {
 	case <-ctx.Done():
-		sc.Sequence.Stats.Error(start)
-		logger.Error(nil, nil, "sequence %v on iteration: %d", ctx.Err(), iteration)
+		seq.Stats.Error(start)
+		logger.Error(nil, nil, "%ssequence %v on iteration: %d", seq.Prefix(), ctx.Err(), iteration)
 		return true
 	default:
 	}
 
 	if hadError {
-		sc.Sequence.Stats.Error(start)
+		seq.Stats.Error(start)
 	} else {
-		sc.Sequence.Stats.Success(start)
+		seq.Stats.Success(start)
 	}
 
 	return hadError
 }
 
 // ExecuteSequence runs the sequence of requests. Returns true if any request had an error.
-func (s *Context) ExecuteSequence(ctx context.Context, iteration int, sc *config.Scenario) bool {
+// Requests are executed in order, unless skipped by a condition, or a goto or
+// on_failure continues with another request.
+func (s *Context) ExecuteSequence(ctx context.Context, iteration int, seq *config.Sequence) bool {
 
 	hadError := false
 
+	requests := seq.Requests
+	indexes := make(map[string]int, len(requests))
+	for i := range requests {
+		indexes[requests[i].Name] = i
+	}
+
+	maxJumps := seq.MaxJumps
+	if maxJumps == 0 {
+		maxJumps = config.DefaultMaxJumps
+	}
+
+	previous := ""
+	jumps := 0
+
 Loop:
-	for i := range sc.Sequence.Requests {
+	for i := 0; i < len(requests); {
 
 		// Did we timeout?
 		select {
@@ -109,51 +246,219 @@ Loop:
 		default:
 		}
 
-		request := &sc.Sequence.Requests[i]
+		request := &requests[i]
+
+		if s.skipped(ctx, seq, request, previous) {
+			i++
+			continue
+		}
+
+		// The jumps of a once only request are only taken once...
+		once := request.OnceOnly && s.executed(request)
 
 		logger.Info(request, nil, "execution started")
-		requestHadError := s.ExecuteRequest(ctx, iteration, request, sc.Sequence.IgnoreDups)
+		requestHadError := s.ExecuteRequest(ctx, iteration, request, seq.IgnoreDups)
 		logger.Info(request, nil, "execution complete")
 
+		if once {
+			i++
+			continue
+		}
+
+		previous = request.Name
+
+		target := request.Goto
 		if requestHadError {
 			hadError = true
-			if sc.Sequence.AbortOnError {
+			switch {
+			case request.OnFailure != "":
+				target = request.OnFailure
+			case seq.AbortOnError:
 				break Loop
 			}
 		}
+
+		if target == "" {
+			i++
+			continue
+		}
+
+		jumps++
+		if jumps > maxJumps {
+			logger.Error(request, nil, "max_jumps (%d) exceeded, ending iteration %d", maxJumps, iteration)
+			hadError = true
+			break
+		}
+
+		next, ok := indexes[target]
+		if !ok {
+			logger.Error(request, nil, "unknown request: %s", target)
+			hadError = true
+			break
+		}
+
+		logger.Info(request, nil, "continuing with request %s", target)
+		i = next
 	}
 
 	return hadError
 }
 
+// executed returns true if a once only request has executed.
+func (s *Context) executed(request *config.Request) bool {
+
+	s.onceMu.Lock()
+	defer s.onceMu.Unlock()
+	return request.Executed
+}
+
+// skipped returns true if the request's conditions are not met.
+func (s *Context) skipped(ctx context.Context, seq *config.Sequence, request *config.Request, previous string) bool {
+
+	if request.WhenCompiled != nil && !s.rest.Evaluate(ctx, seq, request.WhenCompiled, previous) {
+		logger.Info(request, nil, "skipped, when: %s", request.WhenCompiled)
+		return true
+	}
+
+	if request.SkipIfCompiled != nil && s.rest.Evaluate(ctx, seq, request.SkipIfCompiled, previous) {
+		logger.Info(request, nil, "skipped, skip_if: %s", request.SkipIfCompiled)
+		return true
+	}
+
+	return false
+}
+
 // ExecuteRequest executes a request. Returns true if any execution had an error.
 func (s *Context) ExecuteRequest(ctx context.Context, iteration int, request *config.Request, ignoreDups bool) bool {
 
 	// Is this a once only request?
 	if request.OnceOnly {
-		if request.Executed {
+		s.onceMu.Lock()
+		executed := request.Executed
+		request.Executed = true
+		s.onceMu.Unlock()
+
+		if executed {
 			logger.Info(request, nil, "once_only request already executed, ignoring")
 			return false
 		}
-		request.Executed = true
+	}
+
+	var seenErrors *sync.Map
+	if ignoreDups {
+		seenErrors = &sync.Map{}
+	}
+
+	if request.ForEach != nil {
+		return s.executeForEach(ctx, iteration, request, seenErrors)
+	}
+
+	if len(request.ThunderingHerd.Stages) > 0 {
+		return s.executeStages(ctx, iteration, request, seenErrors)
+	}
+
+	if request.ThunderingHerd.Rate > 0 {
+		return s.executeRate(ctx, iteration, request, &request.ThunderingHerd, nil, seenErrors)
+	}
+
+	return s.executeHerd(ctx, iteration, request, &request.ThunderingHerd, nil, seenErrors)
+}
+
+// executeStages executes each stage of the herd in turn.  The worker pool
+// is sized for each stage.
+func (s *Context) executeStages(ctx context.Context, iteration int, request *config.Request, seenErrors *sync.Map) bool {
+
+	herd := &request.ThunderingHerd
+	hadError := false
+
+	for i := range herd.Stages {
+
+		select {
+		case <-ctx.Done():
+			return hadError
+		default:
+		}
+
+		stage := &herd.Stages[i]
+
+		// Stages inherit the herd's settings...
+		h := config.Stampede{
+			Size:           stage.Size,
+			TimeLimit:      stage.Duration,
+			Delay:          herd.Delay,
+			Rate:           stage.Rate,
+			MaxOutstanding: herd.MaxOutstanding,
+		}
+		if h.Size == 0 {
+			h.Size = herd.Size
+		}
+
+		logger.Info(request, nil, "stage %s started: concurrent_requests=%d rate=%v duration=%s", stage.Name, h.Size, h.Rate, h.TimeLimit)
+
+		var stageHadError bool
+		if h.Rate > 0 {
+			stageHadError = s.executeRate(ctx, iteration, request, &h, stage, seenErrors)
+		} else {
+			stageHadError = s.executeHerd(ctx, iteration, request, &h, stage, seenErrors)
+		}
+
+		logger.Info(request, nil, "stage %s complete: %s", stage.Name, stage.Stats.String())
+
+		if stageHadError {
+			hadError = true
+		}
+	}
 
+	return hadError
+}
+
+// Default to one if not specified...
+func poolSize(herd *config.Stampede) int {
+
+	if herd.Size == 0 {
+		return 1
 	}
+	return herd.Size
+}
+
+// newSlots returns n slots.  Each in-flight execution holds a slot, which
+// identifies its virtual user.
+func newSlots(n int) chan int {
 
-	// Default to one if not specified...
-	workerPoolSize := request.ThunderingHerd.Size
-	if workerPoolSize == 0 {
-		workerPoolSize++
+	slots := make(chan int, n)
+	for vu := range n {
+		slots <- vu
 	}
+
+	return slots
+}
+
+// virtualUser returns the virtual user for a slot.  Herds of a single
+// request at a time use the scenario's data scope.
+func virtualUser(slots chan int, vu int) int {
+
+	if cap(slots) == 1 {
+		return data.Shared
+	}
+	return vu
+}
+
+// exhausted returns true once the request's data source has run out of
+// rows and on_exhaust is stop.
+func exhausted(request *config.Request) bool {
+	return request.Feeder != nil && request.Feeder.Exhausted()
+}
+
+// executeHerd executes requests as workers become free (closed-loop).
+func (s *Context) executeHerd(ctx context.Context, iteration int, request *config.Request, herd *config.Stampede, stage *config.Stage, seenErrors *sync.Map) bool {
+
+	workerPoolSize := poolSize(herd)
 	wp := workerpool.New(workerPoolSize)
 
 	var hadError atomic.Bool
-	var seenErrors *sync.Map
-	if ignoreDups {
-		seenErrors = &sync.Map{}
-	}
 
 	// Limit in-flight work (queued + running) to the pool size.
-	sem := make(chan struct{}, workerPoolSize)
+	slots := newSlots(workerPoolSize)
 
 	start := time.Now()
 
@@ -162,37 +467,201 @@ func (s *Context) ExecuteRequest(ctx context.Context, iteration int, request *co
 Loop:
 	for {
 
-		sem <- struct{}{}
+		vu := <-slots
+
+		if exhausted(request) {
+			slots <- vu
+			logger.Info(request, nil, "data source %s exhausted", request.Feeder.Name())
+			break
+		}
+
 		wp.Submit(func() {
-			defer func() { <-sem }()
-			errored := s.rest.Execute(ctx, iteration, request, seenErrors)
+			defer func() { slots <- vu }()
+			errored := s.rest.Execute(ctx, iteration, virtualUser(slots, vu), request, rest.Options{Stage: stage, SeenErrors: seenErrors})
 			if errored {
 				hadError.Store(true)
 			}
 		})
 
 		// Inter-request delay
-		time.Sleep(request.ThunderingHerd.Delay)
+		time.Sleep(herd.Delay)
 
 		i++
 
-		if request.ThunderingHerd.TimeLimit > 0 {
-			if time.Since(start) >= request.ThunderingHerd.TimeLimit {
+		if herd.TimeLimit > 0 {
+			if time.Since(start) >= herd.TimeLimit {
+				break
+			}
+		} else if i >= herd.Max {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			break Loop
+		default:
+		}
+	}
+
+	// Wait for everybody to complete.
+	wp.StopWait()
+
+	return hadError.Load()
+}
+
+// executeForEach executes the request once for each element, as workers
+// become free.  The herd's size and delay apply, its limits do not.
+func (s *Context) executeForEach(ctx context.Context, iteration int, request *config.Request, seenErrors *sync.Map) bool {
+
+	elements, err := s.rest.Elements(ctx, request)
+	if err != nil {
+		logger.Error(request, nil, "%v", err)
+		request.Stats.Error(time.Now())
+		return true
+	}
+
+	logger.Info(request, nil, "foreach: %d elements", len(elements))
+
+	herd := &request.ThunderingHerd
+
+	workerPoolSize := poolSize(herd)
+	wp := workerpool.New(workerPoolSize)
+
+	var hadError atomic.Bool
+	slots := newSlots(workerPoolSize)
+
+Loop:
+	for _, element := range elements {
+
+		select {
+		case <-ctx.Done():
+			break Loop
+		default:
+		}
+
+		if exhausted(request) {
+			logger.Info(request, nil, "data source %s exhausted", request.Feeder.Name())
+			break
+		}
+
+		vu := <-slots
+
+		wp.Submit(func() {
+			defer func() { slots <- vu }()
+			errored := s.rest.Execute(rest.WithElement(ctx, element), iteration, virtualUser(slots, vu), request, rest.Options{SeenErrors: seenErrors})
+			if errored {
+				hadError.Store(true)
+			}
+		})
+
+		// Inter-request delay
+		time.Sleep(herd.Delay)
+	}
+
+	// Wait for everybody to complete.
+	wp.StopWait()
+
+	return hadError.Load()
+}
+
+// scheduleOffset returns the intended send time of the nth request (from
+// zero), relative to the start of a request.  The rate increases linearly
+// from zero to rate over the ramp, then remains constant.
+func scheduleOffset(n int, rate float64, ramp time.Duration) time.Duration {
+
+	// Requests sent during the ramp...
+	rampSends := rate * ramp.Seconds() / 2
+
+	var secs float64
+	if float64(n) < rampSends {
+		secs = math.Sqrt(2 * ramp.Seconds() * float64(n) / rate)
+	} else {
+		secs = ramp.Seconds() + (float64(n)-rampSends)/rate
+	}
+
+	return time.Duration(secs * float64(time.Second))
+}
+
+// executeRate sends requests at a constant arrival rate, independent of
+// completions.  Durations are measured from the scheduled send time, so
+// a slow server shows as increased latency rather than reduced load.
+// Sends that would exceed max_outstanding are dropped and counted.
+func (s *Context) executeRate(ctx context.Context, iteration int, request *config.Request, herd *config.Stampede, stage *config.Stage, seenErrors *sync.Map) bool {
+
+	workerPoolSize := poolSize(herd)
+
+	maxOutstanding := herd.MaxOutstanding
+	if maxOutstanding == 0 {
+		maxOutstanding = workerPoolSize
+	}
+
+	wp := workerpool.New(workerPoolSize)
+
+	var hadError atomic.Bool
+	slots := newSlots(maxOutstanding)
+	dropped := 0
+
+	start := time.Now()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+Loop:
+	for n := 0; ; n++ {
+
+		offset := scheduleOffset(n, herd.Rate, herd.Ramp)
+
+		if herd.TimeLimit > 0 {
+			if offset >= herd.TimeLimit {
 				break
 			}
-		} else if i >= request.ThunderingHerd.Max {
+		} else if n >= herd.Max {
 			break
 		}
 
+		if exhausted(request) {
+			logger.Info(request, nil, "data source %s exhausted", request.Feeder.Name())
+			break
+		}
+
+		scheduled := start.Add(offset)
+
+		// Wait for the scheduled send time, if it hasn't passed.
+		timer.Reset(time.Until(scheduled))
 		select {
 		case <-ctx.Done():
 			break Loop
+		case <-timer.C:
+		}
+
+		var vu int
+		select {
+		case vu = <-slots:
 		default:
+			request.Stats.Drop()
+			if stage != nil {
+				stage.Stats.Drop()
+			}
+			dropped++
+			logger.Debug(request, nil, "dropped send %d, %d requests outstanding", n, maxOutstanding)
+			continue
 		}
+
+		wp.Submit(func() {
+			defer func() { slots <- vu }()
+			errored := s.rest.Execute(ctx, iteration, virtualUser(slots, vu), request, rest.Options{Stage: stage, Scheduled: scheduled, SeenErrors: seenErrors})
+			if errored {
+				hadError.Store(true)
+			}
+		})
 	}
 
 	// Wait for everybody to complete.
 	wp.StopWait()
 
+	if dropped > 0 {
+		logger.Warn(request, nil, "%d sends dropped, max_outstanding (%d) reached", dropped, maxOutstanding)
+	}
+
 	return hadError.Load()
 }
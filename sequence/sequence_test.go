@@ -7,13 +7,17 @@ package sequence_test
 
 import (
 	"context"
+	"errors"
 	"io"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/pwmorreale/rapid/condition"
 	"github.com/pwmorreale/rapid/config"
+	"github.com/pwmorreale/rapid/data"
 	"github.com/pwmorreale/rapid/logger"
+	"github.com/pwmorreale/rapid/rest"
 	"github.com/pwmorreale/rapid/sequence"
 	"github.com/pwmorreale/rapid/testdata/mocks"
 	"github.com/stretchr/testify/assert"
@@ -21,7 +25,7 @@ import (
 
 var RequestDuration = time.Millisecond * 100
 
-func fakeExecuteStub(_ context.Context, _ int, _ *config.Request, _ *sync.Map) bool {
+func fakeExecuteStub(_ context.Context, _ int, _ int, _ *config.Request, _ rest.Options) bool {
 	time.Sleep(RequestDuration)
 	return false
 }
@@ -206,6 +210,170 @@ func TestExecuteRequestDefaults(t *testing.T) {
 
 }
 
+func TestExecuteRequestDataSourceExhausted(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	f, err := data.NewFeeder("painters", "../testdata/sources/painters.csv", "", data.OrderSequential, data.ExhaustStop)
+	assert.Nil(t, err)
+
+	r := &mocks.FakeRest{}
+	r.ExecuteStub = func(_ context.Context, _ int, _ int, _ *config.Request, _ rest.Options) bool {
+		_, _ = f.Next(nil)
+		return false
+	}
+
+	s := sequence.New(r)
+
+	request := config.Request{Feeder: f}
+	request.ThunderingHerd.Max = 10
+
+	s.ExecuteRequest(context.Background(), 0, &request, false)
+
+	// Three rows, then the fourth execution finds the source exhausted...
+	assert.Equal(t, 4, r.ExecuteCallCount())
+	assert.True(t, f.Exhausted())
+
+	s.ExecuteRequest(context.Background(), 1, &request, false)
+	assert.Equal(t, 4, r.ExecuteCallCount())
+}
+
+func TestExecuteRequestRate(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var mu sync.Mutex
+	var scheduled []time.Time
+
+	r := &mocks.FakeRest{}
+	r.ExecuteStub = func(_ context.Context, _ int, _ int, _ *config.Request, opts rest.Options) bool {
+		mu.Lock()
+		scheduled = append(scheduled, opts.Scheduled)
+		mu.Unlock()
+		return false
+	}
+
+	s := sequence.New(r)
+
+	request := config.Request{}
+	request.ThunderingHerd.Max = 10
+	request.ThunderingHerd.Size = 2
+	request.ThunderingHerd.Rate = 100
+
+	start := time.Now()
+	hadError := s.ExecuteRequest(context.Background(), 1, &request, false)
+	elapsed := time.Since(start)
+
+	assert.False(t, hadError)
+	assert.Equal(t, 10, r.ExecuteCallCount())
+	assert.Equal(t, int64(0), request.Stats.GetDropped())
+
+	// 10 requests at 100/s are scheduled over 90ms...
+	assert.GreaterOrEqual(t, elapsed, 90*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range scheduled {
+		assert.False(t, scheduled[i].IsZero())
+	}
+}
+
+func TestExecuteRequestRateDropped(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	r := &mocks.FakeRest{}
+	r.ExecuteStub = fakeExecuteStub
+
+	s := sequence.New(r)
+
+	// Far more than one worker can complete...
+	request := config.Request{}
+	request.ThunderingHerd.Max = 20
+	request.ThunderingHerd.Size = 1
+	request.ThunderingHerd.Rate = 1000
+
+	s.ExecuteRequest(context.Background(), 1, &request, false)
+
+	dropped := request.Stats.GetDropped()
+	assert.Greater(t, dropped, int64(0))
+	assert.Equal(t, int64(20), int64(r.ExecuteCallCount())+dropped)
+}
+
+func TestExecuteRequestRateTimeLimit(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	r := &mocks.FakeRest{}
+	r.ExecuteStub = func(_ context.Context, _ int, _ int, _ *config.Request, _ rest.Options) bool {
+		return false
+	}
+
+	s := sequence.New(r)
+
+	// Ramp from 0 to 200/s over 100ms (10 sends), then 200/s for 100ms (20 sends).
+	request := config.Request{}
+	request.ThunderingHerd.Size = 4
+	request.ThunderingHerd.Rate = 200
+	request.ThunderingHerd.Ramp = 100 * time.Millisecond
+	request.ThunderingHerd.TimeLimit = 200 * time.Millisecond
+
+	s.ExecuteRequest(context.Background(), 1, &request, false)
+
+	assert.Equal(t, 30, r.ExecuteCallCount())
+}
+
+func TestExecuteRequestStages(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var mu sync.Mutex
+	maxActive := map[string]int{}
+	active := map[string]int{}
+
+	r := &mocks.FakeRest{}
+	r.ExecuteStub = func(_ context.Context, _ int, _ int, _ *config.Request, opts rest.Options) bool {
+		stage := opts.Stage
+		mu.Lock()
+		active[stage.Name]++
+		maxActive[stage.Name] = max(maxActive[stage.Name], active[stage.Name])
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+		stage.Stats.Success(time.Now())
+
+		mu.Lock()
+		active[stage.Name]--
+		mu.Unlock()
+		return false
+	}
+
+	s := sequence.New(r)
+
+	sc, err := initConfig("../testdata/configs/stages.yaml")
+	assert.Nil(t, err)
+
+	request := &sc.Sequence.Requests[0]
+
+	hadError := s.ExecuteRequest(context.Background(), 1, request, false)
+	assert.False(t, hadError)
+
+	// The pool is resized for each stage...
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 4, maxActive["ramp-up"])
+	assert.Equal(t, 8, maxActive["stage-2"])
+	assert.LessOrEqual(t, maxActive["steady"], 2)
+
+	stages := request.ThunderingHerd.Stages
+	for i := range stages {
+		assert.Greater(t, stages[i].Stats.GetCount(), int64(0))
+	}
+
+	// 100/s for 50ms, some may be dropped with only 2 workers...
+	assert.Equal(t, int64(5), stages[2].Stats.GetCount()+stages[2].Stats.GetDropped())
+}
+
 func TestRun(t *testing.T) {
 
 	initLogger(io.Discard)
@@ -226,6 +394,191 @@ func TestRun(t *testing.T) {
 	// and the max calls per request is 1.
 	assert.Equal(t, 11, r.ExecuteCallCount())
 
+	// Variables are reset at the start of each iteration...
+	assert.Equal(t, sc.Sequence.Iterations, r.StartIterationCallCount())
+	_, seq, iteration := r.StartIterationArgsForCall(0)
+	assert.Equal(t, &sc.Sequence, seq)
+	assert.Equal(t, 0, iteration)
+
+}
+
+func TestRunSequences(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var mu sync.Mutex
+	executed := map[string]int{}
+
+	r := &mocks.FakeRest{}
+	r.ExecuteStub = func(_ context.Context, _ int, _ int, request *config.Request, _ rest.Options) bool {
+		mu.Lock()
+		executed[request.Sequence]++
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		return request.Sequence == "search"
+	}
+
+	sc, err := initConfig("../testdata/configs/sequences.yaml")
+	assert.Nil(t, err)
+
+	// Aborting ends only the sequence with the error...
+	sc.Sequences[1].AbortOnError = true
+
+	s := sequence.New(r)
+	err = s.Run(context.Background(), sc)
+	assert.Nil(t, err)
+
+	// Each user executes every iteration...
+	assert.Equal(t, 14, executed["browse"])
+	assert.Equal(t, int64(14), sc.Sequences[0].Stats.GetCount())
+	assert.Equal(t, 2, executed["sequence-3"])
+	assert.Equal(t, 2, executed["admin"])
+
+	assert.GreaterOrEqual(t, executed["search"], 1)
+	assert.LessOrEqual(t, executed["search"], 2)
+	assert.Equal(t, int64(0), sc.Sequences[1].Stats.GetCount())
+
+	// Once per user iteration, a search user may be aborted before it
+	// executes...
+	assert.GreaterOrEqual(t, r.StartIterationCallCount(), 14+executed["search"]+1+2)
+	assert.LessOrEqual(t, r.StartIterationCallCount(), 14+2+1+2)
+}
+
+func TestRunSequencesCanceled(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	r := &mocks.FakeRest{}
+	r.ExecuteStub = fakeExecuteStub
+
+	sc, err := initConfig("../testdata/configs/sequences.yaml")
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := sequence.New(r)
+	err = s.Run(ctx, sc)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, r.ExecuteCallCount())
+}
+
+func TestRunPhases(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var executed []string
+	r := &mocks.FakeRest{}
+	r.ExecuteStub = func(ctx context.Context, _ int, _ int, request *config.Request, _ rest.Options) bool {
+		executed = append(executed, request.Name)
+		return ctx.Err() != nil
+	}
+
+	sc, err := initConfig("../testdata/configs/phases.yaml")
+	assert.Nil(t, err)
+
+	s := sequence.New(r)
+	err = s.Run(context.Background(), sc)
+	assert.Nil(t, err)
+
+	// Setup and teardown execute once...
+	assert.Equal(t, []string{"create-tenant", "create-key", "list-painters", "list-painters", "list-painters", "delete-key", "delete-tenant"}, executed)
+	assert.Equal(t, int64(1), sc.SetupSequence.Stats.GetCount())
+	assert.Equal(t, int64(1), sc.TeardownSequence.Stats.GetCount())
+
+	// Iterations are only started for the sequence...
+	assert.Equal(t, 3, r.StartIterationCallCount())
+}
+
+func TestRunPhasesSetupFailed(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var executed []string
+	r := &mocks.FakeRest{}
+	r.ExecuteStub = func(_ context.Context, _ int, _ int, request *config.Request, _ rest.Options) bool {
+		executed = append(executed, request.Name)
+		return request.Name == "create-tenant"
+	}
+
+	sc, err := initConfig("../testdata/configs/phases.yaml")
+	assert.Nil(t, err)
+
+	s := sequence.New(r)
+	err = s.Run(context.Background(), sc)
+	assert.EqualError(t, err, "setup failed")
+	assert.ErrorIs(t, err, sequence.ErrSetup)
+
+	// Setup ends on the first error, and the sequence is not executed...
+	assert.Equal(t, []string{"create-tenant", "delete-key", "delete-tenant"}, executed)
+	assert.Equal(t, int64(1), sc.SetupSequence.Stats.GetErrors())
+}
+
+func TestRunPhasesTeardownFailed(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var executed []string
+	r := &mocks.FakeRest{}
+	r.ExecuteStub = func(_ context.Context, _ int, _ int, request *config.Request, _ rest.Options) bool {
+		executed = append(executed, request.Name)
+		return request.Name == "delete-key" || request.Name == "create-tenant"
+	}
+
+	sc, err := initConfig("../testdata/configs/phases.yaml")
+	assert.Nil(t, err)
+
+	s := sequence.New(r)
+	err = s.Run(context.Background(), sc)
+	assert.ErrorIs(t, err, sequence.ErrSetup)
+	assert.ErrorIs(t, err, sequence.ErrTeardown)
+
+	// Teardown executes every request...
+	assert.Equal(t, []string{"create-tenant", "delete-key", "delete-tenant"}, executed)
+	assert.Equal(t, int64(1), sc.TeardownSequence.Stats.GetErrors())
+
+	// ...and fails on its own.
+	executed = nil
+	sc, err = initConfig("../testdata/configs/phases.yaml")
+	assert.Nil(t, err)
+	r.ExecuteStub = func(_ context.Context, _ int, _ int, request *config.Request, _ rest.Options) bool {
+		executed = append(executed, request.Name)
+		return request.Name == "delete-tenant"
+	}
+
+	err = s.Run(context.Background(), sc)
+	assert.EqualError(t, err, "teardown failed")
+	assert.NotErrorIs(t, err, sequence.ErrSetup)
+	assert.Equal(t, "delete-tenant", executed[len(executed)-1])
+}
+
+func TestRunPhasesCanceled(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var executed []string
+	r := &mocks.FakeRest{}
+	r.ExecuteStub = func(rctx context.Context, _ int, _ int, request *config.Request, _ rest.Options) bool {
+		executed = append(executed, request.Name)
+
+		// Interrupted during setup...
+		cancel()
+		return rctx.Err() != nil
+	}
+
+	sc, err := initConfig("../testdata/configs/phases.yaml")
+	assert.Nil(t, err)
+
+	s := sequence.New(r)
+	err = s.Run(ctx, sc)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, err, sequence.ErrSetup)
+
+	// Teardown executes, and is not canceled...
+	assert.Equal(t, []string{"create-tenant", "delete-key", "delete-tenant"}, executed)
+	assert.Equal(t, int64(1), sc.TeardownSequence.Stats.GetCount())
 }
 
 func TestAbortOnError(t *testing.T) {
@@ -233,7 +586,7 @@ func TestAbortOnError(t *testing.T) {
 	initLogger(io.Discard)
 
 	r := &mocks.FakeRest{}
-	r.ExecuteStub = func(_ context.Context, _ int, _ *config.Request, _ *sync.Map) bool {
+	r.ExecuteStub = func(_ context.Context, _ int, _ int, _ *config.Request, _ rest.Options) bool {
 		return true
 	}
 
@@ -269,7 +622,7 @@ func TestAbortOnErrorDisabled(t *testing.T) {
 	initLogger(io.Discard)
 
 	r := &mocks.FakeRest{}
-	r.ExecuteStub = func(_ context.Context, _ int, _ *config.Request, _ *sync.Map) bool {
+	r.ExecuteStub = func(_ context.Context, _ int, _ int, _ *config.Request, _ rest.Options) bool {
 		return true
 	}
 
@@ -306,7 +659,7 @@ func TestAbortOnErrorStopsSequence(t *testing.T) {
 
 	callCount := 0
 	r := &mocks.FakeRest{}
-	r.ExecuteStub = func(_ context.Context, _ int, req *config.Request, _ *sync.Map) bool {
+	r.ExecuteStub = func(_ context.Context, _ int, _ int, req *config.Request, _ rest.Options) bool {
 		callCount++
 		// Only the second request errors.
 		return req.Name == "second"
@@ -361,7 +714,8 @@ func TestIgnoreDuplicateErrors(t *testing.T) {
 	initLogger(io.Discard)
 
 	r := &mocks.FakeRest{}
-	r.ExecuteStub = func(_ context.Context, _ int, _ *config.Request, seen *sync.Map) bool {
+	r.ExecuteStub = func(_ context.Context, _ int, _ int, _ *config.Request, opts rest.Options) bool {
+		seen := opts.SeenErrors
 		// Verify that a seen-errors map was provided.
 		assert.NotNil(t, seen)
 		return true
@@ -398,7 +752,8 @@ func TestIgnoreDuplicateErrorsDisabled(t *testing.T) {
 	initLogger(io.Discard)
 
 	r := &mocks.FakeRest{}
-	r.ExecuteStub = func(_ context.Context, _ int, _ *config.Request, seen *sync.Map) bool {
+	r.ExecuteStub = func(_ context.Context, _ int, _ int, _ *config.Request, opts rest.Options) bool {
+		seen := opts.SeenErrors
 		// When ignore_duplicate_errors is false, no seen-errors map should be passed.
 		assert.Nil(t, seen)
 		return false
@@ -429,3 +784,210 @@ func TestIgnoreDuplicateErrorsDisabled(t *testing.T) {
 
 	assert.Equal(t, 3, r.ExecuteCallCount())
 }
+
+func TestExecuteRequestVirtualUsers(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var mu sync.Mutex
+	inUse := map[int]bool{}
+	seen := map[int]int{}
+	overlapped := false
+
+	r := &mocks.FakeRest{}
+	r.ExecuteStub = func(_ context.Context, _ int, vu int, _ *config.Request, _ rest.Options) bool {
+		mu.Lock()
+		if inUse[vu] {
+			overlapped = true
+		}
+		inUse[vu] = true
+		seen[vu]++
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		inUse[vu] = false
+		mu.Unlock()
+		return false
+	}
+
+	s := sequence.New(r)
+
+	request := config.Request{}
+	request.ThunderingHerd.Size = 4
+	request.ThunderingHerd.Max = 20
+
+	s.ExecuteRequest(context.Background(), 1, &request, false)
+
+	// A virtual user executes one request at a time...
+	assert.False(t, overlapped)
+	assert.Len(t, seen, 4)
+	for vu, n := range seen {
+		assert.True(t, vu >= 0 && vu < 4)
+		assert.Greater(t, n, 0)
+	}
+
+	// A single request at a time uses the scenario scope.
+	seen = map[int]int{}
+	request.ThunderingHerd.Size = 1
+	request.ThunderingHerd.Max = 3
+
+	s.ExecuteRequest(context.Background(), 1, &request, false)
+	assert.Equal(t, map[int]int{data.Shared: 3}, seen)
+}
+
+func TestExecuteRequestForEach(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	var mu sync.Mutex
+	seen := map[int]int{}
+
+	r := &mocks.FakeRest{}
+	r.ElementsReturns([]string{"7", "9", "11", "13", "15"}, nil)
+	r.ExecuteStub = func(_ context.Context, _ int, vu int, _ *config.Request, _ rest.Options) bool {
+		mu.Lock()
+		seen[vu]++
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		return false
+	}
+
+	s := sequence.New(r)
+
+	// Once per element, the herd's limits do not apply...
+	request := config.Request{ForEach: &config.ForEachConfig{Variable: "ORDERS", Match: "ORDER_ID"}}
+	request.ThunderingHerd.Size = 2
+	request.ThunderingHerd.Max = 1
+
+	hadError := s.ExecuteRequest(context.Background(), 1, &request, false)
+	assert.False(t, hadError)
+	assert.Equal(t, 5, r.ExecuteCallCount())
+	assert.Len(t, seen, 2)
+
+	// No elements...
+	r.ElementsReturns(nil, nil)
+	hadError = s.ExecuteRequest(context.Background(), 1, &request, false)
+	assert.False(t, hadError)
+	assert.Equal(t, 5, r.ExecuteCallCount())
+
+	// The elements could not be found...
+	r.ElementsReturns(nil, errors.New("foreach: variable ORDERS is not set"))
+	hadError = s.ExecuteRequest(context.Background(), 1, &request, false)
+	assert.True(t, hadError)
+	assert.Equal(t, 5, r.ExecuteCallCount())
+	assert.Equal(t, int64(1), request.Stats.GetErrors())
+}
+
+// flowRest records the order requests execute in.  Conditions are
+// evaluated from a table, and the named requests fail once.
+type flowRest struct {
+	mu         sync.Mutex
+	executed   []string
+	conditions map[string]bool
+	failures   map[string]int
+}
+
+func (f *flowRest) fake() *mocks.FakeRest {
+
+	r := &mocks.FakeRest{}
+	r.ExecuteStub = func(_ context.Context, _ int, _ int, request *config.Request, _ rest.Options) bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.executed = append(f.executed, request.Name)
+		if f.failures[request.Name] > 0 {
+			f.failures[request.Name]--
+			return true
+		}
+		return false
+	}
+	r.EvaluateStub = func(_ context.Context, _ *config.Sequence, c *condition.Condition, _ string) bool {
+		return f.conditions[c.String()]
+	}
+
+	return r
+}
+
+func TestExecuteSequenceFlow(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	sc, err := initConfig("../testdata/configs/flow.yaml")
+	assert.Nil(t, err)
+
+	// The painter exists, and there is no canvas...
+	f := &flowRest{conditions: map[string]bool{"find-painter.status == 200": true}}
+	s := sequence.New(f.fake())
+
+	hadError := s.ExecuteSequence(context.Background(), 1, &sc.Sequence)
+	assert.False(t, hadError)
+	assert.Equal(t, []string{"login", "find-painter", "get-painter"}, f.executed)
+
+	// On failure, login again.  This takes precedence over abort_on_error...
+	sc.Sequence.AbortOnError = true
+	f = &flowRest{failures: map[string]int{"get-painter": 1}}
+	s = sequence.New(f.fake())
+
+	hadError = s.ExecuteSequence(context.Background(), 1, &sc.Sequence)
+	assert.True(t, hadError)
+	assert.Equal(t, []string{"login", "find-painter", "create-painter", "get-painter", "login", "find-painter", "create-painter", "get-painter"}, f.executed)
+	assert.Equal(t, 0, logger.ErrorCount())
+
+	// Without a failure target, abort_on_error ends the sequence...
+	f = &flowRest{failures: map[string]int{"find-painter": 1}}
+	s = sequence.New(f.fake())
+
+	hadError = s.ExecuteSequence(context.Background(), 1, &sc.Sequence)
+	assert.True(t, hadError)
+	assert.Equal(t, []string{"login", "find-painter"}, f.executed)
+}
+
+func TestExecuteSequenceMaxJumps(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	sc, err := initConfig("../testdata/configs/flow.yaml")
+	assert.Nil(t, err)
+
+	// Painting goes back to get-painter, forever...
+	f := &flowRest{conditions: map[string]bool{`vars.CANVAS != ""`: true}}
+	s := sequence.New(f.fake())
+
+	hadError := s.ExecuteSequence(context.Background(), 1, &sc.Sequence)
+	assert.True(t, hadError)
+	assert.Equal(t, 1, logger.ErrorCount())
+
+	painted := 0
+	for _, name := range f.executed {
+		if name == "paint" {
+			painted++
+		}
+	}
+	assert.Equal(t, sc.Sequence.MaxJumps+1, painted)
+}
+
+func TestExecuteSequenceOnceOnlyGoto(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	r := &mocks.FakeRest{}
+	s := sequence.New(r)
+
+	sc := &config.Scenario{}
+	sc.Sequence.Requests = []config.Request{
+		{Name: "login"},
+		{Name: "setup", OnceOnly: true, Goto: "login"},
+		{Name: "paint"},
+	}
+	sc.Sequence.Requests[0].ThunderingHerd.Max = 1
+	sc.Sequence.Requests[1].ThunderingHerd.Max = 1
+	sc.Sequence.Requests[2].ThunderingHerd.Max = 1
+
+	// The goto is taken the first time only...
+	hadError := s.ExecuteSequence(context.Background(), 1, &sc.Sequence)
+	assert.False(t, hadError)
+	assert.Equal(t, 4, r.ExecuteCallCount())
+	_, _, _, request, _ := r.ExecuteArgsForCall(3)
+	assert.Equal(t, "paint", request.Name)
+}
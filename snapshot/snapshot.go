@@ -0,0 +1,285 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+// Package snapshot compares response content with golden files.
+//
+// JSON content is compared semantically, ie: field order and whitespace
+// are ignored, and volatile fields (eg: timestamps, ids) may be ignored
+// by path.  Other content is compared line by line.
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Extension of snapshot files.
+const Extension = ".snap"
+
+// Most differences reported for a comparison.
+const maxDiffs = 20
+
+// Longest value shown in a difference.
+const maxValue = 64
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Path returns the snapshot file for a name, eg: "get painters/ok" is
+// stored as dir/get_painters_ok.snap
+func Path(dir, name string) string {
+	return filepath.Join(dir, strings.Trim(unsafeChars.ReplaceAllString(name, "_"), "_")+Extension)
+}
+
+// Read returns the snapshot, or nil if there is none.
+func Read(path string) ([]byte, error) {
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return b, err
+}
+
+// Write stores a snapshot, creating the directory if needed.  JSON is
+// indented, so the snapshot is readable and diffs well under version
+// control.
+func Write(path string, content []byte) error {
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	var indented bytes.Buffer
+	if json.Indent(&indented, content, "", "  ") == nil {
+		indented.WriteByte('\n')
+		content = indented.Bytes()
+	}
+
+	return os.WriteFile(path, content, 0644)
+}
+
+// Diff returns the differences between the expected (snapshot) and the
+// actual content.  Paths are dot separated, eg: items.1.id, and an ignore
+// path may use # or * to match any array index or key, eg: items.#.id
+func Diff(expected, actual []byte, ignore []string) []string {
+
+	var e, a any
+	if decode(expected, &e) == nil && decode(actual, &a) == nil {
+		d := &differ{ignore: splitPaths(ignore)}
+		d.compare(nil, e, a)
+		if d.more > 0 {
+			d.diffs = append(d.diffs, fmt.Sprintf("... and %d more differences", d.more))
+		}
+		return d.diffs
+	}
+
+	return diffLines(expected, actual)
+}
+
+func decode(b []byte, v *any) error {
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	err := dec.Decode(v)
+	if err != nil {
+		return err
+	}
+
+	// Trailing content is not JSON...
+	if dec.More() {
+		return fmt.Errorf("trailing content")
+	}
+
+	return nil
+}
+
+func splitPaths(paths []string) [][]string {
+
+	var all [][]string
+	for _, p := range paths {
+		all = append(all, strings.Split(p, "."))
+	}
+	return all
+}
+
+type differ struct {
+	ignore [][]string
+	diffs  []string
+	more   int
+}
+
+func (d *differ) ignored(path []string) bool {
+
+	for _, pattern := range d.ignore {
+		if len(pattern) != len(path) {
+			continue
+		}
+		matched := true
+		for i := range pattern {
+			if pattern[i] != "#" && pattern[i] != "*" && pattern[i] != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *differ) add(path []string, format string, args ...any) {
+
+	if len(d.diffs) == maxDiffs {
+		d.more++
+		return
+	}
+
+	name := strings.Join(path, ".")
+	if name == "" {
+		name = "(root)"
+	}
+
+	d.diffs = append(d.diffs, name+": "+fmt.Sprintf(format, args...))
+}
+
+func (d *differ) compare(path []string, expected, actual any) {
+
+	if d.ignored(path) {
+		return
+	}
+
+	switch e := expected.(type) {
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			d.add(path, "expected %s, got %s", display(expected), display(actual))
+			return
+		}
+
+		keys := make([]string, 0, len(e)+len(a))
+		for k := range e {
+			keys = append(keys, k)
+		}
+		for k := range a {
+			if _, ok := e[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+
+		for _, k := range keys {
+			p := append(slices.Clip(path), k)
+			ev, eok := e[k]
+			av, aok := a[k]
+			switch {
+			case d.ignored(p):
+			case !aok:
+				d.add(p, "missing, expected %s", display(ev))
+			case !eok:
+				d.add(p, "unexpected %s", display(av))
+			default:
+				d.compare(p, ev, av)
+			}
+		}
+
+	case []any:
+		a, ok := actual.([]any)
+		if !ok {
+			d.add(path, "expected %s, got %s", display(expected), display(actual))
+			return
+		}
+
+		for i := range max(len(e), len(a)) {
+			p := append(slices.Clip(path), strconv.Itoa(i))
+			switch {
+			case d.ignored(p):
+			case i >= len(a):
+				d.add(p, "missing, expected %s", display(e[i]))
+			case i >= len(e):
+				d.add(p, "unexpected %s", display(a[i]))
+			default:
+				d.compare(p, e[i], a[i])
+			}
+		}
+
+	default:
+		if !scalarEqual(expected, actual) {
+			d.add(path, "expected %s, got %s", display(expected), display(actual))
+		}
+	}
+}
+
+// scalarEqual compares numbers by value, eg: 1.0 equals 1
+func scalarEqual(expected, actual any) bool {
+
+	en, eok := expected.(json.Number)
+	an, aok := actual.(json.Number)
+	if eok && aok {
+		ef, err1 := en.Float64()
+		af, err2 := an.Float64()
+		if err1 == nil && err2 == nil {
+			return ef == af
+		}
+		return en == an
+	}
+
+	return expected == actual
+}
+
+func display(v any) string {
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	if len(b) > maxValue {
+		return string(b[:maxValue]) + "..."
+	}
+	return string(b)
+}
+
+// diffLines reports the first differing line of non-JSON content.
+func diffLines(expected, actual []byte) []string {
+
+	if bytes.Equal(expected, actual) {
+		return nil
+	}
+
+	el := strings.Split(string(expected), "\n")
+	al := strings.Split(string(actual), "\n")
+
+	for i := range max(len(el), len(al)) {
+		switch {
+		case i >= len(al):
+			return []string{fmt.Sprintf("line %d: missing, expected %s", i+1, quote(el[i]))}
+		case i >= len(el):
+			return []string{fmt.Sprintf("line %d: unexpected %s", i+1, quote(al[i]))}
+		case el[i] != al[i]:
+			return []string{fmt.Sprintf("line %d: expected %s, got %s", i+1, quote(el[i]), quote(al[i]))}
+		}
+	}
+
+	return nil
+}
+
+func quote(s string) string {
+
+	if len(s) > maxValue {
+		s = s[:maxValue] + "..."
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,110 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package snapshot_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pwmorreale/rapid/snapshot"
+	"github.com/stretchr/testify/assert"
+)
+
+var golden = []byte(`{
+  "items": [{"id": 1, "name": "Bob Ross"}, {"id": 2, "name": "Frida Kahlo"}],
+  "total": 2.0,
+  "updated_at": "2025-01-01T00:00:00Z"
+}`)
+
+func TestPath(t *testing.T) {
+
+	assert.Equal(t, filepath.Join("snaps", "get_painters_ok.snap"), snapshot.Path("snaps", "get painters/ok"))
+	assert.Equal(t, filepath.Join("snaps", "painter-1.snap"), snapshot.Path("snaps", "painter-1"))
+}
+
+func TestReadWrite(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "nested", "painters.snap")
+
+	// No snapshot yet...
+	b, err := snapshot.Read(path)
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+
+	err = snapshot.Write(path, []byte(`{"total":2}`))
+	assert.Nil(t, err)
+
+	// JSON is indented...
+	b, err = snapshot.Read(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "{\n  \"total\": 2\n}\n", string(b))
+
+	err = snapshot.Write(path, []byte("happy little trees"))
+	assert.Nil(t, err)
+
+	b, err = os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "happy little trees", string(b))
+}
+
+func TestDiff(t *testing.T) {
+
+	// Order, whitespace, and number formatting are ignored...
+	actual := []byte(`{"updated_at": "2025-01-01T00:00:00Z", "total": 2, "items": [{"name": "Bob Ross", "id": 1}, {"name": "Frida Kahlo", "id": 2}]}`)
+	assert.Empty(t, snapshot.Diff(golden, actual, nil))
+
+	actual = []byte(`{"items": [{"id": 1, "name": "Bob Ross"}, {"id": 3, "name": "Mary Cassatt"}, {"id": 4}], "updated_at": "2025-06-01T00:00:00Z", "paging": null}`)
+	assert.Equal(t, []string{
+		`items.1.id: expected 2, got 3`,
+		`items.1.name: expected "Frida Kahlo", got "Mary Cassatt"`,
+		`items.2: unexpected {"id":4}`,
+		`paging: unexpected null`,
+		`total: missing, expected 2.0`,
+		`updated_at: expected "2025-01-01T00:00:00Z", got "2025-06-01T00:00:00Z"`,
+	}, snapshot.Diff(golden, actual, nil))
+
+	assert.Equal(t, []string{
+		`items.2: unexpected {"id":4}`,
+		`paging: unexpected null`,
+		`total: missing, expected 2.0`,
+	}, snapshot.Diff(golden, actual, []string{"updated_at", "items.#.id", "items.*.name"}))
+
+	// Type changes...
+	assert.Equal(t, []string{`(root): expected {"total":1}, got [1]`}, snapshot.Diff([]byte(`{"total":1}`), []byte(`[1]`), nil))
+	assert.Equal(t, []string{`total: expected 1, got "1"`}, snapshot.Diff([]byte(`{"total":1}`), []byte(`{"total":"1"}`), nil))
+}
+
+func TestDiffLimit(t *testing.T) {
+
+	var expected, actual []string
+	for i := range 25 {
+		expected = append(expected, fmt.Sprint(i))
+		actual = append(actual, fmt.Sprint(i+100))
+	}
+
+	diffs := snapshot.Diff([]byte("["+strings.Join(expected, ",")+"]"), []byte("["+strings.Join(actual, ",")+"]"), nil)
+	assert.Len(t, diffs, 21)
+	assert.Equal(t, "0: expected 0, got 100", diffs[0])
+	assert.Equal(t, "... and 5 more differences", diffs[20])
+}
+
+func TestDiffText(t *testing.T) {
+
+	expected := []byte("<note>\n  <from>Bob Ross</from>\n</note>")
+
+	assert.Empty(t, snapshot.Diff(expected, expected, nil))
+
+	assert.Equal(t, []string{`line 2: expected "  <from>Bob Ross</from>", got "  <from>Frida Kahlo</from>"`},
+		snapshot.Diff(expected, []byte("<note>\n  <from>Frida Kahlo</from>\n</note>"), nil))
+
+	assert.Equal(t, []string{`line 3: missing, expected "</note>"`},
+		snapshot.Diff(expected, []byte("<note>\n  <from>Bob Ross</from>"), nil))
+
+	assert.Equal(t, []string{`line 4: unexpected ""`},
+		snapshot.Diff(expected, append(expected, '\n'), nil))
+}
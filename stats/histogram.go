@@ -0,0 +1,137 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package stats
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// The histogram is high dynamic range (HDR): each power of two is split
+// into subBucketHalf linear sub-buckets, so recorded values have a
+// relative error of less than 1/subBucketHalf (under 1%) across the range.
+const (
+	subBucketBits  = 8
+	subBucketCount = 1 << subBucketBits
+	subBucketHalf  = subBucketCount / 2
+
+	// Values are nanoseconds, larger values are clamped (~4.9 hours).
+	maxValueBits = 44
+	maxValue     = 1<<maxValueBits - 1
+
+	bucketCount = (maxValueBits-subBucketBits+1)*subBucketHalf + subBucketHalf
+)
+
+// histogram is a lock-free HDR histogram of durations.  The zero value is
+// ready to use.
+type histogram struct {
+	counts [bucketCount]int64
+}
+
+// bucketIndex returns the bucket for v.
+func bucketIndex(v int64) int {
+
+	if v < 0 {
+		v = 0
+	}
+	if v > maxValue {
+		v = maxValue
+	}
+
+	if v < subBucketCount {
+		return int(v)
+	}
+
+	exp := bits.Len64(uint64(v)) - subBucketBits
+	return exp*subBucketHalf + int(v>>exp)
+}
+
+// bucketRange returns the lowest value, and the width, of a bucket.
+func bucketRange(idx int) (int64, int64) {
+
+	if idx < subBucketCount {
+		return int64(idx), 1
+	}
+
+	exp := idx/subBucketHalf - 1
+	sub := idx - exp*subBucketHalf
+	return int64(sub) << exp, 1 << exp
+}
+
+func (h *histogram) record(v int64) {
+	atomic.AddInt64(&h.counts[bucketIndex(v)], 1)
+}
+
+func (h *histogram) merge(o *histogram) {
+
+	for i := range o.counts {
+		if n := atomic.LoadInt64(&o.counts[i]); n > 0 {
+			atomic.AddInt64(&h.counts[i], n)
+		}
+	}
+}
+
+// snapshot returns a consistent copy of the counts, and their total.
+func (h *histogram) snapshot() ([]int64, int64) {
+
+	counts := make([]int64, bucketCount)
+	var total int64
+	for i := range h.counts {
+		counts[i] = atomic.LoadInt64(&h.counts[i])
+		total += counts[i]
+	}
+
+	return counts, total
+}
+
+// percentile returns the highest value equivalent to the qth percentile.
+func (h *histogram) percentile(q float64) int64 {
+
+	counts, total := h.snapshot()
+	if total == 0 {
+		return 0
+	}
+
+	q = math.Max(0, math.Min(100, q))
+
+	target := int64(math.Ceil(q / 100 * float64(total)))
+	if target < 1 {
+		target = 1
+	}
+
+	var seen int64
+	for i := range counts {
+		seen += counts[i]
+		if seen >= target {
+			lower, width := bucketRange(i)
+			return lower + width - 1
+		}
+	}
+
+	return maxValue
+}
+
+// stdDev returns the standard deviation, using the midpoint of each bucket.
+func (h *histogram) stdDev(mean float64) time.Duration {
+
+	counts, total := h.snapshot()
+	if total == 0 {
+		return 0
+	}
+
+	var sum float64
+	for i := range counts {
+		if counts[i] == 0 {
+			continue
+		}
+		lower, width := bucketRange(i)
+		d := float64(lower) + float64(width-1)/2 - mean
+		sum += float64(counts[i]) * d * d
+	}
+
+	return time.Duration(math.Sqrt(sum / float64(total)))
+}
@@ -0,0 +1,125 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package stats
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketRange(t *testing.T) {
+
+	values := []int64{0, 1, 127, 128, 129, 255, 256, 1000, 123456789, maxValue}
+	for i := 0; i < 1000; i++ {
+		values = append(values, rand.Int63n(maxValue))
+	}
+
+	for _, v := range values {
+		idx := bucketIndex(v)
+		assert.Less(t, idx, bucketCount)
+
+		lower, width := bucketRange(idx)
+		assert.LessOrEqual(t, lower, v)
+		assert.Less(t, v, lower+width)
+
+		// Relative error is < 1%
+		assert.Less(t, float64(width-1), float64(v)/100+1)
+	}
+
+	// Clamped...
+	assert.Equal(t, bucketIndex(maxValue), bucketIndex(maxValue+1000))
+	assert.Equal(t, 0, bucketIndex(-1))
+}
+
+func within(t *testing.T, want, got time.Duration) {
+	t.Helper()
+	assert.InEpsilon(t, float64(want), float64(got), 0.01, "want %s, got %s", want, got)
+}
+
+func TestPercentile(t *testing.T) {
+
+	var s Statistics
+
+	assert.Equal(t, time.Duration(0), s.Percentile(99))
+	assert.Equal(t, time.Duration(0), s.StdDev())
+
+	for i := 1; i <= 1000; i++ {
+		s.Success(time.Now().Add(-time.Duration(i) * time.Millisecond))
+	}
+
+	within(t, 500*time.Millisecond, s.Percentile(50))
+	within(t, 900*time.Millisecond, s.Percentile(90))
+	within(t, 990*time.Millisecond, s.Percentile(99))
+	within(t, 999*time.Millisecond, s.Percentile(99.9))
+	within(t, s.GetMinDuration(), s.Percentile(0))
+	assert.Equal(t, s.GetMaxDuration(), s.Percentile(100))
+
+	// Uniform distribution over 1..1000ms
+	within(t, 288675*time.Microsecond, s.StdDev())
+
+	str := s.String()
+	assert.Contains(t, str, "p50=")
+	assert.Contains(t, str, "p99.9=")
+	assert.Contains(t, str, "stdDev=")
+}
+
+func TestMerge(t *testing.T) {
+
+	var a, b, all Statistics
+
+	for i := 1; i <= 500; i++ {
+		start := time.Now().Add(-time.Duration(i) * time.Millisecond)
+		a.Success(start)
+		all.Success(start)
+	}
+
+	for i := 501; i <= 1000; i++ {
+		start := time.Now().Add(-time.Duration(i) * time.Millisecond)
+		b.Error(start)
+		all.Error(start)
+	}
+	b.Drop()
+
+	a.Merge(&b)
+
+	assert.Equal(t, int64(500), a.GetCount())
+	assert.Equal(t, int64(500), a.GetErrors())
+	assert.Equal(t, int64(1), a.GetDropped())
+	assert.Equal(t, b.GetMaxDuration(), a.GetMaxDuration())
+	assert.Less(t, a.GetMinDuration(), b.GetMinDuration())
+
+	for _, q := range []float64{50, 90, 99, 99.9} {
+		within(t, all.Percentile(q), a.Percentile(q))
+	}
+	within(t, all.StdDev(), a.StdDev())
+}
+
+func TestConcurrentMerge(t *testing.T) {
+
+	var s Statistics
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var local Statistics
+			for n := 0; n < 100; n++ {
+				local.Success(time.Now().Add(-time.Millisecond))
+			}
+			s.Merge(&local)
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int64(1000), s.GetCount())
+	within(t, time.Millisecond, s.Percentile(99))
+}
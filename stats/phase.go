@@ -0,0 +1,116 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package stats
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Phase identifies a part of a request's execution.
+type Phase int
+
+// Phases, in the order they occur.
+const (
+	PhaseDNS      Phase = iota // DNS lookup
+	PhaseConnect               // TCP connect
+	PhaseTLS                   // TLS handshake
+	PhaseTTFB                  // Request written to the first response byte
+	PhaseTransfer              // First response byte to the body read
+	phaseCount
+)
+
+var phaseNames = [phaseCount]string{"dns", "connect", "tls", "ttfb", "transfer"}
+
+// String returns the phase name.
+func (p Phase) String() string {
+	return phaseNames[p]
+}
+
+// Phases returns all phases, in order.
+func Phases() []Phase {
+	return []Phase{PhaseDNS, PhaseConnect, PhaseTLS, PhaseTTFB, PhaseTransfer}
+}
+
+// Timings defines the phase durations of a single request.  Phases that
+// did not occur are zero, eg: a reused connection has no DNS, connect, or
+// TLS phases.
+type Timings [phaseCount]time.Duration
+
+type phaseStats struct {
+	count     int64
+	totalTime int64
+	maxTime   int64
+}
+
+func (p *phaseStats) setMax(d int64) {
+	for {
+		current := atomic.LoadInt64(&p.maxTime)
+		if d <= current {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&p.maxTime, current, d) {
+			return
+		}
+	}
+}
+
+func (p *phaseStats) add(n, total, maxTime int64) {
+	atomic.AddInt64(&p.count, n)
+	atomic.AddInt64(&p.totalTime, total)
+	p.setMax(maxTime)
+}
+
+// Timing records the phase durations of a request.
+func (s *Statistics) Timing(t *Timings) {
+
+	for i, d := range t {
+		if d > 0 {
+			s.phases[i].add(1, int64(d), int64(d))
+		}
+	}
+}
+
+// GetPhaseCount returns the number of requests that included the phase.
+func (s *Statistics) GetPhaseCount(p Phase) int64 {
+	return atomic.LoadInt64(&s.phases[p].count)
+}
+
+// GetPhaseAvg returns the average duration of the phase.
+func (s *Statistics) GetPhaseAvg(p Phase) time.Duration {
+
+	n := atomic.LoadInt64(&s.phases[p].count)
+	if n == 0 {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&s.phases[p].totalTime) / n)
+}
+
+// GetPhaseMax returns the maximum duration of the phase.
+func (s *Statistics) GetPhaseMax(p Phase) time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.phases[p].maxTime))
+}
+
+func (s *Statistics) mergePhases(o *Statistics) {
+
+	for i := range o.phases {
+		p := &o.phases[i]
+		s.phases[i].add(atomic.LoadInt64(&p.count), atomic.LoadInt64(&p.totalTime), atomic.LoadInt64(&p.maxTime))
+	}
+}
+
+// phaseString returns the average of each recorded phase.
+func (s *Statistics) phaseString() string {
+
+	str := ""
+	for _, p := range Phases() {
+		if s.GetPhaseCount(p) > 0 {
+			str += fmt.Sprintf(" %s=%s", p, s.GetPhaseAvg(p))
+		}
+	}
+
+	return str
+}
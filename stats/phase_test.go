@@ -0,0 +1,40 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTiming(t *testing.T) {
+
+	var s Statistics
+
+	assert.NotContains(t, s.String(), "ttfb=")
+
+	s.Timing(&Timings{PhaseDNS: 2 * time.Millisecond, PhaseConnect: time.Millisecond, PhaseTTFB: 10 * time.Millisecond})
+	s.Timing(&Timings{PhaseTTFB: 30 * time.Millisecond, PhaseTransfer: time.Millisecond})
+
+	assert.Equal(t, int64(1), s.GetPhaseCount(PhaseDNS))
+	assert.Equal(t, int64(0), s.GetPhaseCount(PhaseTLS))
+	assert.Equal(t, int64(2), s.GetPhaseCount(PhaseTTFB))
+	assert.Equal(t, 20*time.Millisecond, s.GetPhaseAvg(PhaseTTFB))
+	assert.Equal(t, 30*time.Millisecond, s.GetPhaseMax(PhaseTTFB))
+	assert.Equal(t, time.Duration(0), s.GetPhaseAvg(PhaseTLS))
+
+	str := s.String()
+	assert.Contains(t, str, "dns=2ms connect=1ms ttfb=20ms transfer=1ms")
+	assert.NotContains(t, str, "tls=")
+
+	var m Statistics
+	m.Merge(&s)
+	m.Merge(&s)
+	assert.Equal(t, int64(4), m.GetPhaseCount(PhaseTTFB))
+	assert.Equal(t, 20*time.Millisecond, m.GetPhaseAvg(PhaseTTFB))
+	assert.Equal(t, 30*time.Millisecond, m.GetPhaseMax(PhaseTTFB))
+}
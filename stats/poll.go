@@ -0,0 +1,38 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+package stats
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Polling defines the statistics of a polled request.  Execution times are
+// the total wait, from the first poll until the condition was met, or not.
+// Each poll is also recorded in the request's own statistics.
+type Polling struct {
+	Statistics
+	polls int64
+}
+
+// Poll counts a request sent while polling.
+func (p *Polling) Poll() {
+	atomic.AddInt64(&p.polls, 1)
+}
+
+// GetPolls returns the number of requests sent while polling.
+func (p *Polling) GetPolls() int64 {
+	return atomic.LoadInt64(&p.polls)
+}
+
+// Merge adds the statistics in o to p.
+func (p *Polling) Merge(o *Polling) {
+	p.Statistics.Merge(&o.Statistics)
+	atomic.AddInt64(&p.polls, atomic.LoadInt64(&o.polls))
+}
+
+func (p *Polling) String() string {
+	return fmt.Sprintf("polls=%d %s", atomic.LoadInt64(&p.polls), p.Statistics.String())
+}
@@ -11,13 +11,20 @@ import (
 	"time"
 )
 
-// Statistics defines measured statistics.
+// Statistics defines measured statistics.  All execution times, for both
+// successes and errors, are recorded in a histogram for percentiles.
 type Statistics struct {
 	count     int64
 	errors    int64
 	totalTime int64
 	minTime   int64
 	maxTime   int64
+	dropped   int64
+	slow      int64 // Errors that are only outside the expected duration
+	first     int64 // Earliest start, UnixNano
+	last      int64 // Latest completion, UnixNano
+	times     histogram
+	phases    [phaseCount]phaseStats
 }
 
 func (s *Statistics) setMin(d int64) {
@@ -44,12 +51,41 @@ func (s *Statistics) setMax(d int64) {
 	}
 }
 
+func (s *Statistics) setFirst(t int64) {
+	for {
+		current := atomic.LoadInt64(&s.first)
+		if current > 0 && t >= current {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&s.first, current, t) {
+			return
+		}
+	}
+}
+
+func (s *Statistics) setLast(t int64) {
+	for {
+		current := atomic.LoadInt64(&s.last)
+		if t <= current {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&s.last, current, t) {
+			return
+		}
+	}
+}
+
 func (s *Statistics) updateTimes(start time.Time) {
 
-	d := int64(time.Since(start))
+	now := time.Now()
+	s.setFirst(start.UnixNano())
+	s.setLast(now.UnixNano())
+
+	d := int64(now.Sub(start))
 	atomic.AddInt64(&s.totalTime, d)
 	s.setMin(d)
 	s.setMax(d)
+	s.times.record(d)
 
 }
 
@@ -65,6 +101,66 @@ func (s *Statistics) Error(start time.Time) {
 	s.updateTimes(start)
 }
 
+// DurationError counts an error for a response that was otherwise valid,
+// but was not received within the expected duration.
+func (s *Statistics) DurationError(start time.Time) {
+	atomic.AddInt64(&s.slow, 1)
+	s.Error(start)
+}
+
+// Drop counts a request that was scheduled but not sent.
+func (s *Statistics) Drop() {
+	atomic.AddInt64(&s.dropped, 1)
+}
+
+// Merge adds the statistics in o to s.
+func (s *Statistics) Merge(o *Statistics) {
+
+	atomic.AddInt64(&s.count, atomic.LoadInt64(&o.count))
+	atomic.AddInt64(&s.errors, atomic.LoadInt64(&o.errors))
+	atomic.AddInt64(&s.totalTime, atomic.LoadInt64(&o.totalTime))
+	atomic.AddInt64(&s.dropped, atomic.LoadInt64(&o.dropped))
+	atomic.AddInt64(&s.slow, atomic.LoadInt64(&o.slow))
+
+	if d := atomic.LoadInt64(&o.minTime); d > 0 {
+		s.setMin(d)
+	}
+	s.setMax(atomic.LoadInt64(&o.maxTime))
+
+	if t := atomic.LoadInt64(&o.first); t > 0 {
+		s.setFirst(t)
+	}
+	s.setLast(atomic.LoadInt64(&o.last))
+
+	s.times.merge(&o.times)
+	s.mergePhases(o)
+}
+
+// Percentile returns the execution time at percentile q, eg: 99.9
+// The result is accurate to within 1%.
+func (s *Statistics) Percentile(q float64) time.Duration {
+
+	d := s.times.percentile(q)
+
+	// Buckets are wider than the recorded range...
+	d = min(d, atomic.LoadInt64(&s.maxTime))
+	d = max(d, atomic.LoadInt64(&s.minTime))
+
+	return time.Duration(d)
+}
+
+// StdDev returns the standard deviation of the execution times.
+func (s *Statistics) StdDev() time.Duration {
+
+	n := atomic.LoadInt64(&s.count) + atomic.LoadInt64(&s.errors)
+	if n == 0 {
+		return 0
+	}
+
+	mean := float64(atomic.LoadInt64(&s.totalTime)) / float64(n)
+	return s.times.stdDev(mean)
+}
+
 // GetCount returns the count.
 func (s *Statistics) GetCount() int64 {
 	return atomic.LoadInt64(&s.count)
@@ -75,6 +171,28 @@ func (s *Statistics) GetErrors() int64 {
 	return atomic.LoadInt64(&s.errors)
 }
 
+// GetDurationErrors returns the errors that were only outside the expected
+// duration.  They are included in GetErrors.
+func (s *Statistics) GetDurationErrors() int64 {
+	return atomic.LoadInt64(&s.slow)
+}
+
+// GetDropped returns the dropped count.
+func (s *Statistics) GetDropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// GetElapsed returns the wall time from the earliest start to the latest
+// completion.
+func (s *Statistics) GetElapsed() time.Duration {
+
+	first := atomic.LoadInt64(&s.first)
+	if first == 0 {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&s.last) - first)
+}
+
 // GetMinDuration returns the minimum duration.
 func (s *Statistics) GetMinDuration() time.Duration {
 	return time.Duration(atomic.LoadInt64(&s.minTime))
@@ -104,12 +222,34 @@ func (s *Statistics) String() string {
 		divisor = 1
 	}
 
-	return fmt.Sprintf("count=%d errors=%d minTime=%s maxTime=%s avgTime=%s",
+	str := fmt.Sprintf("count=%d errors=%d minTime=%s maxTime=%s avgTime=%s p50=%s p90=%s p99=%s p99.9=%s stdDev=%s",
 		count,
 		errors,
 		time.Duration(minTime).String(),
 		time.Duration(maxTime).String(),
 		time.Duration(totalTime/divisor).String(),
+		s.Percentile(50).String(),
+		s.Percentile(90).String(),
+		s.Percentile(99).String(),
+		s.Percentile(99.9).String(),
+		s.StdDev().String(),
 	)
 
+	// Only reported for rate limited requests...
+	dropped := atomic.LoadInt64(&s.dropped)
+	if dropped > 0 {
+		str += fmt.Sprintf(" dropped=%d", dropped)
+	}
+
+	// Only reported for responses with a min or max duration...
+	slow := atomic.LoadInt64(&s.slow)
+	if slow > 0 {
+		str += fmt.Sprintf(" duration_errors=%d", slow)
+	}
+
+	// Only reported once a response is received...
+	str += s.phaseString()
+
+	return str
+
 }
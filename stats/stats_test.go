@@ -85,6 +85,78 @@ func TestStringWithData(t *testing.T) {
 	assert.Contains(t, str, "avgTime=")
 }
 
+func TestDropped(t *testing.T) {
+
+	var s Statistics
+
+	assert.NotContains(t, s.String(), "dropped=")
+
+	s.Drop()
+	s.Drop()
+
+	assert.Equal(t, int64(2), s.GetDropped())
+	assert.Equal(t, int64(0), s.GetCount())
+	assert.Contains(t, s.String(), "dropped=2")
+}
+
+func TestDurationError(t *testing.T) {
+
+	var s Statistics
+
+	assert.NotContains(t, s.String(), "duration_errors=")
+
+	start := time.Now()
+	s.Error(start)
+	s.DurationError(start)
+
+	assert.Equal(t, int64(2), s.GetErrors())
+	assert.Equal(t, int64(1), s.GetDurationErrors())
+	assert.Contains(t, s.String(), "errors=2")
+	assert.Contains(t, s.String(), "duration_errors=1")
+
+	var total Statistics
+	total.Merge(&s)
+	assert.Equal(t, int64(1), total.GetDurationErrors())
+}
+
+func TestPolling(t *testing.T) {
+
+	var p Polling
+
+	start := time.Now().Add(-time.Second)
+	p.Poll()
+	p.Poll()
+	p.Poll()
+	p.Success(start)
+	p.Poll()
+	p.Error(start)
+
+	assert.Equal(t, int64(4), p.GetPolls())
+	assert.Equal(t, int64(1), p.GetCount())
+	assert.Equal(t, int64(1), p.GetErrors())
+	assert.GreaterOrEqual(t, p.GetMinDuration(), time.Second)
+	assert.Contains(t, p.String(), "polls=4 count=1 errors=1")
+
+	var total Polling
+	total.Merge(&p)
+	assert.Equal(t, int64(4), total.GetPolls())
+	assert.Equal(t, int64(1), total.GetErrors())
+}
+
+func TestElapsed(t *testing.T) {
+
+	var s Statistics
+
+	assert.Equal(t, time.Duration(0), s.GetElapsed())
+
+	now := time.Now()
+	s.Success(now.Add(-time.Second))
+	s.Error(now.Add(-100 * time.Millisecond))
+
+	assert.GreaterOrEqual(t, s.GetElapsed(), time.Second)
+	assert.Less(t, s.GetElapsed(), 2*time.Second)
+}
+
 func TestConcurrentAccess(t *testing.T) {
 
 	var s Statistics
@@ -35,11 +35,12 @@ type FakeData struct {
 		result1 string
 		result2 error
 	}
-	ExtractRegexStub        func(string, io.Reader) (string, error)
+	ExtractRegexStub        func(string, io.Reader, data.RegexMatch) (string, error)
 	extractRegexMutex       sync.RWMutex
 	extractRegexArgsForCall []struct {
 		arg1 string
 		arg2 io.Reader
+		arg3 data.RegexMatch
 	}
 	extractRegexReturns struct {
 		result1 string
@@ -84,6 +85,20 @@ type FakeData struct {
 	lookupReturnsOnCall map[int]struct {
 		result1 string
 	}
+	RenderStub        func(string, int) (string, error)
+	renderMutex       sync.RWMutex
+	renderArgsForCall []struct {
+		arg1 string
+		arg2 int
+	}
+	renderReturns struct {
+		result1 string
+		result2 error
+	}
+	renderReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
 	ReplaceStub        func(string) string
 	replaceMutex       sync.RWMutex
 	replaceArgsForCall []struct {
@@ -95,6 +110,58 @@ type FakeData struct {
 	replaceReturnsOnCall map[int]struct {
 		result1 string
 	}
+	ResetStub        func(...string)
+	resetMutex       sync.RWMutex
+	resetArgsForCall []struct {
+		arg1 []string
+	}
+	ScopeStub        func(int) data.Data
+	scopeMutex       sync.RWMutex
+	scopeArgsForCall []struct {
+		arg1 int
+	}
+	scopeReturns struct {
+		result1 data.Data
+	}
+	scopeReturnsOnCall map[int]struct {
+		result1 data.Data
+	}
+	SnapshotStub        func() data.Snapshot
+	snapshotMutex       sync.RWMutex
+	snapshotArgsForCall []struct {
+	}
+	snapshotReturns struct {
+		result1 data.Snapshot
+	}
+	snapshotReturnsOnCall map[int]struct {
+		result1 data.Snapshot
+	}
+	UnsetStub        func(string)
+	unsetMutex       sync.RWMutex
+	unsetArgsForCall []struct {
+		arg1 string
+	}
+	UserStub        func(int) data.Data
+	userMutex       sync.RWMutex
+	userArgsForCall []struct {
+		arg1 int
+	}
+	userReturns struct {
+		result1 data.Data
+	}
+	userReturnsOnCall map[int]struct {
+		result1 data.Data
+	}
+	VariablesStub        func() map[string]string
+	variablesMutex       sync.RWMutex
+	variablesArgsForCall []struct {
+	}
+	variablesReturns struct {
+		result1 map[string]string
+	}
+	variablesReturnsOnCall map[int]struct {
+		result1 map[string]string
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -226,19 +293,20 @@ func (fake *FakeData) ExtractJSONReturnsOnCall(i int, result1 string, result2 er
 	}{result1, result2}
 }
 
-func (fake *FakeData) ExtractRegex(arg1 string, arg2 io.Reader) (string, error) {
+func (fake *FakeData) ExtractRegex(arg1 string, arg2 io.Reader, arg3 data.RegexMatch) (string, error) {
 	fake.extractRegexMutex.Lock()
 	ret, specificReturn := fake.extractRegexReturnsOnCall[len(fake.extractRegexArgsForCall)]
 	fake.extractRegexArgsForCall = append(fake.extractRegexArgsForCall, struct {
 		arg1 string
 		arg2 io.Reader
-	}{arg1, arg2})
+		arg3 data.RegexMatch
+	}{arg1, arg2, arg3})
 	stub := fake.ExtractRegexStub
 	fakeReturns := fake.extractRegexReturns
-	fake.recordInvocation("ExtractRegex", []interface{}{arg1, arg2})
+	fake.recordInvocation("ExtractRegex", []interface{}{arg1, arg2, arg3})
 	fake.extractRegexMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -252,17 +320,17 @@ func (fake *FakeData) ExtractRegexCallCount() int {
 	return len(fake.extractRegexArgsForCall)
 }
 
-func (fake *FakeData) ExtractRegexCalls(stub func(string, io.Reader) (string, error)) {
+func (fake *FakeData) ExtractRegexCalls(stub func(string, io.Reader, data.RegexMatch) (string, error)) {
 	fake.extractRegexMutex.Lock()
 	defer fake.extractRegexMutex.Unlock()
 	fake.ExtractRegexStub = stub
 }
 
-func (fake *FakeData) ExtractRegexArgsForCall(i int) (string, io.Reader) {
+func (fake *FakeData) ExtractRegexArgsForCall(i int) (string, io.Reader, data.RegexMatch) {
 	fake.extractRegexMutex.RLock()
 	defer fake.extractRegexMutex.RUnlock()
 	argsForCall := fake.extractRegexArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *FakeData) ExtractRegexReturns(result1 string, result2 error) {
@@ -470,6 +538,71 @@ func (fake *FakeData) LookupReturnsOnCall(i int, result1 string) {
 	}{result1}
 }
 
+func (fake *FakeData) Render(arg1 string, arg2 int) (string, error) {
+	fake.renderMutex.Lock()
+	ret, specificReturn := fake.renderReturnsOnCall[len(fake.renderArgsForCall)]
+	fake.renderArgsForCall = append(fake.renderArgsForCall, struct {
+		arg1 string
+		arg2 int
+	}{arg1, arg2})
+	stub := fake.RenderStub
+	fakeReturns := fake.renderReturns
+	fake.recordInvocation("Render", []interface{}{arg1, arg2})
+	fake.renderMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeData) RenderCallCount() int {
+	fake.renderMutex.RLock()
+	defer fake.renderMutex.RUnlock()
+	return len(fake.renderArgsForCall)
+}
+
+func (fake *FakeData) RenderCalls(stub func(string, int) (string, error)) {
+	fake.renderMutex.Lock()
+	defer fake.renderMutex.Unlock()
+	fake.RenderStub = stub
+}
+
+func (fake *FakeData) RenderArgsForCall(i int) (string, int) {
+	fake.renderMutex.RLock()
+	defer fake.renderMutex.RUnlock()
+	argsForCall := fake.renderArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeData) RenderReturns(result1 string, result2 error) {
+	fake.renderMutex.Lock()
+	defer fake.renderMutex.Unlock()
+	fake.RenderStub = nil
+	fake.renderReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeData) RenderReturnsOnCall(i int, result1 string, result2 error) {
+	fake.renderMutex.Lock()
+	defer fake.renderMutex.Unlock()
+	fake.RenderStub = nil
+	if fake.renderReturnsOnCall == nil {
+		fake.renderReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.renderReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeData) Replace(arg1 string) string {
 	fake.replaceMutex.Lock()
 	ret, specificReturn := fake.replaceReturnsOnCall[len(fake.replaceArgsForCall)]
@@ -531,6 +664,298 @@ func (fake *FakeData) ReplaceReturnsOnCall(i int, result1 string) {
 	}{result1}
 }
 
+func (fake *FakeData) Reset(arg1 ...string) {
+	fake.resetMutex.Lock()
+	fake.resetArgsForCall = append(fake.resetArgsForCall, struct {
+		arg1 []string
+	}{arg1})
+	stub := fake.ResetStub
+	fake.recordInvocation("Reset", []interface{}{arg1})
+	fake.resetMutex.Unlock()
+	if stub != nil {
+		fake.ResetStub(arg1...)
+	}
+}
+
+func (fake *FakeData) ResetCallCount() int {
+	fake.resetMutex.RLock()
+	defer fake.resetMutex.RUnlock()
+	return len(fake.resetArgsForCall)
+}
+
+func (fake *FakeData) ResetCalls(stub func(...string)) {
+	fake.resetMutex.Lock()
+	defer fake.resetMutex.Unlock()
+	fake.ResetStub = stub
+}
+
+func (fake *FakeData) ResetArgsForCall(i int) []string {
+	fake.resetMutex.RLock()
+	defer fake.resetMutex.RUnlock()
+	argsForCall := fake.resetArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeData) Scope(arg1 int) data.Data {
+	fake.scopeMutex.Lock()
+	ret, specificReturn := fake.scopeReturnsOnCall[len(fake.scopeArgsForCall)]
+	fake.scopeArgsForCall = append(fake.scopeArgsForCall, struct {
+		arg1 int
+	}{arg1})
+	stub := fake.ScopeStub
+	fakeReturns := fake.scopeReturns
+	fake.recordInvocation("Scope", []interface{}{arg1})
+	fake.scopeMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeData) ScopeCallCount() int {
+	fake.scopeMutex.RLock()
+	defer fake.scopeMutex.RUnlock()
+	return len(fake.scopeArgsForCall)
+}
+
+func (fake *FakeData) ScopeCalls(stub func(int) data.Data) {
+	fake.scopeMutex.Lock()
+	defer fake.scopeMutex.Unlock()
+	fake.ScopeStub = stub
+}
+
+func (fake *FakeData) ScopeArgsForCall(i int) int {
+	fake.scopeMutex.RLock()
+	defer fake.scopeMutex.RUnlock()
+	argsForCall := fake.scopeArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeData) ScopeReturns(result1 data.Data) {
+	fake.scopeMutex.Lock()
+	defer fake.scopeMutex.Unlock()
+	fake.ScopeStub = nil
+	fake.scopeReturns = struct {
+		result1 data.Data
+	}{result1}
+}
+
+func (fake *FakeData) ScopeReturnsOnCall(i int, result1 data.Data) {
+	fake.scopeMutex.Lock()
+	defer fake.scopeMutex.Unlock()
+	fake.ScopeStub = nil
+	if fake.scopeReturnsOnCall == nil {
+		fake.scopeReturnsOnCall = make(map[int]struct {
+			result1 data.Data
+		})
+	}
+	fake.scopeReturnsOnCall[i] = struct {
+		result1 data.Data
+	}{result1}
+}
+
+func (fake *FakeData) Snapshot() data.Snapshot {
+	fake.snapshotMutex.Lock()
+	ret, specificReturn := fake.snapshotReturnsOnCall[len(fake.snapshotArgsForCall)]
+	fake.snapshotArgsForCall = append(fake.snapshotArgsForCall, struct {
+	}{})
+	stub := fake.SnapshotStub
+	fakeReturns := fake.snapshotReturns
+	fake.recordInvocation("Snapshot", []interface{}{})
+	fake.snapshotMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeData) SnapshotCallCount() int {
+	fake.snapshotMutex.RLock()
+	defer fake.snapshotMutex.RUnlock()
+	return len(fake.snapshotArgsForCall)
+}
+
+func (fake *FakeData) SnapshotCalls(stub func() data.Snapshot) {
+	fake.snapshotMutex.Lock()
+	defer fake.snapshotMutex.Unlock()
+	fake.SnapshotStub = stub
+}
+
+func (fake *FakeData) SnapshotReturns(result1 data.Snapshot) {
+	fake.snapshotMutex.Lock()
+	defer fake.snapshotMutex.Unlock()
+	fake.SnapshotStub = nil
+	fake.snapshotReturns = struct {
+		result1 data.Snapshot
+	}{result1}
+}
+
+func (fake *FakeData) SnapshotReturnsOnCall(i int, result1 data.Snapshot) {
+	fake.snapshotMutex.Lock()
+	defer fake.snapshotMutex.Unlock()
+	fake.SnapshotStub = nil
+	if fake.snapshotReturnsOnCall == nil {
+		fake.snapshotReturnsOnCall = make(map[int]struct {
+			result1 data.Snapshot
+		})
+	}
+	fake.snapshotReturnsOnCall[i] = struct {
+		result1 data.Snapshot
+	}{result1}
+}
+
+func (fake *FakeData) Unset(arg1 string) {
+	fake.unsetMutex.Lock()
+	fake.unsetArgsForCall = append(fake.unsetArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.UnsetStub
+	fake.recordInvocation("Unset", []interface{}{arg1})
+	fake.unsetMutex.Unlock()
+	if stub != nil {
+		fake.UnsetStub(arg1)
+	}
+}
+
+func (fake *FakeData) UnsetCallCount() int {
+	fake.unsetMutex.RLock()
+	defer fake.unsetMutex.RUnlock()
+	return len(fake.unsetArgsForCall)
+}
+
+func (fake *FakeData) UnsetCalls(stub func(string)) {
+	fake.unsetMutex.Lock()
+	defer fake.unsetMutex.Unlock()
+	fake.UnsetStub = stub
+}
+
+func (fake *FakeData) UnsetArgsForCall(i int) string {
+	fake.unsetMutex.RLock()
+	defer fake.unsetMutex.RUnlock()
+	argsForCall := fake.unsetArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeData) User(arg1 int) data.Data {
+	fake.userMutex.Lock()
+	ret, specificReturn := fake.userReturnsOnCall[len(fake.userArgsForCall)]
+	fake.userArgsForCall = append(fake.userArgsForCall, struct {
+		arg1 int
+	}{arg1})
+	stub := fake.UserStub
+	fakeReturns := fake.userReturns
+	fake.recordInvocation("User", []interface{}{arg1})
+	fake.userMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeData) UserCallCount() int {
+	fake.userMutex.RLock()
+	defer fake.userMutex.RUnlock()
+	return len(fake.userArgsForCall)
+}
+
+func (fake *FakeData) UserCalls(stub func(int) data.Data) {
+	fake.userMutex.Lock()
+	defer fake.userMutex.Unlock()
+	fake.UserStub = stub
+}
+
+func (fake *FakeData) UserArgsForCall(i int) int {
+	fake.userMutex.RLock()
+	defer fake.userMutex.RUnlock()
+	argsForCall := fake.userArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeData) UserReturns(result1 data.Data) {
+	fake.userMutex.Lock()
+	defer fake.userMutex.Unlock()
+	fake.UserStub = nil
+	fake.userReturns = struct {
+		result1 data.Data
+	}{result1}
+}
+
+func (fake *FakeData) UserReturnsOnCall(i int, result1 data.Data) {
+	fake.userMutex.Lock()
+	defer fake.userMutex.Unlock()
+	fake.UserStub = nil
+	if fake.userReturnsOnCall == nil {
+		fake.userReturnsOnCall = make(map[int]struct {
+			result1 data.Data
+		})
+	}
+	fake.userReturnsOnCall[i] = struct {
+		result1 data.Data
+	}{result1}
+}
+
+func (fake *FakeData) Variables() map[string]string {
+	fake.variablesMutex.Lock()
+	ret, specificReturn := fake.variablesReturnsOnCall[len(fake.variablesArgsForCall)]
+	fake.variablesArgsForCall = append(fake.variablesArgsForCall, struct {
+	}{})
+	stub := fake.VariablesStub
+	fakeReturns := fake.variablesReturns
+	fake.recordInvocation("Variables", []interface{}{})
+	fake.variablesMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeData) VariablesCallCount() int {
+	fake.variablesMutex.RLock()
+	defer fake.variablesMutex.RUnlock()
+	return len(fake.variablesArgsForCall)
+}
+
+func (fake *FakeData) VariablesCalls(stub func() map[string]string) {
+	fake.variablesMutex.Lock()
+	defer fake.variablesMutex.Unlock()
+	fake.VariablesStub = stub
+}
+
+func (fake *FakeData) VariablesReturns(result1 map[string]string) {
+	fake.variablesMutex.Lock()
+	defer fake.variablesMutex.Unlock()
+	fake.VariablesStub = nil
+	fake.variablesReturns = struct {
+		result1 map[string]string
+	}{result1}
+}
+
+func (fake *FakeData) VariablesReturnsOnCall(i int, result1 map[string]string) {
+	fake.variablesMutex.Lock()
+	defer fake.variablesMutex.Unlock()
+	fake.VariablesStub = nil
+	if fake.variablesReturnsOnCall == nil {
+		fake.variablesReturnsOnCall = make(map[int]struct {
+			result1 map[string]string
+		})
+	}
+	fake.variablesReturnsOnCall[i] = struct {
+		result1 map[string]string
+	}{result1}
+}
+
 func (fake *FakeData) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -546,8 +971,22 @@ func (fake *FakeData) Invocations() map[string][][]interface{} {
 	defer fake.lenMutex.RUnlock()
 	fake.lookupMutex.RLock()
 	defer fake.lookupMutex.RUnlock()
+	fake.renderMutex.RLock()
+	defer fake.renderMutex.RUnlock()
 	fake.replaceMutex.RLock()
 	defer fake.replaceMutex.RUnlock()
+	fake.resetMutex.RLock()
+	defer fake.resetMutex.RUnlock()
+	fake.scopeMutex.RLock()
+	defer fake.scopeMutex.RUnlock()
+	fake.snapshotMutex.RLock()
+	defer fake.snapshotMutex.RUnlock()
+	fake.unsetMutex.RLock()
+	defer fake.unsetMutex.RUnlock()
+	fake.userMutex.RLock()
+	defer fake.userMutex.RUnlock()
+	fake.variablesMutex.RLock()
+	defer fake.variablesMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
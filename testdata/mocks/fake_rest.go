@@ -5,18 +5,48 @@ import (
 	"context"
 	"sync"
 
+	"github.com/pwmorreale/rapid/condition"
 	"github.com/pwmorreale/rapid/config"
 	"github.com/pwmorreale/rapid/rest"
 )
 
 type FakeRest struct {
-	ExecuteStub        func(context.Context, int, *config.Request, *sync.Map) bool
+	ElementsStub        func(context.Context, *config.Request) ([]string, error)
+	elementsMutex       sync.RWMutex
+	elementsArgsForCall []struct {
+		arg1 context.Context
+		arg2 *config.Request
+	}
+	elementsReturns struct {
+		result1 []string
+		result2 error
+	}
+	elementsReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
+	EvaluateStub        func(context.Context, *config.Sequence, *condition.Condition, string) bool
+	evaluateMutex       sync.RWMutex
+	evaluateArgsForCall []struct {
+		arg1 context.Context
+		arg2 *config.Sequence
+		arg3 *condition.Condition
+		arg4 string
+	}
+	evaluateReturns struct {
+		result1 bool
+	}
+	evaluateReturnsOnCall map[int]struct {
+		result1 bool
+	}
+	ExecuteStub        func(context.Context, int, int, *config.Request, rest.Options) bool
 	executeMutex       sync.RWMutex
 	executeArgsForCall []struct {
 		arg1 context.Context
 		arg2 int
-		arg3 *config.Request
-		arg4 *sync.Map
+		arg3 int
+		arg4 *config.Request
+		arg5 rest.Options
 	}
 	executeReturns struct {
 		result1 bool
@@ -34,25 +64,162 @@ type FakeRest struct {
 	pushReturnsOnCall map[int]struct {
 		result1 error
 	}
+	StartIterationStub        func(context.Context, *config.Sequence, int)
+	startIterationMutex       sync.RWMutex
+	startIterationArgsForCall []struct {
+		arg1 context.Context
+		arg2 *config.Sequence
+		arg3 int
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeRest) Execute(arg1 context.Context, arg2 int, arg3 *config.Request, arg4 *sync.Map) bool {
+func (fake *FakeRest) Elements(arg1 context.Context, arg2 *config.Request) ([]string, error) {
+	fake.elementsMutex.Lock()
+	ret, specificReturn := fake.elementsReturnsOnCall[len(fake.elementsArgsForCall)]
+	fake.elementsArgsForCall = append(fake.elementsArgsForCall, struct {
+		arg1 context.Context
+		arg2 *config.Request
+	}{arg1, arg2})
+	stub := fake.ElementsStub
+	fakeReturns := fake.elementsReturns
+	fake.recordInvocation("Elements", []interface{}{arg1, arg2})
+	fake.elementsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRest) ElementsCallCount() int {
+	fake.elementsMutex.RLock()
+	defer fake.elementsMutex.RUnlock()
+	return len(fake.elementsArgsForCall)
+}
+
+func (fake *FakeRest) ElementsCalls(stub func(context.Context, *config.Request) ([]string, error)) {
+	fake.elementsMutex.Lock()
+	defer fake.elementsMutex.Unlock()
+	fake.ElementsStub = stub
+}
+
+func (fake *FakeRest) ElementsArgsForCall(i int) (context.Context, *config.Request) {
+	fake.elementsMutex.RLock()
+	defer fake.elementsMutex.RUnlock()
+	argsForCall := fake.elementsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeRest) ElementsReturns(result1 []string, result2 error) {
+	fake.elementsMutex.Lock()
+	defer fake.elementsMutex.Unlock()
+	fake.ElementsStub = nil
+	fake.elementsReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRest) ElementsReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.elementsMutex.Lock()
+	defer fake.elementsMutex.Unlock()
+	fake.ElementsStub = nil
+	if fake.elementsReturnsOnCall == nil {
+		fake.elementsReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.elementsReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRest) Evaluate(arg1 context.Context, arg2 *config.Sequence, arg3 *condition.Condition, arg4 string) bool {
+	fake.evaluateMutex.Lock()
+	ret, specificReturn := fake.evaluateReturnsOnCall[len(fake.evaluateArgsForCall)]
+	fake.evaluateArgsForCall = append(fake.evaluateArgsForCall, struct {
+		arg1 context.Context
+		arg2 *config.Sequence
+		arg3 *condition.Condition
+		arg4 string
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.EvaluateStub
+	fakeReturns := fake.evaluateReturns
+	fake.recordInvocation("Evaluate", []interface{}{arg1, arg2, arg3, arg4})
+	fake.evaluateMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeRest) EvaluateCallCount() int {
+	fake.evaluateMutex.RLock()
+	defer fake.evaluateMutex.RUnlock()
+	return len(fake.evaluateArgsForCall)
+}
+
+func (fake *FakeRest) EvaluateCalls(stub func(context.Context, *config.Sequence, *condition.Condition, string) bool) {
+	fake.evaluateMutex.Lock()
+	defer fake.evaluateMutex.Unlock()
+	fake.EvaluateStub = stub
+}
+
+func (fake *FakeRest) EvaluateArgsForCall(i int) (context.Context, *config.Sequence, *condition.Condition, string) {
+	fake.evaluateMutex.RLock()
+	defer fake.evaluateMutex.RUnlock()
+	argsForCall := fake.evaluateArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeRest) EvaluateReturns(result1 bool) {
+	fake.evaluateMutex.Lock()
+	defer fake.evaluateMutex.Unlock()
+	fake.EvaluateStub = nil
+	fake.evaluateReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *FakeRest) EvaluateReturnsOnCall(i int, result1 bool) {
+	fake.evaluateMutex.Lock()
+	defer fake.evaluateMutex.Unlock()
+	fake.EvaluateStub = nil
+	if fake.evaluateReturnsOnCall == nil {
+		fake.evaluateReturnsOnCall = make(map[int]struct {
+			result1 bool
+		})
+	}
+	fake.evaluateReturnsOnCall[i] = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *FakeRest) Execute(arg1 context.Context, arg2 int, arg3 int, arg4 *config.Request, arg5 rest.Options) bool {
 	fake.executeMutex.Lock()
 	ret, specificReturn := fake.executeReturnsOnCall[len(fake.executeArgsForCall)]
 	fake.executeArgsForCall = append(fake.executeArgsForCall, struct {
 		arg1 context.Context
 		arg2 int
-		arg3 *config.Request
-		arg4 *sync.Map
-	}{arg1, arg2, arg3, arg4})
+		arg3 int
+		arg4 *config.Request
+		arg5 rest.Options
+	}{arg1, arg2, arg3, arg4, arg5})
 	stub := fake.ExecuteStub
 	fakeReturns := fake.executeReturns
-	fake.recordInvocation("Execute", []interface{}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("Execute", []interface{}{arg1, arg2, arg3, arg4, arg5})
 	fake.executeMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2, arg3, arg4)
+		return stub(arg1, arg2, arg3, arg4, arg5)
 	}
 	if specificReturn {
 		return ret.result1
@@ -66,17 +233,17 @@ func (fake *FakeRest) ExecuteCallCount() int {
 	return len(fake.executeArgsForCall)
 }
 
-func (fake *FakeRest) ExecuteCalls(stub func(context.Context, int, *config.Request, *sync.Map) bool) {
+func (fake *FakeRest) ExecuteCalls(stub func(context.Context, int, int, *config.Request, rest.Options) bool) {
 	fake.executeMutex.Lock()
 	defer fake.executeMutex.Unlock()
 	fake.ExecuteStub = stub
 }
 
-func (fake *FakeRest) ExecuteArgsForCall(i int) (context.Context, int, *config.Request, *sync.Map) {
+func (fake *FakeRest) ExecuteArgsForCall(i int) (context.Context, int, int, *config.Request, rest.Options) {
 	fake.executeMutex.RLock()
 	defer fake.executeMutex.RUnlock()
 	argsForCall := fake.executeArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
 }
 
 func (fake *FakeRest) ExecuteReturns(result1 bool) {
@@ -155,13 +322,53 @@ func (fake *FakeRest) PushReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeRest) StartIteration(arg1 context.Context, arg2 *config.Sequence, arg3 int) {
+	fake.startIterationMutex.Lock()
+	fake.startIterationArgsForCall = append(fake.startIterationArgsForCall, struct {
+		arg1 context.Context
+		arg2 *config.Sequence
+		arg3 int
+	}{arg1, arg2, arg3})
+	stub := fake.StartIterationStub
+	fake.recordInvocation("StartIteration", []interface{}{arg1, arg2, arg3})
+	fake.startIterationMutex.Unlock()
+	if stub != nil {
+		fake.StartIterationStub(arg1, arg2, arg3)
+	}
+}
+
+func (fake *FakeRest) StartIterationCallCount() int {
+	fake.startIterationMutex.RLock()
+	defer fake.startIterationMutex.RUnlock()
+	return len(fake.startIterationArgsForCall)
+}
+
+func (fake *FakeRest) StartIterationCalls(stub func(context.Context, *config.Sequence, int)) {
+	fake.startIterationMutex.Lock()
+	defer fake.startIterationMutex.Unlock()
+	fake.StartIterationStub = stub
+}
+
+func (fake *FakeRest) StartIterationArgsForCall(i int) (context.Context, *config.Sequence, int) {
+	fake.startIterationMutex.RLock()
+	defer fake.startIterationMutex.RUnlock()
+	argsForCall := fake.startIterationArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
 func (fake *FakeRest) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.elementsMutex.RLock()
+	defer fake.elementsMutex.RUnlock()
+	fake.evaluateMutex.RLock()
+	defer fake.evaluateMutex.RUnlock()
 	fake.executeMutex.RLock()
 	defer fake.executeMutex.RUnlock()
 	fake.pushMutex.RLock()
 	defer fake.pushMutex.RUnlock()
+	fake.startIterationMutex.RLock()
+	defer fake.startIterationMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,255 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+// Package threshold evaluates SLO thresholds against statistics.
+//
+// A threshold is an expression of the form: metric operator value, eg:
+//
+//	p99 < 250ms
+//	error_rate < 0.5%
+//	throughput > 800rps
+//	count(status=429) == 0
+package threshold
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pwmorreale/rapid/stats"
+)
+
+// Metric kinds determine how values are parsed and displayed.
+type kind int
+
+const (
+	durationKind kind = iota
+	countKind
+	rateKind
+	throughputKind
+)
+
+var expression = regexp.MustCompile(`^\s*([a-z_][a-z0-9_.]*)(?:\(\s*status\s*=\s*([1-5][0-9]{2}|[1-5]xx)\s*\))?\s*(<=|>=|==|!=|<|>)\s*(\S+)\s*$`)
+
+// Input defines the measurements a threshold is evaluated against.
+type Input struct {
+	Stats        *stats.Statistics // Execution times
+	Total        int64             // Executions, including errors
+	Errors       int64
+	Dropped      int64
+	Elapsed      time.Duration
+	StatusCounts map[int]int64 // Executions by response status code
+}
+
+// Result defines the outcome of evaluating a threshold.
+type Result struct {
+	Expression string
+	Actual     string
+	Passed     bool
+}
+
+// Threshold defines a compiled threshold expression.
+type Threshold struct {
+	expr   string
+	metric string
+	status string
+	op     string
+	kind   kind
+	q      float64 // Percentile
+	value  float64
+}
+
+// Parse compiles a threshold expression.
+func Parse(expr string) (*Threshold, error) {
+
+	m := expression.FindStringSubmatch(expr)
+	if m == nil {
+		return nil, fmt.Errorf("threshold %q: expected: metric operator value", expr)
+	}
+
+	t := &Threshold{
+		expr:   strings.TrimSpace(expr),
+		metric: m[1],
+		status: m[2],
+		op:     m[3],
+	}
+
+	err := t.setKind()
+	if err != nil {
+		return nil, fmt.Errorf("threshold %q: %w", expr, err)
+	}
+
+	t.value, err = parseValue(t.kind, m[4])
+	if err != nil {
+		return nil, fmt.Errorf("threshold %q: %w", expr, err)
+	}
+
+	return t, nil
+}
+
+// String returns the threshold expression.
+func (t *Threshold) String() string {
+	return t.expr
+}
+
+func (t *Threshold) setKind() error {
+
+	if t.status != "" && t.metric != "count" {
+		return fmt.Errorf("status filter is only valid for count")
+	}
+
+	switch t.metric {
+	case "min", "max", "avg", "std_dev":
+		t.kind = durationKind
+	case "count", "errors", "dropped":
+		t.kind = countKind
+	case "error_rate":
+		t.kind = rateKind
+	case "throughput":
+		t.kind = throughputKind
+	default:
+		if !strings.HasPrefix(t.metric, "p") {
+			return fmt.Errorf("unknown metric: %s", t.metric)
+		}
+
+		q, err := strconv.ParseFloat(t.metric[1:], 64)
+		if err != nil || q < 0 || q > 100 {
+			return fmt.Errorf("invalid percentile: %s", t.metric)
+		}
+
+		t.kind = durationKind
+		t.q = q
+	}
+
+	return nil
+}
+
+// parseValue returns durations in nanoseconds, and rates as a fraction.
+func parseValue(k kind, s string) (float64, error) {
+
+	switch k {
+	case durationKind:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+		return float64(d), nil
+
+	case rateKind:
+		if pct, ok := strings.CutSuffix(s, "%"); ok {
+			v, err := strconv.ParseFloat(pct, 64)
+			return v / 100, err
+		}
+
+	case throughputKind:
+		s = strings.TrimSuffix(strings.TrimSuffix(s, "rps"), "/s")
+	}
+
+	return strconv.ParseFloat(s, 64)
+}
+
+// measure returns the actual value of the metric.
+func (t *Threshold) measure(in *Input) float64 {
+
+	switch t.metric {
+	case "min":
+		return float64(in.Stats.GetMinDuration())
+	case "max":
+		return float64(in.Stats.GetMaxDuration())
+	case "avg":
+		n := in.Stats.GetCount() + in.Stats.GetErrors()
+		if n == 0 {
+			return 0
+		}
+		return float64(in.Stats.GetDuration()) / float64(n)
+	case "std_dev":
+		return float64(in.Stats.StdDev())
+	case "count":
+		if t.status != "" {
+			return float64(statusCount(in.StatusCounts, t.status))
+		}
+		return float64(in.Total)
+	case "errors":
+		return float64(in.Errors)
+	case "dropped":
+		return float64(in.Dropped)
+	case "error_rate":
+		if in.Total == 0 {
+			return 0
+		}
+		return float64(in.Errors) / float64(in.Total)
+	case "throughput":
+		if in.Elapsed <= 0 {
+			return 0
+		}
+		return float64(in.Total) / in.Elapsed.Seconds()
+	}
+
+	return float64(in.Stats.Percentile(t.q))
+}
+
+// statusCount returns the count for a status code, or class, eg: 5xx
+func statusCount(counts map[int]int64, status string) int64 {
+
+	if class, ok := strings.CutSuffix(status, "xx"); ok {
+		c, _ := strconv.Atoi(class)
+
+		var total int64
+		for code, n := range counts {
+			if code/100 == c {
+				total += n
+			}
+		}
+		return total
+	}
+
+	code, _ := strconv.Atoi(status)
+	return counts[code]
+}
+
+func (t *Threshold) format(v float64) string {
+
+	switch t.kind {
+	case durationKind:
+		return time.Duration(v).String()
+	case rateKind:
+		return strconv.FormatFloat(v*100, 'f', 3, 64) + "%"
+	case throughputKind:
+		return strconv.FormatFloat(v, 'f', 2, 64) + "rps"
+	}
+
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func compare(actual float64, op string, value float64) bool {
+
+	switch op {
+	case "<":
+		return actual < value
+	case "<=":
+		return actual <= value
+	case ">":
+		return actual > value
+	case ">=":
+		return actual >= value
+	case "==":
+		return actual == value
+	}
+
+	return actual != value
+}
+
+// Evaluate evaluates the threshold.
+func (t *Threshold) Evaluate(in *Input) Result {
+
+	actual := t.measure(in)
+
+	return Result{
+		Expression: t.expr,
+		Actual:     t.format(actual),
+		Passed:     compare(actual, t.op, t.value),
+	}
+}
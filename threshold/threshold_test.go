@@ -0,0 +1,113 @@
+//
+//  Copyright © 2025 Peter W. Morreale. All Rights Reserved.
+//
+
+// Package threshold_test contains unit tests for the threshold module.
+package threshold_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pwmorreale/rapid/stats"
+	"github.com/pwmorreale/rapid/threshold"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeInput() *threshold.Input {
+
+	var s stats.Statistics
+
+	// 100 executions, 1ms..100ms
+	now := time.Now()
+	for i := 1; i <= 100; i++ {
+		start := now.Add(-time.Duration(i) * time.Millisecond)
+		if i <= 2 {
+			s.Error(start)
+		} else {
+			s.Success(start)
+		}
+	}
+
+	return &threshold.Input{
+		Stats:        &s,
+		Total:        100,
+		Errors:       2,
+		Dropped:      1,
+		Elapsed:      time.Second,
+		StatusCounts: map[int]int64{200: 95, 429: 3, 503: 2},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+
+	in := makeInput()
+
+	tests := []struct {
+		expr   string
+		passed bool
+		actual string
+	}{
+		{"p99 < 250ms", true, ""},
+		{"p50 >= 60ms", false, ""},
+		{"p99.9 <= 101ms", true, ""},
+		{"max < 100ms", false, ""},
+		{"avg < 60ms", true, ""},
+		{"std_dev > 0s", true, ""},
+		{"error_rate < 0.5%", false, "2.000%"},
+		{"error_rate <= 0.02", true, "2.000%"},
+		{"throughput > 800rps", false, "100.00rps"},
+		{"throughput >= 100/s", true, "100.00rps"},
+		{"count(status=429) == 0", false, "3"},
+		{"count(status=5xx) == 2", true, "2"},
+		{"count(status = 200) != 0", true, "95"},
+		{"count == 100", true, "100"},
+		{"errors < 3", true, "2"},
+		{"dropped == 0", false, "1"},
+	}
+
+	for _, tc := range tests {
+		th, err := threshold.Parse(tc.expr)
+		assert.Nil(t, err, tc.expr)
+
+		r := th.Evaluate(in)
+		assert.Equal(t, tc.passed, r.Passed, "%s actual %s", tc.expr, r.Actual)
+		assert.Equal(t, tc.expr, r.Expression)
+		if tc.actual != "" {
+			assert.Equal(t, tc.actual, r.Actual, tc.expr)
+		}
+	}
+}
+
+func TestEvaluateEmpty(t *testing.T) {
+
+	in := &threshold.Input{Stats: &stats.Statistics{}}
+
+	for _, expr := range []string{"p99 < 1ms", "error_rate == 0", "avg == 0s", "throughput == 0"} {
+		th, err := threshold.Parse(expr)
+		assert.Nil(t, err)
+		assert.True(t, th.Evaluate(in).Passed, expr)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	bad := []string{
+		"",
+		"p99",
+		"p99 << 1ms",
+		"p99 < fast",
+		"p101 < 1ms",
+		"latency < 1ms",
+		"error_rate < lots",
+		"throughput > 8krps",
+		"p99(status=200) < 1ms",
+		"count(status=600) == 0",
+	}
+
+	for _, expr := range bad {
+		th, err := threshold.Parse(expr)
+		assert.Nil(t, th, expr)
+		assert.NotNil(t, err, expr)
+	}
+}
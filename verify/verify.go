@@ -14,9 +14,19 @@ import (
 
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/pwmorreale/rapid/config"
+	"github.com/pwmorreale/rapid/data"
 	"github.com/pwmorreale/rapid/logger"
+	"github.com/pwmorreale/rapid/openapi"
+	"github.com/pwmorreale/rapid/snapshot"
 )
 
+// Template actions are replaced by a placeholder before syntax checks.
+var templateAction = regexp.MustCompile(`\{\{.*?\}\}`)
+
+func untemplated(s string) string {
+	return templateAction.ReplaceAllString(s, "x")
+}
+
 // CheckCookies verifies cookie syntax.
 func CheckCookies(request *config.Request, response *config.Response, cookies []config.CookieData) {
 
@@ -24,7 +34,7 @@ func CheckCookies(request *config.Request, response *config.Response, cookies []
 		cookie := cookies[i]
 
 		logger.Info(request, response, "parsing cookie value: %s", cookie.Value)
-		cookies, err := http.ParseCookie(cookie.Value)
+		cookies, err := http.ParseCookie(untemplated(cookie.Value))
 		if err != nil {
 			logger.Error(request, response, "parsing cookie: %s", err)
 			continue
@@ -105,23 +115,105 @@ func CheckResponseContent(request *config.Request, response *config.Response) {
 		}
 	}
 
+	CheckResponseSchema(request, response)
+
+	CheckAssertions(request, response, "assert", "json", len(response.Content.Assert))
+
+	CheckAssertions(request, response, "xpath_assert", "xml", len(response.Content.XPathAssert))
+
+	if len(response.Content.Namespaces) > 0 && len(response.Content.XPathAssert) == 0 {
+		logger.Warn(request, response, "namespaces are only used by xpath_assert")
+	}
+
 	for i := range response.Content.Extract {
+		e := &response.Content.Extract[i]
+
+		if e.Name == "" {
+			logger.Error(request, response, "extract data_name must be defined")
+		}
 
-		if response.Content.Extract[i].Type == "" {
+		if e.Unset {
+			if e.Type != "" || e.Path != "" {
+				logger.Warn(request, response, "extract %s: type and path are ignored with unset", e.Name)
+			}
+			continue
+		}
+
+		switch e.Type {
+		case "":
 			logger.Error(request, response, "extract type must be defined")
+		case "json", "xml", "text", "header", "cookie":
+			if e.Path == "" {
+				logger.Error(request, response, "extract path must be defined")
+			}
+		case "status":
+			if e.Path != "" {
+				logger.Warn(request, response, "extract %s: path is ignored with type status", e.Name)
+			}
+		default:
+			logger.Error(request, response, "invalid extract type: %q (must be json, xml, text, header, cookie, or status)", e.Type)
+		}
+
+		if e.Type == "text" && e.Path != "" {
+			err := data.CheckRegex(e.Path, data.RegexMatch{Group: e.Group, Index: e.Index, Separator: e.Separator})
+			if err != nil {
+				logger.Error(request, response, "extract %s: %v", e.Name, err)
+			}
 		}
 
-		if response.Content.Extract[i].Path == "" {
-			logger.Error(request, response, "extract path must be defined")
+		if e.Type != "text" && (e.Group != "" || e.Index != "" || e.Separator != "") {
+			logger.Warn(request, response, "extract %s: group, index, and separator are ignored with type %s", e.Name, e.Type)
 		}
 
-		if response.Content.Extract[i].Name == "" {
-			logger.Error(request, response, "extract data_name must be defined")
+		if e.Type == "cookie" && strings.ContainsAny(e.Path, "=; ") {
+			logger.Error(request, response, "extract %s: invalid cookie name: %q", e.Name, e.Path)
 		}
 	}
 
 }
 
+// CheckAssertions checks that n assertions of a kind have content of the
+// media type, eg: json.  The assertions themselves are compiled, and
+// checked, when the scenario is parsed.
+func CheckAssertions(request *config.Request, response *config.Response, kind string, mediaType string, n int) {
+
+	if n == 0 {
+		return
+	}
+
+	if !response.Content.Expected {
+		logger.Error(request, response, "%s requires response content, but content.expected is false", kind)
+	} else if !strings.Contains(response.Content.MediaType, mediaType) {
+		logger.Warn(request, response, "%s requires %s content, content_type is %q", kind, strings.ToUpper(mediaType), response.Content.MediaType)
+	}
+}
+
+// CheckResponseSchema compiles the response JSON schema, if any.
+func CheckResponseSchema(request *config.Request, response *config.Response) {
+
+	if response.Content.Schema == "" {
+		return
+	}
+
+	if !response.Content.Expected {
+		logger.Warn(request, response, "schema specified, but content.expected is false")
+	}
+
+	if response.Content.SchemaCompiled != nil {
+		logger.Debug(request, response, "schema compiled")
+		return
+	}
+
+	sch, err := config.CompileSchema(response.Content.Schema)
+	if err != nil {
+		logger.Error(request, response, "schema: %v", err)
+		return
+	}
+
+	response.Content.SchemaCompiled = sch
+	logger.Debug(request, response, "schema compiled")
+}
+
 // CheckResponse verifies a response
 func CheckResponse(request *config.Request, response *config.Response) {
 
@@ -129,18 +221,132 @@ func CheckResponse(request *config.Request, response *config.Response) {
 		logger.Error(request, response, "invalid status code: %d", response.StatusCode)
 	}
 
+	switch response.Protocol {
+	case "", config.ProtocolHTTP1, config.ProtocolH2, config.ProtocolH2C:
+	default:
+		logger.Error(request, response, "invalid protocol: %q (must be http1, h2, or h2c)", response.Protocol)
+	}
+
 	CheckCookies(request, response, response.Cookies)
 
 	CheckHeaders(request, response, response.Headers)
 
 	CheckResponseContent(request, response)
 
+	CheckDuration(request, response)
+}
+
+// CheckDuration verifies the expected response time.
+func CheckDuration(request *config.Request, response *config.Response) {
+
+	if response.MinDuration < 0 || response.MaxDuration < 0 {
+		logger.Error(request, response, "min_duration and max_duration must not be negative")
+		return
+	}
+
+	if response.MaxDuration > 0 && response.MinDuration > response.MaxDuration {
+		logger.Error(request, response, "min_duration %s exceeds max_duration %s", response.MinDuration, response.MaxDuration)
+	}
+}
+
+// CheckPoll verifies the poll condition and limits.
+func CheckPoll(request *config.Request) {
+
+	p := request.Poll
+	if p == nil {
+		return
+	}
+
+	if p.Interval < 0 || p.Timeout < 0 || p.MaxPolls < 0 {
+		logger.Error(request, nil, "poll: interval, timeout, and max_polls must not be negative")
+	}
+
+	if p.Timeout > 0 && p.Interval >= p.Timeout {
+		logger.Warn(request, nil, "poll: interval %s is not less than timeout %s, only one poll is sent", p.Interval, p.Timeout)
+	}
+
+	until := &p.Until
+	if until.StatusCode == 0 && len(until.Headers) == 0 && len(until.Assert) == 0 {
+		logger.Error(request, nil, "poll: until has no status_code, headers, or assert")
+	}
+
+	if until.StatusCode != 0 && !hasStatus(request, until.StatusCode) {
+		logger.Error(request, nil, "poll: no response for until status_code: %d", until.StatusCode)
+	}
+
+	CheckHeaders(request, nil, until.Headers)
+}
+
+// CheckForEach checks a foreach configuration.
+func CheckForEach(request *config.Request) {
+
+	f := request.ForEach
+	if f == nil {
+		return
+	}
+
+	if f.Variable == "" {
+		logger.Error(request, nil, "foreach variable must be defined")
+	}
+
+	if f.Match == "" {
+		logger.Error(request, nil, "foreach match must be defined")
+	}
+
+	switch f.Type {
+	case "json":
+	case "xml":
+		if f.Path == "" {
+			logger.Error(request, nil, "foreach path must be defined for type xml")
+		}
+	default:
+		logger.Error(request, nil, "invalid foreach type: %q (must be json or xml)", f.Type)
+	}
+
+	herd := &request.ThunderingHerd
+	if herd.TimeLimit > 0 || herd.Rate > 0 || len(herd.Stages) > 0 {
+		logger.Warn(request, nil, "thundering_herd.time_limit, rate, and stages are ignored with foreach")
+	}
+}
+
+// hasStatus returns true if a response has the status code.
+func hasStatus(request *config.Request, statusCode int) bool {
+
+	for _, response := range request.Responses {
+		if response.StatusCode == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTemplates verifies the template syntax of the request URL, headers,
+// cookies, and content.
+func CheckTemplates(request *config.Request) {
+
+	check := func(field, s string) {
+		err := data.CheckTemplate(s)
+		if err != nil {
+			logger.Error(request, nil, "%s template: %v", field, err)
+		}
+	}
+
+	check("url", request.URL)
+	check("content", request.Content)
+
+	for i := range request.ExtraHeaders {
+		check("header "+request.ExtraHeaders[i].Name, request.ExtraHeaders[i].Value)
+	}
+
+	for i := range request.Cookies {
+		check("cookie", request.Cookies[i].Value)
+	}
 }
 
 // CheckURL verifies the URL
 func CheckURL(request *config.Request) {
 
-	u, err := url.ParseRequestURI(request.URL)
+	u, err := url.ParseRequestURI(untemplated(request.URL))
 	if err != nil {
 		logger.Error(request, nil, "URL error: %v", err)
 		return
@@ -161,6 +367,87 @@ func CheckThunderingHerd(request *config.Request) {
 	if request.ThunderingHerd.Size == 0 {
 		logger.Warn(request, nil, "missing thundering_herd.active_size, default is 1")
 	}
+
+	CheckRate(request)
+
+	CheckStages(request)
+}
+
+// CheckStages checks a staged herd configuration
+func CheckStages(request *config.Request) {
+
+	herd := &request.ThunderingHerd
+
+	if len(herd.Stages) == 0 {
+		return
+	}
+
+	if herd.TimeLimit > 0 || herd.Rate > 0 || herd.Ramp > 0 {
+		logger.Warn(request, nil, "thundering_herd.time_limit, rate, and ramp are ignored when stages are set")
+	}
+
+	names := map[string]bool{}
+	for i := range herd.Stages {
+		stage := &herd.Stages[i]
+
+		if names[stage.Name] {
+			logger.Error(request, nil, "duplicate stage name: %s", stage.Name)
+		}
+		names[stage.Name] = true
+
+		if stage.Duration <= 0 {
+			logger.Error(request, nil, "stage %s: duration must be positive", stage.Name)
+		}
+
+		if stage.Size < 0 {
+			logger.Error(request, nil, "stage %s: concurrent_requests must be positive: %d", stage.Name, stage.Size)
+		}
+
+		if stage.Rate < 0 {
+			logger.Error(request, nil, "stage %s: rate must be positive: %v", stage.Name, stage.Rate)
+		}
+
+		if stage.Size == 0 && stage.Rate == 0 {
+			logger.Warn(request, nil, "stage %s: no concurrent_requests or rate, using thundering_herd.concurrent_requests", stage.Name)
+		}
+	}
+}
+
+// CheckRate checks the open-loop rate configuration
+func CheckRate(request *config.Request) {
+
+	herd := &request.ThunderingHerd
+
+	if herd.Rate < 0 {
+		logger.Error(request, nil, "thundering_herd.rate must be positive: %v", herd.Rate)
+	}
+
+	if herd.Ramp < 0 {
+		logger.Error(request, nil, "thundering_herd.ramp must be positive: %s", herd.Ramp)
+	}
+
+	if herd.MaxOutstanding < 0 {
+		logger.Error(request, nil, "thundering_herd.max_outstanding must be positive: %d", herd.MaxOutstanding)
+	}
+
+	if herd.Rate == 0 {
+		if herd.Ramp != 0 || herd.MaxOutstanding != 0 {
+			logger.Warn(request, nil, "thundering_herd.ramp and max_outstanding are ignored without rate")
+		}
+		return
+	}
+
+	if herd.Delay != 0 {
+		logger.Warn(request, nil, "thundering_herd.delay is ignored when rate is set")
+	}
+
+	if herd.TimeLimit > 0 && herd.Ramp > herd.TimeLimit {
+		logger.Warn(request, nil, "thundering_herd.ramp %s exceeds time_limit %s", herd.Ramp, herd.TimeLimit)
+	}
+
+	if herd.MaxOutstanding > 0 && herd.MaxOutstanding < herd.Size {
+		logger.Warn(request, nil, "thundering_herd.max_outstanding %d is less than concurrent_requests %d", herd.MaxOutstanding, herd.Size)
+	}
 }
 
 // CheckRequest verifies a request
@@ -176,7 +463,18 @@ func CheckRequest(request *config.Request) {
 
 	CheckRequestContent(request)
 
-	CheckThunderingHerd(request)
+	// Setup and teardown requests execute once...
+	if request.Sequence != config.PhaseSetup && request.Sequence != config.PhaseTeardown {
+		CheckThunderingHerd(request)
+	}
+
+	CheckPoll(request)
+
+	CheckForEach(request)
+
+	if request.Transport != nil {
+		CheckTransport(request, request.Transport)
+	}
 
 	if len(request.Responses) == 0 {
 		logger.Error(request, nil, "no responses defined")
@@ -185,6 +483,61 @@ func CheckRequest(request *config.Request) {
 	CheckHeaders(request, nil, request.ExtraHeaders)
 }
 
+// CheckTransport checks a transport configuration.
+func CheckTransport(request *config.Request, tc *config.TransportConfig) {
+
+	if tc.MaxIdleConns < 0 || tc.MaxIdleConnsPerHost < 0 || tc.MaxConnsPerHost < 0 {
+		logger.Error(request, nil, "transport connection limits must be positive")
+	}
+
+	if tc.IdleTimeout < 0 || tc.DialTimeout < 0 || tc.TLSHandshakeTimeout < 0 || tc.ResponseHeaderTimeout < 0 {
+		logger.Error(request, nil, "transport timeouts must be positive")
+	}
+
+	if !tc.KeepAlives && (tc.MaxIdleConns != 0 || tc.MaxIdleConnsPerHost != 0 || tc.IdleTimeout != 0) {
+		logger.Warn(request, nil, "transport idle connection settings are ignored without keep_alives")
+	}
+
+	switch tc.Protocol {
+	case "", config.ProtocolAuto, config.ProtocolHTTP1:
+	case config.ProtocolH2, config.ProtocolH2C:
+		if !tc.KeepAlives {
+			logger.Warn(request, nil, "transport protocol %s cannot multiplex requests without keep_alives", tc.Protocol)
+		}
+	default:
+		logger.Error(request, nil, "invalid transport protocol: %q (must be http1, h2, h2c, or auto)", tc.Protocol)
+	}
+}
+
+// CheckOpenAPI verifies that a request maps to an operation in the OpenAPI
+// spec, and that its configured responses are documented.
+func CheckOpenAPI(spec *openapi.Context, request *config.Request) {
+
+	if spec == nil {
+		return
+	}
+
+	u, err := url.Parse(request.URL)
+	if err != nil {
+		return // Reported by CheckURL...
+	}
+
+	rt, _, err := spec.Find(request.Method, u)
+	if err != nil {
+		// Placeholders in the URL may prevent a match until run time.
+		logger.Warn(request, nil, "%v", err)
+		return
+	}
+
+	logger.Debug(request, nil, "openapi operation: %s %s", rt.Method, rt.Path)
+
+	for _, response := range request.Responses {
+		if rt.Operation.Responses.Status(response.StatusCode) == nil && rt.Operation.Responses.Default() == nil {
+			logger.Error(request, response, "openapi: status code %d not documented for %s %s", response.StatusCode, rt.Method, rt.Path)
+		}
+	}
+}
+
 // CheckReplacements verifies the replacement data.
 func CheckReplacements(r []config.ReplaceData) {
 
@@ -206,6 +559,251 @@ func CheckReplacements(r []config.ReplaceData) {
 	}
 }
 
+// CheckDataSources verifies the data sources are named and used.  The
+// sources themselves are loaded, and checked, when the scenario is parsed.
+func CheckDataSources(sc *config.Scenario) {
+
+	used := make(map[string]bool)
+	for _, request := range sc.AllRequests() {
+		used[request.DataSource] = true
+	}
+
+	for _, ds := range sc.DataSources {
+		if ds.Name == "" {
+			logger.Error(nil, nil, "missing data source name: %s", ds.Path)
+			continue
+		}
+
+		if !used[ds.Name] {
+			logger.Warn(nil, nil, "data source %s is not used by any request", ds.Name)
+		}
+	}
+}
+
+// CheckSnapshots verifies the response snapshots.  Each must have its own
+// snapshot file.
+func CheckSnapshots(sc *config.Scenario) {
+
+	paths := make(map[string]string)
+
+	for _, request := range sc.AllRequests() {
+
+		for _, response := range request.Responses {
+			if response.Snapshot == nil {
+				continue
+			}
+
+			if !response.Content.Expected {
+				logger.Warn(request, response, "snapshot of a response without content")
+			}
+
+			for _, path := range response.Snapshot.Ignore {
+				if path == "" {
+					logger.Error(request, response, "snapshot: empty ignore path")
+				}
+			}
+
+			path := snapshot.Path(sc.SnapshotDir, response.SnapshotName(request))
+			if other, ok := paths[path]; ok {
+				logger.Error(request, response, "snapshot %s is also used by %s", path, other)
+				continue
+			}
+			paths[path] = request.Name + "/" + response.Name
+		}
+	}
+}
+
+// CheckSequences verifies the sequences are uniquely named, and that each
+// has users and requests.
+func CheckSequences(sc *config.Scenario) {
+
+	if sc.Concurrency < 0 {
+		logger.Error(nil, nil, "concurrency must not be negative")
+	}
+
+	users := sc.SequenceUsers()
+	weighted := false
+	names := make(map[string]bool)
+
+	for i, seq := range sc.AllSequences() {
+
+		if names[seq.Name] {
+			logger.Error(nil, nil, "duplicate sequence name: %s", seq.Name)
+		}
+		if seq.Name == config.PhaseSetup || seq.Name == config.PhaseTeardown {
+			logger.Error(nil, nil, "sequence name %s is reserved", seq.Name)
+		}
+		names[seq.Name] = true
+
+		if len(seq.Requests) == 0 {
+			logger.Error(nil, nil, "%sno requests defined", seq.Prefix())
+		}
+
+		if seq.Weight < 0 || seq.Concurrency < 0 {
+			logger.Error(nil, nil, "%sweight and concurrency must not be negative", seq.Prefix())
+			continue
+		}
+
+		if seq.Concurrency > 0 {
+			if seq.Weight > 0 {
+				logger.Warn(nil, nil, "%sweight is ignored when concurrency is set", seq.Prefix())
+			}
+			continue
+		}
+
+		if seq.Weight > 0 {
+			weighted = true
+			if users[i] == 0 {
+				logger.Warn(nil, nil, "%sweight %d is too small a share of concurrency %d, it is never executed", seq.Prefix(), seq.Weight, sc.Concurrency)
+			}
+		}
+	}
+
+	if sc.Concurrency > 0 && !weighted {
+		logger.Warn(nil, nil, "concurrency is only shared by sequences with a weight")
+	}
+}
+
+// CheckPhases verifies the setup and teardown requests are executed once.
+func CheckPhases(sc *config.Scenario) {
+
+	phases := 0
+	for _, seq := range sc.Phases() {
+		phases += len(seq.Requests)
+		for i := range seq.Requests {
+			request := &seq.Requests[i]
+			herd := &request.ThunderingHerd
+			if herd.Max > 1 || herd.TimeLimit > 0 || herd.Rate > 0 || len(herd.Stages) > 0 {
+				logger.Warn(request, nil, "%s request has a thundering_herd, it executes more than once", seq.Name)
+			}
+		}
+	}
+
+	if sc.PhaseStats && phases == 0 {
+		logger.Warn(nil, nil, "phase_stats is ignored without setup or teardown requests")
+	}
+}
+
+// conditional returns true if the request may be skipped.
+func conditional(request *config.Request) bool {
+	return request.When != "" || request.SkipIf != "" || request.OnceOnly
+}
+
+// CheckFlow verifies the conditions and jumps of each sequence.  Requests
+// that are never executed are reported, as are goto loops that no
+// condition ends.
+func CheckFlow(sc *config.Scenario) {
+
+	for _, seq := range append(sc.AllSequences(), sc.Phases()...) {
+		checkSequenceFlow(seq.Requests)
+	}
+}
+
+func checkSequenceFlow(requests []config.Request) {
+
+	n := len(requests)
+	if n == 0 {
+		return
+	}
+
+	indexes := make(map[string]int, n)
+	for i := range requests {
+		name := requests[i].Name
+		if _, ok := indexes[name]; ok {
+			if jumpTarget(requests, name) {
+				logger.Error(&requests[i], nil, "duplicate request name is the target of a goto or on_failure")
+			}
+			continue
+		}
+		indexes[name] = i
+	}
+
+	// The request executed after i, n for none...
+	next := func(i int) int {
+		if requests[i].Goto != "" {
+			return indexes[requests[i].Goto]
+		}
+		return i + 1
+	}
+
+	// Reachable requests, from the first...
+	reached := make([]bool, n+1)
+	pending := []int{0}
+	for len(pending) > 0 {
+		i := pending[0]
+		pending = pending[1:]
+		if reached[i] {
+			continue
+		}
+		reached[i] = true
+		if i == n {
+			continue
+		}
+
+		pending = append(pending, next(i))
+		if requests[i].OnFailure != "" {
+			pending = append(pending, indexes[requests[i].OnFailure])
+		}
+		if conditional(&requests[i]) {
+			pending = append(pending, i+1)
+		}
+	}
+
+	for i := range requests {
+		if !reached[i] {
+			logger.Warn(&requests[i], nil, "unreachable request, it is never executed")
+		}
+	}
+
+	// Loops, following goto...
+	checked := make([]bool, n)
+	for i := range requests {
+		path := []int{}
+		onPath := make(map[int]int)
+
+		j := i
+		for j < n && !checked[j] {
+			if start, ok := onPath[j]; ok {
+				reportLoop(requests, path[start:])
+				break
+			}
+			onPath[j] = len(path)
+			path = append(path, j)
+			j = next(j)
+		}
+
+		for _, j := range path {
+			checked[j] = true
+		}
+	}
+}
+
+// jumpTarget returns true if a goto or on_failure refers to name.
+func jumpTarget(requests []config.Request, name string) bool {
+
+	for i := range requests {
+		if requests[i].Goto == name || requests[i].OnFailure == name {
+			return true
+		}
+	}
+	return false
+}
+
+// reportLoop reports a goto loop, unless a condition may end it.
+func reportLoop(requests []config.Request, loop []int) {
+
+	names := make([]string, 0, len(loop)+1)
+	for _, i := range loop {
+		if conditional(&requests[i]) {
+			return
+		}
+		names = append(names, requests[i].Name)
+	}
+	names = append(names, names[0])
+
+	logger.Error(&requests[loop[0]], nil, "goto loop has no condition to end it: %s", strings.Join(names, " -> "))
+}
+
 // Check verifies a scenario configuration.
 func Check(scenarioFile string) error {
 
@@ -225,17 +823,29 @@ func Check(scenarioFile string) error {
 
 	CheckReplacements(sc.Replacements)
 
-	if len(sc.Sequence.Requests) == 0 {
-		logger.Error(nil, nil, "no requests defined")
-	}
+	CheckTransport(nil, &sc.Transport)
+
+	CheckDataSources(sc)
+
+	CheckSnapshots(sc)
+
+	CheckFlow(sc)
 
-	for i := range sc.Sequence.Requests {
+	CheckSequences(sc)
 
-		request := &sc.Sequence.Requests[i]
+	CheckPhases(sc)
+
+	for _, request := range sc.AllRequests() {
 
 		logger.Info(request, nil, "request check started")
 		CheckRequest(request)
 
+		if sc.Templates {
+			CheckTemplates(request)
+		}
+
+		CheckOpenAPI(sc.OpenAPI.Spec, request)
+
 		for n := range request.Responses {
 			CheckResponse(request, request.Responses[n])
 		}
@@ -8,6 +8,7 @@ package verify_test
 import (
 	"io"
 	"testing"
+	"time"
 
 	"github.com/pwmorreale/rapid/config"
 	"github.com/pwmorreale/rapid/logger"
@@ -76,6 +77,297 @@ func TestRequestContent(t *testing.T) {
 
 }
 
+func TestCheckRate(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	request := &config.Request{}
+	request.ThunderingHerd = config.Stampede{Size: 10, Rate: 100, Ramp: time.Second, MaxOutstanding: 20}
+
+	verify.CheckRate(request)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	request.ThunderingHerd = config.Stampede{Size: 10, Rate: -1, Delay: time.Millisecond, MaxOutstanding: 5}
+
+	verify.CheckRate(request)
+	assert.Equal(t, 1, logger.ErrorCount())
+	assert.Equal(t, 2, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	request.ThunderingHerd = config.Stampede{Ramp: time.Second}
+
+	verify.CheckRate(request)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 1, logger.WarnCount())
+}
+
+func TestCheckStages(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	request := &config.Request{}
+	request.ThunderingHerd.Stages = []config.Stage{
+		{Name: "warm", Size: 10, Duration: time.Second},
+		{Name: "peak", Rate: 500, Duration: time.Second},
+	}
+
+	verify.CheckStages(request)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	request.ThunderingHerd.TimeLimit = time.Second
+	request.ThunderingHerd.Stages = []config.Stage{
+		{Name: "warm", Size: -1},
+		{Name: "warm", Duration: time.Second},
+	}
+
+	verify.CheckStages(request)
+	assert.Equal(t, 3, logger.ErrorCount())
+	assert.Equal(t, 2, logger.WarnCount())
+}
+
+func TestCheckTemplates(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	request := &config.Request{
+		Name:         "templates",
+		Method:       "post",
+		URL:          "https://bob_ross.com/v1/{{ .iteration }}/paintings?id={{ uuid }}",
+		Content:      `{"email": "user-{{ randInt 1 100 }}@example.com"}`,
+		ContentType:  "application/json",
+		ExtraHeaders: []config.HeaderData{{Name: "X-Request-Id", Value: "{{ uuid }}"}},
+		Cookies:      []config.CookieData{{Value: "session={{ .vars.SESSION }}"}},
+		Responses:    []*config.Response{{Name: "ok", StatusCode: 200}},
+	}
+
+	verify.CheckRequest(request)
+	assert.Equal(t, 0, logger.ErrorCount())
+
+	request.Content = `{"n": "{{ randInt 1 }"}`
+	request.ExtraHeaders[0].Value = "{{ nosuchfunc }}"
+
+	// Only checked when the scenario enables templates...
+	verify.CheckRequest(request)
+	assert.Equal(t, 0, logger.ErrorCount())
+
+	verify.CheckTemplates(request)
+	assert.Equal(t, 2, logger.ErrorCount())
+}
+
+func TestCheckTransport(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	verify.CheckTransport(nil, &config.TransportConfig{KeepAlives: true, MaxIdleConnsPerHost: 100, IdleTimeout: time.Minute})
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	verify.CheckTransport(nil, &config.TransportConfig{MaxConnsPerHost: -1, DialTimeout: -time.Second, MaxIdleConns: 10})
+	assert.Equal(t, 2, logger.ErrorCount())
+	assert.Equal(t, 1, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	verify.CheckTransport(nil, &config.TransportConfig{Protocol: "h2c"})
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 1, logger.WarnCount())
+
+	verify.CheckTransport(nil, &config.TransportConfig{KeepAlives: true, Protocol: "spdy"})
+	assert.Equal(t, 1, logger.ErrorCount())
+}
+
+func TestCheckDataSources(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	sc := &config.Scenario{
+		DataSources: []config.DataSource{
+			{Name: "painters", Path: "painters.csv"},
+			{Name: "sculptors", Path: "sculptors.csv"},
+			{Path: "anonymous.csv"},
+		},
+	}
+	sc.Sequence.Requests = []config.Request{{Name: "painter", DataSource: "painters"}}
+
+	verify.CheckDataSources(sc)
+	assert.Equal(t, 1, logger.ErrorCount())
+	assert.Equal(t, 1, logger.WarnCount())
+}
+
+func TestCheckExtractUnset(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	response := &config.Response{Name: "logout", StatusCode: 200}
+	response.Content.Extract = []config.ExtractData{
+		{Name: "TOKEN", Unset: true},
+		{Name: "ID", Unset: true, Type: "json"},
+		{Unset: true},
+	}
+
+	verify.CheckResponseContent(nil, response)
+	assert.Equal(t, 1, logger.ErrorCount())
+	assert.Equal(t, 1, logger.WarnCount())
+}
+
+func TestCheckExtractTypes(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	response := &config.Response{Name: "created", StatusCode: 201}
+	response.Content.Extract = []config.ExtractData{
+		{Name: "LOCATION", Type: "header", Path: "Location"},
+		{Name: "SESSION", Type: "cookie", Path: "session"},
+		{Name: "STATUS", Type: "status"},
+	}
+
+	verify.CheckResponseContent(nil, response)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	response.Content.Extract = []config.ExtractData{
+		{Name: "LOCATION", Type: "header"},
+		{Name: "SESSION", Type: "cookie", Path: "session=abc"},
+		{Name: "STATUS", Type: "status", Path: "code"},
+		{Name: "BODY", Type: "yaml", Path: "id"},
+	}
+
+	verify.CheckResponseContent(nil, response)
+	assert.Equal(t, 3, logger.ErrorCount())
+	assert.Equal(t, 1, logger.WarnCount())
+}
+
+func TestCheckExtractRegex(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	response := &config.Response{Name: "page", StatusCode: 200}
+	response.Content.Extract = []config.ExtractData{
+		{Name: "ID", Type: "text", Path: `id=(?P<id>\d+)`, Group: "id", Index: "all", Separator: " "},
+	}
+
+	verify.CheckResponseContent(nil, response)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	response.Content.Extract = []config.ExtractData{
+		{Name: "ID", Type: "text", Path: `id=(\d+)`, Group: "2"},
+		{Name: "NAME", Type: "text", Path: `name=(\w+)`, Index: "last"},
+		{Name: "TOKEN", Type: "json", Path: "token", Group: "1"},
+	}
+
+	verify.CheckResponseContent(nil, response)
+	assert.Equal(t, 2, logger.ErrorCount())
+	assert.Equal(t, 1, logger.WarnCount())
+}
+
+func TestCheckAssertions(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	response := &config.Response{Name: "painters", StatusCode: 200}
+	response.Content.Assert = []config.AssertData{{Path: "items.#", Op: ">=", Value: 1}}
+
+	verify.CheckResponseContent(nil, response)
+	assert.Equal(t, 1, logger.ErrorCount())
+
+	initLogger(io.Discard)
+
+	response.Content.Expected = true
+	response.Content.MediaType = "text/html"
+	verify.CheckResponseContent(nil, response)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 1, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	response.Content.MediaType = "application/json"
+	verify.CheckResponseContent(nil, response)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	// XPath assertions need XML...
+	response.Content.XPathAssert = []config.AssertData{{Path: "//Painter", Op: "count", Value: 2}}
+	verify.CheckResponseContent(nil, response)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 1, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	response.Content.Assert = nil
+	response.Content.MediaType = "text/xml"
+	verify.CheckResponseContent(nil, response)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	response.Content.XPathAssert = nil
+	response.Content.Namespaces = []config.NamespaceData{{Prefix: "p", URI: "urn:painters"}}
+	verify.CheckResponseContent(nil, response)
+	assert.Equal(t, 1, logger.WarnCount())
+}
+
+func TestCheckResponseSchema(t *testing.T) {
+
+	request := &config.Request{Name: "schema"}
+	response := &config.Response{
+		Name: "success",
+		Content: config.ContentData{
+			Expected:  true,
+			MediaType: "application/json",
+			Schema:    `{"type": "object", "minProperties": "lots"}`,
+		},
+	}
+
+	initLogger(io.Discard)
+
+	verify.CheckResponseSchema(request, response)
+	assert.Equal(t, 1, logger.ErrorCount())
+	assert.Nil(t, response.Content.SchemaCompiled)
+
+	response.Content.Schema = `{"type": "object"}`
+
+	initLogger(io.Discard)
+
+	verify.CheckResponseSchema(request, response)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.NotNil(t, response.Content.SchemaCompiled)
+}
+
+func TestCheckOpenAPI(t *testing.T) {
+
+	c := config.New()
+	sc, err := c.ParseFile("../testdata/configs/openapi.yaml")
+	assert.Nil(t, err)
+
+	initLogger(io.Discard)
+
+	// Undocumented 500 response.
+	verify.CheckOpenAPI(sc.OpenAPI.Spec, &sc.Sequence.Requests[0])
+	assert.Equal(t, 1, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	// No matching operation.
+	verify.CheckOpenAPI(sc.OpenAPI.Spec, &sc.Sequence.Requests[1])
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 1, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	// No spec.
+	verify.CheckOpenAPI(nil, &sc.Sequence.Requests[1])
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+}
+
 func TestCheck(t *testing.T) {
 
 	initLogger(io.Discard)
@@ -88,3 +380,278 @@ func TestCheck(t *testing.T) {
 	assert.Equal(t, 11, logger.InfoCount())
 	assert.Equal(t, 9, logger.DebugCount())
 }
+
+func TestCheckSnapshots(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	c := config.New()
+	sc, err := c.ParseFile("../testdata/configs/snapshot.yaml")
+	assert.Nil(t, err)
+
+	verify.CheckSnapshots(sc)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	// Responses may not share a snapshot...
+	sc.Sequence.Requests[1].Responses[0].Snapshot.Name = "painters_success"
+	sc.Sequence.Requests[0].Responses[0].Snapshot.Ignore = append(sc.Sequence.Requests[0].Responses[0].Snapshot.Ignore, "")
+	verify.CheckSnapshots(sc)
+	assert.Equal(t, 2, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	sc.Sequence.Requests[1].Responses[0].Snapshot.Name = ""
+	sc.Sequence.Requests[0].Responses[0].Snapshot.Ignore = nil
+	sc.Sequence.Requests[0].Responses[0].Content.Expected = false
+	verify.CheckSnapshots(sc)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 1, logger.WarnCount())
+}
+
+func TestCheckDuration(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	c := config.New()
+	sc, err := c.ParseFile("../testdata/configs/duration.yaml")
+	assert.Nil(t, err)
+
+	request := &sc.Sequence.Requests[0]
+	for _, response := range request.Responses {
+		verify.CheckDuration(request, response)
+	}
+	assert.Equal(t, 0, logger.ErrorCount())
+
+	response := &config.Response{Name: "throttled", MinDuration: 2 * time.Second, MaxDuration: time.Second}
+	verify.CheckDuration(request, response)
+	assert.Equal(t, 1, logger.ErrorCount())
+
+	initLogger(io.Discard)
+
+	response = &config.Response{Name: "throttled", MaxDuration: -time.Second}
+	verify.CheckDuration(request, response)
+	assert.Equal(t, 1, logger.ErrorCount())
+}
+
+func TestCheckPoll(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	c := config.New()
+	sc, err := c.ParseFile("../testdata/configs/poll.yaml")
+	assert.Nil(t, err)
+
+	for i := range sc.Sequence.Requests {
+		verify.CheckPoll(&sc.Sequence.Requests[i])
+	}
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	request := &sc.Sequence.Requests[0]
+	request.Poll.Timeout = 5 * time.Millisecond
+	request.Poll.Until.StatusCode = 204
+	verify.CheckPoll(request)
+	assert.Equal(t, 1, logger.ErrorCount())
+	assert.Equal(t, 1, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	request.Poll = &config.PollConfig{Interval: -time.Second}
+	verify.CheckPoll(request)
+	assert.Equal(t, 2, logger.ErrorCount())
+}
+
+func TestCheckForEach(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	c := config.New()
+	sc, err := c.ParseFile("../testdata/configs/foreach.yaml")
+	assert.Nil(t, err)
+
+	for i := range sc.Sequence.Requests {
+		verify.CheckForEach(&sc.Sequence.Requests[i])
+	}
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	request := &sc.Sequence.Requests[1]
+	request.ForEach = &config.ForEachConfig{Type: "yaml"}
+	request.ThunderingHerd.Rate = 10
+	verify.CheckForEach(request)
+	assert.Equal(t, 3, logger.ErrorCount())
+	assert.Equal(t, 1, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	request.ForEach = &config.ForEachConfig{Type: "xml", Variable: "INVOICES", Match: "INVOICE_ID"}
+	request.ThunderingHerd.Rate = 0
+	verify.CheckForEach(request)
+	assert.Equal(t, 1, logger.ErrorCount())
+}
+
+func TestCheckSequences(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	sc, err := config.New().ParseFile("../testdata/configs/sequences.yaml")
+	assert.Nil(t, err)
+
+	verify.CheckSequences(sc)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	// Goto is followed within each sequence...
+	verify.CheckFlow(sc)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 1, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	sc.Concurrency = 4
+	sc.Sequences[1].Name = "browse"
+	sc.Sequences[3].Weight = 1
+	verify.CheckSequences(sc)
+	assert.Equal(t, 1, logger.ErrorCount())
+	assert.Equal(t, 2, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	sc.Concurrency = -1
+	sc.Sequences[1].Name = "search"
+	sc.Sequences[1].Requests = nil
+	sc.Sequences[3].Weight = -1
+	verify.CheckSequences(sc)
+	assert.Equal(t, 3, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+}
+
+func TestCheckPhases(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	sc, err := config.New().ParseFile("../testdata/configs/phases.yaml")
+	assert.Nil(t, err)
+
+	verify.CheckPhases(sc)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	sc.Setup[0].ThunderingHerd.Max = 5
+	sc.Teardown[1].ThunderingHerd.Rate = 1
+	verify.CheckPhases(sc)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 2, logger.WarnCount())
+
+	initLogger(io.Discard)
+
+	sc, err = config.New().ParseFile("../testdata/configs/flow.yaml")
+	assert.Nil(t, err)
+
+	sc.PhaseStats = true
+	verify.CheckPhases(sc)
+	assert.Equal(t, 1, logger.WarnCount())
+
+	// Phase names are reserved...
+	sc, err = config.New().ParseFile("../testdata/configs/sequences.yaml")
+	assert.Nil(t, err)
+
+	initLogger(io.Discard)
+
+	sc.Sequences[0].Name = config.PhaseTeardown
+	verify.CheckSequences(sc)
+	assert.Equal(t, 1, logger.ErrorCount())
+}
+
+func TestCheckFlow(t *testing.T) {
+
+	initLogger(io.Discard)
+
+	sc, err := config.New().ParseFile("../testdata/configs/flow.yaml")
+	assert.Nil(t, err)
+
+	// The paint loop ends when CANVAS is unset...
+	verify.CheckFlow(sc)
+	assert.Equal(t, 0, logger.ErrorCount())
+	assert.Equal(t, 0, logger.WarnCount())
+
+	for _, tc := range []struct {
+		name     string
+		requests []config.Request
+		errors   int
+		warnings int
+	}{
+		{
+			name: "goto skips a request",
+			requests: []config.Request{
+				{Name: "login", Goto: "paint"},
+				{Name: "setup"},
+				{Name: "paint"},
+			},
+			warnings: 1,
+		},
+		{
+			name: "skipped goto",
+			requests: []config.Request{
+				{Name: "login", Goto: "paint", When: "vars.TOKEN == ''"},
+				{Name: "setup"},
+				{Name: "paint"},
+			},
+		},
+		{
+			name: "failure target",
+			requests: []config.Request{
+				{Name: "login", Goto: "paint", OnFailure: "setup"},
+				{Name: "setup"},
+				{Name: "paint"},
+			},
+		},
+		{
+			name: "loop",
+			requests: []config.Request{
+				{Name: "login"},
+				{Name: "paint"},
+				{Name: "clean", Goto: "paint"},
+				{Name: "logout"},
+			},
+			errors:   1,
+			warnings: 1,
+		},
+		{
+			name: "loop to itself",
+			requests: []config.Request{
+				{Name: "paint", Goto: "paint"},
+			},
+			errors: 1,
+		},
+		{
+			name: "once only loop",
+			requests: []config.Request{
+				{Name: "login"},
+				{Name: "paint", OnceOnly: true, Goto: "login"},
+			},
+		},
+		{
+			name: "ambiguous target",
+			requests: []config.Request{
+				{Name: "login"},
+				{Name: "login"},
+				{Name: "paint", OnFailure: "login"},
+			},
+			errors: 1,
+		},
+	} {
+		initLogger(io.Discard)
+
+		sc := &config.Scenario{}
+		sc.Sequence.Requests = tc.requests
+
+		verify.CheckFlow(sc)
+		assert.Equal(t, tc.errors, logger.ErrorCount(), tc.name)
+		assert.Equal(t, tc.warnings, logger.WarnCount(), tc.name)
+	}
+}